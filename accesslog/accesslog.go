@@ -0,0 +1,412 @@
+// Package accesslog provides a `httpx.CompletionHook` that
+// records each request serviced by a gateway in the Common Log
+// Format, the Combined Log Format or as JSON lines.
+//
+// Entries are formatted when the hook is invoked and written
+// asynchronously through a buffer so that slow storage doesn't
+// delay requests.  When the file is rotated send SIGHUP or call
+// `Reopen` to continue writing to a new file.
+package accesslog
+
+import (
+	"bufio"
+	stdctx "context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+)
+
+const (
+	defaultBufferSize    = 1024
+	defaultFlushInterval = time.Second
+)
+
+// Format is the layout of access log entries.
+type Format int
+
+const (
+	// CommonLogFormat is the NCSA Common Log Format, e.g.
+	//
+	//	10.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /a HTTP/1.1" 200 2326
+	//
+	// Selected fields that are not part of the format are
+	// appended as key=value pairs.
+	CommonLogFormat Format = iota
+	// CombinedLogFormat is the Common Log Format followed by
+	// the quoted referer and user agent of the request.
+	CombinedLogFormat
+	// JSONFormat writes an object per line with the keys
+	// "time", "method", "uri" and "protocol" followed by the
+	// selected fields.
+	JSONFormat
+)
+
+// ParseFormat returns the format with the given name, one of
+// "common", "combined" or "json".
+func ParseFormat(name string) (Format, merry.Error) {
+	switch strings.ToLower(name) {
+	case "common":
+		return CommonLogFormat, nil
+	case "combined":
+		return CombinedLogFormat, nil
+	case "json":
+		return JSONFormat, nil
+	}
+
+	return CommonLogFormat, merry.New("access log: parse format: unknown format").Append(name)
+}
+
+// Field is an optional part of an access log entry.  The value
+// of a field is also its key in JSON entries.
+type Field string
+
+const (
+	FieldRequestID Field = "request_id" // request id of the context
+	FieldActor     Field = "actor"      // id of the actor of the context
+	FieldClientIP  Field = "client_ip"  // see `httpx.Request.ClientIP`
+	FieldRoute     Field = "route"      // route template, or path if unrouted
+	FieldStatus    Field = "status"     // status code of the response
+	FieldSize      Field = "size"       // size of the response body
+	FieldLatency   Field = "latency"    // duration in seconds
+)
+
+// DefaultFields are the fields included when `Logger.Fields`
+// is empty.
+var DefaultFields = []Field{
+	FieldRequestID,
+	FieldActor,
+	FieldClientIP,
+	FieldRoute,
+	FieldStatus,
+	FieldSize,
+	FieldLatency,
+}
+
+// Logger writes access log entries.  Use its `Hook` method as
+// the `CompletionHook` of a gateway.  Entries are only recorded
+// between calls to `Start` and `Stop`.
+type Logger struct {
+	// counters first for 64-bit alignment
+	written int64
+	dropped int64
+	failed  int64
+
+	// Path is the file entries are appended to.  It is created
+	// if it doesn't exist and reopened on SIGHUP.
+	Path string
+
+	// Output receives entries if `Path` is empty.  It is never
+	// reopened or closed.
+	Output io.Writer
+
+	// Format is the layout of entries.
+	// The default is `CommonLogFormat`.
+	Format Format
+
+	// Fields selects the fields included in entries.
+	// If empty `DefaultFields` will be used.
+	Fields []Field
+
+	// BufferSize is the maximum number of entries waiting to be
+	// written.  Entries that would exceed it are dropped.
+	// The default is 1024.
+	BufferSize int
+
+	// FlushInterval is the maximum duration entries are held
+	// in memory before being written.
+	// The default is 1 second.
+	FlushInterval time.Duration
+
+	// IgnoreHangup disables reopening `Path` on SIGHUP.
+	IgnoreHangup bool
+
+	// ErrorHook optionally receives errors encountered writing
+	// or reopening the log.  The request passed to the hook is
+	// always nil.
+	// If nil errors are ignored.
+	ErrorHook httpx.ErrorHook
+
+	mtx     sync.RWMutex
+	fields  map[Field]bool
+	entries chan []byte
+	done    chan struct{}
+
+	wmtx   sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// String returns the logger counters as JSON so it may be
+// published with `expvar`.
+func (l *Logger) String() string {
+	var stats = struct {
+		Written int64 `json:"written"`
+		Dropped int64 `json:"dropped"`
+		Failed  int64 `json:"failed"`
+	}{
+		Written: atomic.LoadInt64(&l.written),
+		Dropped: atomic.LoadInt64(&l.dropped),
+		Failed:  atomic.LoadInt64(&l.failed),
+	}
+
+	bs, _ := json.Marshal(stats)
+
+	return string(bs)
+}
+
+// Start opens the log and begins writing entries.  Calling
+// `Start` on a started logger does nothing.
+func (l *Logger) Start() merry.Error {
+	if l.Path == "" && l.Output == nil {
+		return merry.New("access log: check invariants: path and output empty")
+	}
+	switch l.Format {
+	case CommonLogFormat, CombinedLogFormat, JSONFormat:
+	default:
+		return merry.New("access log: check invariants: unknown format")
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.entries != nil {
+		return nil
+	}
+
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	fields := l.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	l.fields = make(map[Field]bool, len(fields))
+	for _, field := range fields {
+		l.fields[field] = true
+	}
+
+	size := l.BufferSize
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	interval := l.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	// register for signals before returning so that none are
+	// missed once the caller considers the logger started
+	var hangup chan os.Signal
+	if !l.IgnoreHangup && l.Path != "" {
+		hangup = make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+	}
+
+	l.entries = make(chan []byte, size)
+	l.done = make(chan struct{})
+	go l.run(l.entries, hangup, interval, l.done)
+
+	return nil
+}
+
+// Stop writes the entries waiting to be written and closes the
+// log.  Entries recorded after `Stop` are dropped.
+func (l *Logger) Stop() merry.Error {
+	l.mtx.Lock()
+	entries, done := l.entries, l.done
+	l.entries, l.done = nil, nil
+	l.mtx.Unlock()
+
+	if entries == nil {
+		return nil
+	}
+
+	close(entries)
+	<-done
+
+	l.wmtx.Lock()
+	defer l.wmtx.Unlock()
+
+	err := l.flush()
+	if l.file != nil {
+		if closeErr := l.file.Close(); closeErr != nil && err == nil {
+			err = merry.Prepend(closeErr, "access log: close").Append(l.Path)
+		}
+		l.file = nil
+	}
+	l.writer = nil
+
+	return err
+}
+
+// Reopen writes the entries waiting to be written, closes the
+// log file and opens `Path` again, e.g. after the file has been
+// renamed by a log rotation tool.  It does nothing if `Path` is
+// empty.
+func (l *Logger) Reopen() merry.Error {
+	if l.Path == "" {
+		return nil
+	}
+
+	l.wmtx.Lock()
+	defer l.wmtx.Unlock()
+
+	if l.writer == nil {
+		return nil
+	}
+
+	err := l.flush()
+	if closeErr := l.file.Close(); closeErr != nil && err == nil {
+		err = merry.Prepend(closeErr, "access log: reopen: close").Append(l.Path)
+	}
+	l.file = nil
+
+	file, openErr := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if openErr != nil {
+		l.writer = bufio.NewWriter(discardWriter{})
+		return merry.Prepend(openErr, "access log: reopen").Append(l.Path)
+	}
+	l.file = file
+	l.writer = bufio.NewWriter(file)
+
+	return err
+}
+
+// Hook records an entry for the request.  It has the signature
+// of `httpx.CompletionHook`.
+func (l *Logger) Hook(ctx context.Context, request *httpx.Request, snapshot httpx.ResponseSnapshot) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if l.entries == nil {
+		atomic.AddInt64(&l.dropped, 1)
+		return
+	}
+
+	var entry []byte
+	if l.Format == JSONFormat {
+		entry = l.formatJSON(ctx, request, snapshot)
+	} else {
+		entry = l.formatCommon(ctx, request, snapshot)
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		atomic.AddInt64(&l.dropped, 1)
+	}
+}
+
+func (l *Logger) open() merry.Error {
+	l.wmtx.Lock()
+	defer l.wmtx.Unlock()
+
+	if l.Path == "" {
+		l.writer = bufio.NewWriter(l.Output)
+		return nil
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return merry.Prepend(err, "access log: open").Append(l.Path)
+	}
+	l.file = file
+	l.writer = bufio.NewWriter(file)
+
+	return nil
+}
+
+func (l *Logger) run(entries chan []byte, hangup chan os.Signal, interval time.Duration, done chan struct{}) {
+	defer close(done)
+
+	if hangup != nil {
+		defer signal.Stop(hangup)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			l.write(entry)
+		case <-ticker.C:
+			l.wmtx.Lock()
+			err := l.flush()
+			l.wmtx.Unlock()
+			l.report(err)
+		case <-hangup:
+			l.report(l.Reopen())
+		}
+	}
+}
+
+func (l *Logger) write(entry []byte) {
+	l.wmtx.Lock()
+	defer l.wmtx.Unlock()
+
+	if _, err := l.writer.Write(entry); err != nil {
+		atomic.AddInt64(&l.failed, 1)
+		l.report(merry.Prepend(err, "access log: write"))
+		l.writer.Reset(l.destination())
+		return
+	}
+
+	atomic.AddInt64(&l.written, 1)
+}
+
+// flush must be called with `wmtx` held.
+func (l *Logger) flush() merry.Error {
+	if l.writer == nil {
+		return nil
+	}
+
+	if err := l.writer.Flush(); err != nil {
+		l.writer.Reset(l.destination())
+		return merry.Prepend(err, "access log: flush")
+	}
+
+	return nil
+}
+
+// destination must be called with `wmtx` held.
+func (l *Logger) destination() io.Writer {
+	if l.Path == "" {
+		return l.Output
+	}
+	if l.file == nil {
+		return discardWriter{}
+	}
+
+	return l.file
+}
+
+func (l *Logger) report(err merry.Error) {
+	if err == nil {
+		return
+	}
+
+	ctx := context.New(stdctx.Background())
+	l.ErrorHook.InvokeSafely(ctx, nil, err)
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) {
+	return 0, merry.New("access log: file not open")
+}
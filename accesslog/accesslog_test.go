@@ -0,0 +1,365 @@
+package accesslog
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/models"
+)
+
+var (
+	expectedStart = time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+)
+
+type recordingErrorHook struct {
+	sync.Mutex
+	errs []merry.Error
+}
+
+func (h *recordingErrorHook) Handle(_ context.Context, _ *httpx.Request, err merry.Error) {
+	h.Lock()
+	defer h.Unlock()
+	h.errs = append(h.errs, err)
+}
+
+func (h *recordingErrorHook) calls() int {
+	h.Lock()
+	defer h.Unlock()
+	return len(h.errs)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("i blewed up!")
+}
+
+type stats struct {
+	Written int64 `json:"written"`
+	Dropped int64 `json:"dropped"`
+	Failed  int64 `json:"failed"`
+}
+
+func readStats(t *testing.T, l *Logger) (s stats) {
+	assert.NoError(t, json.Unmarshal([]byte(l.String()), &s))
+	return
+}
+
+func fakeEntry() (context.Context, *httpx.Request, httpx.ResponseSnapshot) {
+	r := httptest.NewRequest(http.MethodGet, "/accounts/123?expand=true", nil)
+	r.RemoteAddr = "10.0.0.1:5150"
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "zalgo/6.6.6 (he comes)")
+	request := &httpx.Request{Request: r}
+
+	actor := &models.FakeUser{IDHook: func() string { return "alice" }}
+	ctx := context.New(stdctx.Background()).WithRequestID("abc123").WithActor(actor)
+
+	snapshot := httpx.ResponseSnapshot{
+		StatusCode: http.StatusOK,
+		Size:       2326,
+		Start:      expectedStart,
+		Elapsed:    time.Millisecond * 1500,
+	}
+
+	return ctx, request, snapshot
+}
+
+func logEntry(t *testing.T, cut *Logger) string {
+	var buf bytes.Buffer
+	cut.Output = &buf
+	cut.IgnoreHangup = true
+
+	assert.NoError(t, cut.Start())
+	cut.Hook(fakeEntry())
+	assert.NoError(t, cut.Stop())
+
+	return buf.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{
+		"common":   CommonLogFormat,
+		"Combined": CombinedLogFormat,
+		"JSON":     JSONFormat,
+	} {
+		format, err := ParseFormat(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, format, name)
+	}
+
+	_, err := ParseFormat("zalgo")
+	assert.Error(t, err)
+}
+
+func TestLoggerStartMissingOutput(t *testing.T) {
+	cut := &Logger{}
+
+	assert.Error(t, cut.Start())
+}
+
+func TestLoggerStartUnknownFormat(t *testing.T) {
+	cut := &Logger{Output: ioutil.Discard, Format: Format(42)}
+
+	assert.Error(t, cut.Start())
+}
+
+func TestLoggerStartBadPath(t *testing.T) {
+	cut := &Logger{Path: "/no/such/dir/access.log", IgnoreHangup: true}
+
+	assert.Error(t, cut.Start())
+}
+
+func TestLoggerCommonLogFormat(t *testing.T) {
+	cut := &Logger{Fields: []Field{FieldClientIP, FieldActor, FieldStatus, FieldSize}}
+
+	expected := `10.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" 200 2326` + "\n"
+	assert.Equal(t, expected, logEntry(t, cut))
+}
+
+func TestLoggerCommonLogFormatDefaultFields(t *testing.T) {
+	cut := &Logger{}
+
+	expected := `10.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" 200 2326 request_id="abc123" route="/accounts/123" latency=1.500000` + "\n"
+	assert.Equal(t, expected, logEntry(t, cut))
+}
+
+func TestLoggerCommonLogFormatOmittedFields(t *testing.T) {
+	cut := &Logger{Fields: []Field{FieldRequestID}}
+
+	expected := `- - - [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" - - request_id="abc123"` + "\n"
+	assert.Equal(t, expected, logEntry(t, cut))
+}
+
+func TestLoggerRouteTemplate(t *testing.T) {
+	var buf bytes.Buffer
+	cut := &Logger{Output: &buf, Fields: []Field{FieldRoute}, IgnoreHangup: true}
+	assert.NoError(t, cut.Start())
+
+	ctx, request, snapshot := fakeEntry()
+	snapshot.Route = "/accounts/:id"
+	cut.Hook(ctx, request, snapshot)
+	assert.NoError(t, cut.Stop())
+
+	expected := `- - - [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" - - route="/accounts/:id"` + "\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestLoggerCombinedLogFormat(t *testing.T) {
+	cut := &Logger{
+		Format: CombinedLogFormat,
+		Fields: []Field{FieldClientIP, FieldActor, FieldStatus, FieldSize},
+	}
+
+	expected := `10.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" 200 2326 "https://example.com/" "zalgo/6.6.6 (he comes)"` + "\n"
+	assert.Equal(t, expected, logEntry(t, cut))
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	cut := &Logger{Format: JSONFormat}
+
+	entry := logEntry(t, cut)
+	assert.True(t, strings.HasSuffix(entry, "}\n"))
+
+	var actual map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(entry), &actual))
+
+	expected := map[string]interface{}{
+		"time":       "2000-10-10T13:55:36-07:00",
+		"method":     "GET",
+		"uri":        "/accounts/123?expand=true",
+		"protocol":   "HTTP/1.1",
+		"request_id": "abc123",
+		"actor":      "alice",
+		"client_ip":  "10.0.0.1",
+		"route":      "/accounts/123",
+		"status":     float64(200),
+		"size":       float64(2326),
+		"latency":    1.5,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestLoggerJSONFormatFields(t *testing.T) {
+	cut := &Logger{Format: JSONFormat, Fields: []Field{FieldStatus, FieldLatency}}
+
+	var actual map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(logEntry(t, cut)), &actual))
+
+	assert.Len(t, actual, 6)
+	assert.Equal(t, float64(200), actual["status"])
+	assert.Equal(t, 1.5, actual["latency"])
+}
+
+func TestLoggerEscapesValues(t *testing.T) {
+	var buf bytes.Buffer
+	cut := &Logger{
+		Output:       &buf,
+		Format:       CombinedLogFormat,
+		Fields:       []Field{FieldActor},
+		IgnoreHangup: true,
+	}
+	assert.NoError(t, cut.Start())
+
+	ctx, request, snapshot := fakeEntry()
+	actor := &models.FakeUser{IDHook: func() string { return "mallory 10.0.0.2" }}
+	ctx = ctx.WithActor(actor)
+	request.Header.Set("User-Agent", "zalgo\"\n127.0.0.1")
+
+	cut.Hook(ctx, request, snapshot)
+	assert.NoError(t, cut.Stop())
+
+	expected := `- - mallory\x2010.0.0.2 [10/Oct/2000:13:55:36 -0700] "GET /accounts/123?expand=true HTTP/1.1" - - "https://example.com/" "zalgo\"\x0a127.0.0.1"` + "\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestLoggerNotStarted(t *testing.T) {
+	var buf bytes.Buffer
+	cut := &Logger{Output: &buf}
+
+	cut.Hook(fakeEntry())
+
+	assert.Equal(t, 0, buf.Len())
+	assert.Equal(t, stats{Dropped: 1}, readStats(t, cut))
+}
+
+func TestLoggerBufferFull(t *testing.T) {
+	cut := &Logger{Output: ioutil.Discard, BufferSize: 1, IgnoreHangup: true}
+	assert.NoError(t, cut.Start())
+
+	// hold the writer so queued entries can't be written
+	cut.wmtx.Lock()
+	for i := 0; i < 10; i++ {
+		cut.Hook(fakeEntry())
+	}
+	cut.wmtx.Unlock()
+
+	assert.NoError(t, cut.Stop())
+
+	s := readStats(t, cut)
+	assert.Equal(t, int64(10), s.Written+s.Dropped)
+	assert.True(t, s.Dropped >= 8, "expected most entries to be dropped")
+}
+
+func TestLoggerFlushInterval(t *testing.T) {
+	var buf bytes.Buffer
+	cut := &Logger{Output: &buf, FlushInterval: time.Millisecond * 10, IgnoreHangup: true}
+	assert.NoError(t, cut.Start())
+	defer cut.Stop()
+
+	cut.Hook(fakeEntry())
+	time.Sleep(time.Millisecond * 50)
+
+	cut.wmtx.Lock()
+	assert.NotZero(t, buf.Len())
+	cut.wmtx.Unlock()
+}
+
+func TestLoggerWriteError(t *testing.T) {
+	hook := new(recordingErrorHook)
+	cut := &Logger{Output: failingWriter{}, BufferSize: 1, IgnoreHangup: true, ErrorHook: hook.Handle}
+	assert.NoError(t, cut.Start())
+
+	cut.Hook(fakeEntry())
+
+	assert.Error(t, cut.Stop())
+	assert.Equal(t, 0, hook.calls())
+}
+
+func TestLoggerFlushError(t *testing.T) {
+	hook := new(recordingErrorHook)
+	cut := &Logger{
+		Output:        failingWriter{},
+		FlushInterval: time.Millisecond * 10,
+		IgnoreHangup:  true,
+		ErrorHook:     hook.Handle,
+	}
+	assert.NoError(t, cut.Start())
+
+	cut.Hook(fakeEntry())
+	time.Sleep(time.Millisecond * 50)
+
+	assert.NoError(t, cut.Stop())
+	assert.Equal(t, 1, hook.calls())
+}
+
+func TestLoggerStopTwice(t *testing.T) {
+	cut := &Logger{Output: ioutil.Discard, IgnoreHangup: true}
+	assert.NoError(t, cut.Start())
+	assert.NoError(t, cut.Start())
+
+	assert.NoError(t, cut.Stop())
+	assert.NoError(t, cut.Stop())
+}
+
+func TestLoggerReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	cut := &Logger{Path: path, IgnoreHangup: true}
+	assert.NoError(t, cut.Start())
+
+	cut.Hook(fakeEntry())
+	time.Sleep(time.Millisecond * 10)
+
+	rotated := filepath.Join(dir, "access.log.1")
+	assert.NoError(t, os.Rename(path, rotated))
+	assert.NoError(t, cut.Reopen())
+
+	cut.Hook(fakeEntry())
+	assert.NoError(t, cut.Stop())
+
+	first, err := ioutil.ReadFile(rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(first), "\n"))
+
+	second, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(second), "\n"))
+
+	assert.Equal(t, stats{Written: 2}, readStats(t, cut))
+}
+
+func TestLoggerHangupReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	cut := &Logger{Path: path}
+	assert.NoError(t, cut.Start())
+
+	cut.Hook(fakeEntry())
+	time.Sleep(time.Millisecond * 10)
+
+	rotated := filepath.Join(dir, "access.log.1")
+	assert.NoError(t, os.Rename(path, rotated))
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	time.Sleep(time.Millisecond * 50)
+
+	cut.Hook(fakeEntry())
+	assert.NoError(t, cut.Stop())
+
+	second, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(second), "\n"))
+}
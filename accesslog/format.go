@@ -0,0 +1,163 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+)
+
+const (
+	commonTimeFormat = "02/Jan/2006:15:04:05 -0700"
+	hexDigits        = "0123456789abcdef"
+)
+
+func (l *Logger) formatCommon(ctx context.Context, request *httpx.Request, snapshot httpx.ResponseSnapshot) []byte {
+	b := make([]byte, 0, 256)
+
+	b = appendOptional(b, l.fields[FieldClientIP], request.ClientIP(), false)
+	b = append(b, " - "...)
+	b = appendOptional(b, l.fields[FieldActor], actorID(ctx), false)
+	b = append(b, " ["...)
+	b = snapshot.Start.AppendFormat(b, commonTimeFormat)
+	b = append(b, "] \""...)
+	b = appendEscaped(b, request.Method, true)
+	b = append(b, ' ')
+	b = appendEscaped(b, request.URL.RequestURI(), true)
+	b = append(b, ' ')
+	b = appendEscaped(b, request.Proto, true)
+	b = append(b, "\" "...)
+	if l.fields[FieldStatus] {
+		b = strconv.AppendInt(b, int64(snapshot.StatusCode), 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, ' ')
+	if l.fields[FieldSize] && snapshot.Size > 0 {
+		b = strconv.AppendInt(b, int64(snapshot.Size), 10)
+	} else {
+		b = append(b, '-')
+	}
+
+	if l.Format == CombinedLogFormat {
+		b = append(b, " \""...)
+		b = appendOptional(b, true, request.Referer(), true)
+		b = append(b, "\" \""...)
+		b = appendOptional(b, true, request.UserAgent(), true)
+		b = append(b, '"')
+	}
+
+	if l.fields[FieldRequestID] {
+		b = append(b, " request_id=\""...)
+		b = appendOptional(b, true, ctx.RequestID(), true)
+		b = append(b, '"')
+	}
+	if l.fields[FieldRoute] {
+		b = append(b, " route=\""...)
+		b = appendOptional(b, true, route(request, snapshot), true)
+		b = append(b, '"')
+	}
+	if l.fields[FieldLatency] {
+		b = append(b, " latency="...)
+		b = strconv.AppendFloat(b, snapshot.Elapsed.Seconds(), 'f', 6, 64)
+	}
+
+	return append(b, '\n')
+}
+
+func (l *Logger) formatJSON(ctx context.Context, request *httpx.Request, snapshot httpx.ResponseSnapshot) []byte {
+	b := make([]byte, 0, 384)
+
+	b = append(b, `{"time":`...)
+	b = appendJSONString(b, snapshot.Start.Format(time.RFC3339Nano))
+	b = append(b, `,"method":`...)
+	b = appendJSONString(b, request.Method)
+	b = append(b, `,"uri":`...)
+	b = appendJSONString(b, request.URL.RequestURI())
+	b = append(b, `,"protocol":`...)
+	b = appendJSONString(b, request.Proto)
+
+	for _, field := range DefaultFields {
+		if !l.fields[field] {
+			continue
+		}
+
+		b = append(b, ',')
+		b = appendJSONString(b, string(field))
+		b = append(b, ':')
+
+		switch field {
+		case FieldRequestID:
+			b = appendJSONString(b, ctx.RequestID())
+		case FieldActor:
+			b = appendJSONString(b, actorID(ctx))
+		case FieldClientIP:
+			b = appendJSONString(b, request.ClientIP())
+		case FieldRoute:
+			b = appendJSONString(b, route(request, snapshot))
+		case FieldStatus:
+			b = strconv.AppendInt(b, int64(snapshot.StatusCode), 10)
+		case FieldSize:
+			b = strconv.AppendInt(b, int64(snapshot.Size), 10)
+		case FieldLatency:
+			b = strconv.AppendFloat(b, snapshot.Elapsed.Seconds(), 'f', 6, 64)
+		}
+	}
+
+	return append(b, "}\n"...)
+}
+
+func actorID(ctx context.Context) string {
+	if actor := ctx.Actor(); actor != nil {
+		return actor.ID()
+	}
+
+	return ""
+}
+
+// route returns the route template of the endpoint that matched
+// the request, or the path if the request wasn't routed.
+func route(request *httpx.Request, snapshot httpx.ResponseSnapshot) string {
+	if snapshot.Route != "" {
+		return snapshot.Route
+	}
+
+	return request.URL.Path
+}
+
+// appendOptional appends the escaped value, or "-" if the value
+// is empty or not enabled.
+func appendOptional(b []byte, enabled bool, value string, quoted bool) []byte {
+	if !enabled || value == "" {
+		return append(b, '-')
+	}
+
+	return appendEscaped(b, value, quoted)
+}
+
+// appendEscaped appends the value with quotes, backslashes and
+// non-printable bytes escaped so that an entry can't be forged
+// or split by the user agent.  Spaces are also escaped unless
+// the value is quoted.
+func appendEscaped(b []byte, value string, quoted bool) []byte {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < ' ' || c >= 0x7f || (c == ' ' && !quoted):
+			b = append(b, '\\', 'x', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
+
+func appendJSONString(b []byte, value string) []byte {
+	bs, _ := json.Marshal(value)
+	return append(b, bs...)
+}
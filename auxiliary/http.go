@@ -35,6 +35,14 @@ type HTTPServer struct {
 	// is `true`.
 	TLSConfig *tls.Config
 
+	// CertificateLoader optionally provides the certificate
+	// used if `UseTLS` is `true`, replacing any in `TLSConfig`.
+	// The certificate is reloaded while the server is running
+	// when the files change or on SIGHUP.  Reload failures keep
+	// the current certificate and are passed to `ErrorHook` if
+	// the loader doesn't have its own.
+	CertificateLoader *httpx.CertificateLoader
+
 	// ReadTimeout is the maximum duration for reading the entire
 	// request, including the body.
 	//
@@ -200,6 +208,12 @@ func (s *HTTPServer) Serve() error {
 	}
 
 	if s.UseTLS {
+		if s.CertificateLoader != nil {
+			if err := s.startCertificateLoader(); err != nil {
+				return err.Prepend("auxiliary server: serve")
+			}
+			defer s.CertificateLoader.Stop()
+		}
 		return s.base.ServeTLS(s.listener, "", "")
 	}
 
@@ -211,6 +225,25 @@ func (s *HTTPServer) Serve() error {
 	return merry.Prepend(err, "auxiliary server: abnormal termination")
 }
 
+// startCertificateLoader loads the certificate and installs the
+// loader in the TLS config of the server.
+func (s *HTTPServer) startCertificateLoader() merry.Error {
+	loader := s.CertificateLoader
+	if loader.ErrorHook == nil {
+		loader.ErrorHook = func(ctx context.Context, request *httpx.Request, err merry.Error) {
+			s.invokeErrorHookSafely(ctx, request, err.Prepend("auxiliary server"))
+		}
+	}
+
+	if err := loader.Start(); err != nil {
+		return err
+	}
+
+	s.base.TLSConfig = loader.TLSConfig(s.TLSConfig)
+
+	return nil
+}
+
 func (s *HTTPServer) Shutdown(timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(stdctx.Background(), timeout)
 	defer cancel()
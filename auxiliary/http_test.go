@@ -2,10 +2,17 @@ package auxiliary
 
 import (
 	stdctx "context"
+	"crypto/x509"
+	"encoding/pem"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ansel1/merry"
 	"github.com/stretchr/testify/assert"
@@ -408,3 +415,81 @@ func TestHTTPServerAuthorizationUnauthorizedHandlerPanic(t *testing.T) {
 	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
 	assert.True(t, w.Flushed)
 }
+
+func TestHTTPServerCertificateLoaderMissingFiles(t *testing.T) {
+	cut := &HTTPServer{
+		Addr:   "127.0.0.1:0",
+		UseTLS: true,
+		CertificateLoader: &httpx.CertificateLoader{
+			CertFile:     "/zalgo/cert.pem",
+			KeyFile:      "/zalgo/key.pem",
+			IgnoreHangup: true,
+		},
+	}
+
+	assert.NoError(t, cut.Listen())
+	defer cut.listener.Close()
+
+	err := cut.Serve()
+	assert.Error(t, err)
+	assert.False(t, merry.Is(err, http.ErrServerClosed))
+}
+
+func TestHTTPServerCertificateLoader(t *testing.T) {
+	ts := httptest.NewTLSServer(nil)
+	certificate := ts.TLS.Certificates[0]
+	client := ts.Client()
+	ts.Close()
+
+	dir, err := ioutil.TempDir("", "auxiliary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	assert.NoError(t, err)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	assert.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	errHook := new(mockErrorHook)
+	cut := &HTTPServer{
+		Addr:   "127.0.0.1:0",
+		UseTLS: true,
+		CertificateLoader: &httpx.CertificateLoader{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			IgnoreHangup: true,
+		},
+		Router: func(context.Context, *httpx.Request) httpx.Handler {
+			return func(context.Context, *httpx.Request) httpx.Response {
+				return httpx.NewEmpty(http.StatusOK)
+			}
+		},
+		ErrorHook: errHook.Handle,
+	}
+
+	assert.NoError(t, cut.Listen())
+	addr := cut.Address()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		cut.Serve()
+		wg.Done()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	response, err := client.Get("https://" + addr + "/")
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	cut.Shutdown(time.Second)
+	wg.Wait()
+	errHook.assertNotCalled(t)
+}
@@ -0,0 +1,190 @@
+package auxiliary
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/contenttype"
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/errorx"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	defaultMaintenanceServerPath = "/maintenance"
+)
+
+var (
+	maintenanceStats = new(expvar.Map)
+)
+
+// MaintenanceController toggles maintenance mode, e.g. a
+// `gateway.Gateway`.
+type MaintenanceController interface {
+	MaintenanceStatus() service.Maintenance
+	SetMaintenance(bool)
+	SetServiceMaintenance(string, bool) merry.Error
+}
+
+type maintenanceMarshaler struct {
+	status service.Maintenance
+}
+
+func (m maintenanceMarshaler) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.status)
+}
+
+// MaintenanceServer reports and changes the maintenance mode of
+// a gateway at the given address and path.  A GET request
+// returns the current `service.Maintenance` as JSON.  A PUT or
+// POST request with the "enabled" query parameter set to "true"
+// or "false" changes it, for the whole gateway or for the
+// service named by the optional "service" query parameter, and
+// returns the result.
+// Because it can take the gateway out of service an
+// `Authorizer` is required, `Listen` fails without one.
+type MaintenanceServer struct {
+	HTTPServer
+	Path string // URL path to listen on, "/maintenance" if empty
+
+	// Controller is the target of maintenance changes.
+	// Required.
+	Controller MaintenanceController
+}
+
+func (s *MaintenanceServer) init() {
+	now := time.Now().UTC().Format(startTimeFormat)
+
+	maintenanceStats = maintenanceStats.Init()
+
+	AuxiliaryStats.Set("maintenance", maintenanceStats)
+
+	maintenanceStats.Set("hits", new(expvar.Int))
+	maintenanceStats.Set("changes", new(expvar.Int))
+
+	startTime := new(expvar.String)
+	startTime.Set(now)
+	maintenanceStats.Set("starttime", startTime)
+
+	maintenanceStats.Set("addr", expvar.Func(func() interface{} {
+		return s.Address()
+	}))
+
+	if s.Path == "" {
+		s.Path = defaultMaintenanceServerPath
+	}
+
+	s.Router = s.Route
+}
+
+func (s *MaintenanceServer) Name() string {
+	return "maintenance"
+}
+
+func (s *MaintenanceServer) Route(ctx context.Context, request *httpx.Request) httpx.Handler {
+	if request.URL.Path == s.Path {
+		return s.Service
+	}
+
+	return nil
+}
+
+func (s *MaintenanceServer) Listen() error {
+	if s.Controller == nil {
+		return merry.New("maintenance server: check invariants: controller is nil")
+	}
+	if s.Authorizer == nil {
+		return merry.New("maintenance server: check invariants: authorizer is nil")
+	}
+
+	if err := s.HTTPServer.Listen(); err != nil {
+		return err
+	}
+
+	s.init()
+
+	return nil
+}
+
+func (s *MaintenanceServer) Service(ctx context.Context, request *httpx.Request) httpx.Response {
+	maintenanceStats.Add("hits", 1)
+
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		if response := s.change(request); response != nil {
+			return response
+		}
+	default:
+		response := httpx.NewEmpty(http.StatusMethodNotAllowed)
+		response.Headers().Set(httpx.AllowHeaderKey, "GET, HEAD, POST, PUT")
+		return response
+	}
+
+	status, err := maintenanceStatusSafely(s.Controller)
+	if err != nil {
+		return httpx.NewEmptyError(http.StatusInternalServerError, err.Prepend("maintenance"))
+	}
+
+	response := &httpx.JsonResponse{
+		BasicResponse: httpx.BasicResponse{
+			Code: http.StatusOK,
+		},
+		Payload: maintenanceMarshaler{status},
+	}
+	response.Headers().Set(contenttype.ContentTypeHeaderKey, jsonContentType)
+
+	return response
+}
+
+func (s *MaintenanceServer) change(request *httpx.Request) httpx.Response {
+	query := request.URL.Query()
+
+	enabled, err := strconv.ParseBool(query.Get("enabled"))
+	if err != nil {
+		err1 := merry.Prepend(err, "maintenance: parse enabled").WithHTTPCode(http.StatusBadRequest)
+		return httpx.NewEmptyError(http.StatusBadRequest, err1)
+	}
+
+	if name := query.Get("service"); name != "" {
+		if err := setServiceMaintenanceSafely(s.Controller, name, enabled); err != nil {
+			return httpx.NewEmptyError(merry.HTTPCode(err), err.Prepend("maintenance"))
+		}
+	} else if err := setMaintenanceSafely(s.Controller, enabled); err != nil {
+		return httpx.NewEmptyError(http.StatusInternalServerError, err.Prepend("maintenance"))
+	}
+
+	maintenanceStats.Add("changes", 1)
+
+	return nil
+}
+
+func maintenanceStatusSafely(controller MaintenanceController) (_ service.Maintenance, err merry.Error) {
+	defer errorx.CapturePanic(&err, "panic in maintenance controller")
+
+	return controller.MaintenanceStatus(), nil
+}
+
+func setMaintenanceSafely(controller MaintenanceController, enabled bool) (err merry.Error) {
+	defer errorx.CapturePanic(&err, "panic in maintenance controller")
+
+	controller.SetMaintenance(enabled)
+
+	return nil
+}
+
+func setServiceMaintenanceSafely(controller MaintenanceController, name string, enabled bool) (err merry.Error) {
+	defer errorx.CapturePanic(&err, "panic in maintenance controller")
+
+	if err := controller.SetServiceMaintenance(name, enabled); err != nil {
+		return err.WithHTTPCode(http.StatusBadRequest)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package auxiliary
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/service"
+)
+
+type stubMaintenanceController struct {
+	status service.Maintenance
+}
+
+func (c *stubMaintenanceController) MaintenanceStatus() service.Maintenance {
+	return c.status
+}
+
+func (c *stubMaintenanceController) SetMaintenance(enabled bool) {
+	c.status.Enabled = enabled
+}
+
+func (c *stubMaintenanceController) SetServiceMaintenance(name string, enabled bool) merry.Error {
+	if name != "accounts" {
+		return merry.New("unknown service")
+	}
+	if enabled {
+		c.status.Services = []string{name}
+	} else {
+		c.status.Services = nil
+	}
+	return nil
+}
+
+type panicMaintenanceController struct{}
+
+func (c panicMaintenanceController) MaintenanceStatus() service.Maintenance {
+	panic("i blewed up!")
+}
+
+func (c panicMaintenanceController) SetMaintenance(bool) {
+	panic("i blewed up!")
+}
+
+func (c panicMaintenanceController) SetServiceMaintenance(string, bool) merry.Error {
+	panic("i blewed up!")
+}
+
+func newMaintenanceServer(controller MaintenanceController, errHook *mockErrorHook) *MaintenanceServer {
+	cut := &MaintenanceServer{
+		HTTPServer: HTTPServer{
+			ErrorHook: errHook.Handle,
+		},
+		Controller: controller,
+	}
+	cut.HTTPServer.init()
+	cut.init()
+
+	return cut
+}
+
+func TestMaintenanceServerMissingController(t *testing.T) {
+	cut := MaintenanceServer{
+		HTTPServer: HTTPServer{
+			Addr: ":0",
+		},
+	}
+
+	err := cut.Listen()
+	assert.Error(t, err)
+}
+
+func TestMaintenanceServerMissingAuthorizer(t *testing.T) {
+	cut := MaintenanceServer{
+		HTTPServer: HTTPServer{
+			Addr: ":0",
+		},
+		Controller: new(stubMaintenanceController),
+	}
+
+	err := cut.Listen()
+	assert.Error(t, err)
+	assert.Nil(t, cut.listener)
+}
+
+func TestMaintenanceServerAddress(t *testing.T) {
+	cut := MaintenanceServer{
+		HTTPServer: HTTPServer{
+			Addr:       ":0",
+			Authorizer: NewFakeAuthorizerDefaultFatal(t),
+		},
+		Controller: new(stubMaintenanceController),
+	}
+
+	err := cut.Listen()
+	assert.NoError(t, err)
+	assert.NotEqual(t, ":0", cut.Address())
+	assert.Equal(t, "maintenance", cut.Name())
+	assert.Equal(t, defaultMaintenanceServerPath, cut.Path)
+
+	cut.listener.Close()
+}
+
+func TestMaintenanceServerServeHTTPBadPath(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := newMaintenanceServer(new(stubMaintenanceController), errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plonk", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMaintenanceServerServeHTTPStatus(t *testing.T) {
+	errHook := new(mockErrorHook)
+	controller := &stubMaintenanceController{
+		status: service.Maintenance{Services: []string{"accounts"}},
+	}
+	cut := newMaintenanceServer(controller, errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cut.Path, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.HeaderMap.Get("Content-Type"))
+
+	var status service.Maintenance
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, controller.status, status)
+}
+
+func TestMaintenanceServerServeHTTPChange(t *testing.T) {
+	errHook := new(mockErrorHook)
+	controller := new(stubMaintenanceController)
+	cut := newMaintenanceServer(controller, errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodPut, cut.Path+"?enabled=true", nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, controller.status.Enabled)
+
+	w = httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodPost, cut.Path+"?enabled=1&service=accounts", nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status service.Maintenance
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, service.Maintenance{Enabled: true, Services: []string{"accounts"}}, status)
+
+	w = httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodPut, cut.Path+"?enabled=false", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, controller.status.Enabled)
+}
+
+func TestMaintenanceServerServeHTTPBadRequest(t *testing.T) {
+	errHook := new(mockErrorHook)
+	controller := new(stubMaintenanceController)
+	cut := newMaintenanceServer(controller, errHook)
+
+	for _, query := range []string{"", "?enabled=maybe", "?enabled=true&service=lolwut"} {
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, httptest.NewRequest(http.MethodPut, cut.Path+query, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	assert.Equal(t, service.Maintenance{}, controller.status)
+}
+
+func TestMaintenanceServerServeHTTPMethodNotAllowed(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := newMaintenanceServer(new(stubMaintenanceController), errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, cut.Path, nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, PUT", w.HeaderMap.Get("Allow"))
+}
+
+func TestMaintenanceServerServeHTTPControllerPanic(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := newMaintenanceServer(panicMaintenanceController{}, errHook)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, cut.Path, nil),
+		httptest.NewRequest(http.MethodPut, cut.Path+"?enabled=true", nil),
+		httptest.NewRequest(http.MethodPut, cut.Path+"?enabled=true&service=accounts", nil),
+	} {
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code, r.URL.String())
+	}
+
+	errHook.assertCalledN(t, 3)
+}
@@ -0,0 +1,130 @@
+package auxiliary
+
+import (
+	"expvar"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/metrics"
+)
+
+const (
+	defaultMetricsServerPath = "/metrics"
+	auxiliaryMetricsName     = "shisa_auxiliary"
+)
+
+var (
+	metricsStats = new(expvar.Map)
+)
+
+type metricsResponse struct {
+	registry *metrics.Registry
+	headers  http.Header
+}
+
+func (r metricsResponse) StatusCode() int {
+	return http.StatusOK
+}
+
+func (r metricsResponse) Headers() http.Header {
+	return r.headers
+}
+
+func (r metricsResponse) Trailers() http.Header {
+	return nil
+}
+
+func (r metricsResponse) Err() error {
+	return nil
+}
+
+func (r metricsResponse) Serialize(w io.Writer) merry.Error {
+	if err := r.registry.WriteText(w); err != nil {
+		return err.Prepend("metrics: serialize")
+	}
+
+	return nil
+}
+
+// MetricsServer serves the metrics of a `metrics.Registry` in
+// the Prometheus text exposition format to the configured
+// address and path.  The `AuxiliaryStats` expvar map is exposed
+// in the registry with the "shisa_auxiliary" prefix.
+type MetricsServer struct {
+	HTTPServer
+	Path string // URL path to listen on, "/metrics" if empty
+
+	// Registry optionally customizes the metrics exposed.
+	// If nil `metrics.DefaultRegistry` will be used.
+	Registry *metrics.Registry
+}
+
+func (s *MetricsServer) init() {
+	now := time.Now().UTC().Format(startTimeFormat)
+
+	metricsStats = metricsStats.Init()
+
+	AuxiliaryStats.Set("metrics", metricsStats)
+
+	metricsStats.Set("hits", new(expvar.Int))
+
+	startTime := new(expvar.String)
+	startTime.Set(now)
+	metricsStats.Set("starttime", startTime)
+
+	metricsStats.Set("addr", expvar.Func(func() interface{} {
+		return s.Address()
+	}))
+
+	if s.Path == "" {
+		s.Path = defaultMetricsServerPath
+	}
+
+	if s.Registry == nil {
+		s.Registry = metrics.DefaultRegistry
+	}
+	s.Registry.Register(auxiliaryMetricsName, &metrics.ExpvarCollector{
+		Namespace: auxiliaryMetricsName,
+		Var:       AuxiliaryStats,
+	})
+
+	s.Router = s.Route
+}
+
+func (s *MetricsServer) Name() string {
+	return "metrics"
+}
+
+func (s *MetricsServer) Route(ctx context.Context, request *httpx.Request) httpx.Handler {
+	if request.URL.Path == s.Path {
+		return s.Service
+	}
+
+	return nil
+}
+
+func (s *MetricsServer) Listen() error {
+	if err := s.HTTPServer.Listen(); err != nil {
+		return err
+	}
+
+	s.init()
+
+	return nil
+}
+
+func (s *MetricsServer) Service(ctx context.Context, request *httpx.Request) httpx.Response {
+	metricsStats.Add("hits", 1)
+
+	return metricsResponse{
+		registry: s.Registry,
+		headers: http.Header{
+			"Content-Type": []string{metrics.TextContentType},
+		},
+	}
+}
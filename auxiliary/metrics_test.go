@@ -0,0 +1,103 @@
+package auxiliary
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/metrics"
+)
+
+type failingCollector struct{}
+
+func (failingCollector) WriteText(io.Writer) error {
+	return errors.New("i blewed up!")
+}
+
+func newMetricsServer(registry *metrics.Registry, errHook *mockErrorHook) *MetricsServer {
+	cut := &MetricsServer{
+		HTTPServer: HTTPServer{
+			ErrorHook: errHook.Handle,
+		},
+		Registry: registry,
+	}
+	cut.HTTPServer.init()
+	cut.init()
+
+	return cut
+}
+
+func TestMetricsServerAddress(t *testing.T) {
+	cut := MetricsServer{
+		HTTPServer: HTTPServer{
+			Addr: ":0",
+		},
+		Registry: metrics.NewRegistry(),
+	}
+
+	err := cut.Listen()
+	assert.NoError(t, err)
+	assert.NotEqual(t, ":0", cut.Address())
+	assert.Equal(t, "metrics", cut.Name())
+	assert.Equal(t, defaultMetricsServerPath, cut.Path)
+
+	cut.listener.Close()
+}
+
+func TestMetricsServerDefaultRegistry(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := newMetricsServer(nil, errHook)
+	defer metrics.DefaultRegistry.Unregister(auxiliaryMetricsName)
+
+	assert.True(t, cut.Registry == metrics.DefaultRegistry)
+}
+
+func TestMetricsServerServeHTTPBadPath(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := newMetricsServer(metrics.NewRegistry(), errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plonk", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMetricsServerServeHTTP(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter, err := registry.NewCounter("requests_total", "Requests served.", "code")
+	assert.NoError(t, err)
+	counter.Inc("200")
+
+	errHook := new(mockErrorHook)
+	cut := newMetricsServer(registry, errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cut.Path, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.TextContentType, w.HeaderMap.Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "# HELP requests_total Requests served.\n"))
+	assert.Contains(t, body, "requests_total{code=\"200\"} 1\n")
+	assert.Contains(t, body, "# TYPE shisa_auxiliary_metrics_hits untyped\nshisa_auxiliary_metrics_hits 1\n")
+}
+
+func TestMetricsServerServeHTTPCollectorError(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Register("zalgo", failingCollector{})
+
+	errHook := new(mockErrorHook)
+	cut := newMetricsServer(registry, errHook)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cut.Path, nil))
+
+	errHook.assertCalledN(t, 1)
+}
@@ -0,0 +1,193 @@
+package auxiliary
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/contenttype"
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/errorx"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	defaultRoutesServerPath = "/routes"
+	acceptHeaderKey         = "Accept"
+	textContentType         = "text/plain; charset=utf-8"
+)
+
+var (
+	routesStats = new(expvar.Map)
+)
+
+// RouteLister is a source of installed routes, e.g. a
+// `gateway.Gateway`.
+type RouteLister interface {
+	Routes() []service.Route
+}
+
+type routesMarshaler struct {
+	routes []service.Route
+}
+
+func (m routesMarshaler) MarshalJSON() ([]byte, error) {
+	if m.routes == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(m.routes)
+}
+
+type routesTextResponse struct {
+	httpx.BasicResponse
+	routes []service.Route
+}
+
+func (r *routesTextResponse) Serialize(w io.Writer) merry.Error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tROUTE\tMETHODS\tHOSTS")
+	for _, route := range r.routes {
+		methods := make([]string, len(route.Methods))
+		for i, method := range route.Methods {
+			methods[i] = method.Method
+		}
+		hosts := "*"
+		if len(route.Hosts) != 0 {
+			hosts = strings.Join(route.Hosts, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", route.Service, route.Route, strings.Join(methods, ","), hosts)
+	}
+
+	return merry.Prepend(tw.Flush(), "routes: serialize")
+}
+
+// RoutesServer lists the routes of a gateway to the given
+// address and path.  The listing is a JSON array of
+// `service.Route` if the user agent accepts "application/json",
+// otherwise it is a plain text table.
+// The optional "service" query parameter limits the listing to
+// the routes of the named service.
+type RoutesServer struct {
+	HTTPServer
+	Path string // URL path to listen on, "/routes" if empty
+
+	// Lister is the source of routes to list.  Required.
+	Lister RouteLister
+}
+
+func (s *RoutesServer) init() {
+	now := time.Now().UTC().Format(startTimeFormat)
+
+	routesStats = routesStats.Init()
+
+	AuxiliaryStats.Set("routes", routesStats)
+
+	routesStats.Set("hits", new(expvar.Int))
+
+	startTime := new(expvar.String)
+	startTime.Set(now)
+	routesStats.Set("starttime", startTime)
+
+	routesStats.Set("addr", expvar.Func(func() interface{} {
+		return s.Address()
+	}))
+
+	if s.Path == "" {
+		s.Path = defaultRoutesServerPath
+	}
+
+	s.Router = s.Route
+}
+
+func (s *RoutesServer) Name() string {
+	return "routes"
+}
+
+func (s *RoutesServer) Route(ctx context.Context, request *httpx.Request) httpx.Handler {
+	if request.URL.Path == s.Path {
+		return s.Service
+	}
+
+	return nil
+}
+
+func (s *RoutesServer) Listen() error {
+	if s.Lister == nil {
+		return merry.New("routes server: check invariants: lister is nil")
+	}
+
+	if err := s.HTTPServer.Listen(); err != nil {
+		return err
+	}
+
+	s.init()
+
+	return nil
+}
+
+func (s *RoutesServer) Service(ctx context.Context, request *httpx.Request) httpx.Response {
+	routesStats.Add("hits", 1)
+
+	routes, err := listRoutesSafely(s.Lister)
+	if err != nil {
+		return httpx.NewEmptyError(http.StatusInternalServerError, err.Prepend("routes"))
+	}
+
+	if name := request.URL.Query().Get("service"); name != "" {
+		filtered := routes[:0]
+		for _, route := range routes {
+			if route.Service == name {
+				filtered = append(filtered, route)
+			}
+		}
+		routes = filtered
+	}
+
+	if acceptsJSON(request) {
+		response := &httpx.JsonResponse{
+			BasicResponse: httpx.BasicResponse{
+				Code: http.StatusOK,
+			},
+			Payload: routesMarshaler{routes},
+		}
+		response.Headers().Set(contenttype.ContentTypeHeaderKey, jsonContentType)
+
+		return response
+	}
+
+	response := &routesTextResponse{
+		BasicResponse: httpx.BasicResponse{
+			Code: http.StatusOK,
+		},
+		routes: routes,
+	}
+	response.Headers().Set(contenttype.ContentTypeHeaderKey, textContentType)
+
+	return response
+}
+
+func acceptsJSON(request *httpx.Request) bool {
+	for _, value := range request.Header[acceptHeaderKey] {
+		for _, mediaRange := range strings.Split(value, ",") {
+			if strings.HasPrefix(strings.TrimSpace(mediaRange), jsonContentType) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func listRoutesSafely(lister RouteLister) (_ []service.Route, err merry.Error) {
+	defer errorx.CapturePanic(&err, "panic in route lister")
+
+	return lister.Routes(), nil
+}
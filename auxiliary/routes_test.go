@@ -0,0 +1,158 @@
+package auxiliary
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/service"
+)
+
+type stubRouteLister []service.Route
+
+func (l stubRouteLister) Routes() []service.Route {
+	return l
+}
+
+type panicRouteLister struct{}
+
+func (l panicRouteLister) Routes() []service.Route {
+	panic("i blewed up!")
+}
+
+var (
+	stubRoutes = stubRouteLister{
+		{
+			Service: "accounts",
+			Route:   "/accounts/:id",
+			Methods: []service.RouteMethod{{Method: http.MethodGet}, {Method: http.MethodPut}},
+		},
+		{
+			Service: "admin",
+			Route:   "/users",
+			Hosts:   []string{"admin.example.com"},
+			Methods: []service.RouteMethod{{Method: http.MethodGet}},
+		},
+	}
+)
+
+func TestRoutesServerMissingLister(t *testing.T) {
+	cut := RoutesServer{
+		HTTPServer: HTTPServer{
+			Addr: ":0",
+		},
+	}
+
+	err := cut.Listen()
+	assert.Error(t, err)
+}
+
+func TestRoutesServerAddress(t *testing.T) {
+	cut := RoutesServer{
+		HTTPServer: HTTPServer{
+			Addr: ":0",
+		},
+		Lister: stubRoutes,
+	}
+
+	err := cut.Listen()
+	assert.NoError(t, err)
+	assert.NotEqual(t, ":0", cut.Address())
+	assert.Equal(t, "routes", cut.Name())
+	assert.Equal(t, defaultRoutesServerPath, cut.Path)
+
+	cut.listener.Close()
+}
+
+func TestRoutesServerServeHTTPBadPath(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/plonk", nil)
+	w := httptest.NewRecorder()
+
+	cut := RoutesServer{Lister: stubRoutes}
+	cut.HTTPServer.init()
+	cut.init()
+
+	cut.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRoutesServerServeHTTPText(t *testing.T) {
+	errHandler := new(mockErrorHook)
+	cut := RoutesServer{
+		HTTPServer: HTTPServer{
+			ErrorHook: errHandler.Handle,
+		},
+		Lister: stubRoutes,
+	}
+	cut.HTTPServer.init()
+	cut.init()
+
+	r := httptest.NewRequest(http.MethodGet, cut.Path, nil)
+	r.Header.Set("Accept", "*/*")
+	w := httptest.NewRecorder()
+
+	cut.ServeHTTP(w, r)
+
+	errHandler.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, textContentType, w.HeaderMap.Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"SERVICE", "ROUTE", "METHODS", "HOSTS"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"accounts", "/accounts/:id", "GET,PUT", "*"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"admin", "/users", "GET", "admin.example.com"}, strings.Fields(lines[2]))
+}
+
+func TestRoutesServerServeHTTPJSON(t *testing.T) {
+	errHandler := new(mockErrorHook)
+	cut := RoutesServer{
+		HTTPServer: HTTPServer{
+			ErrorHook: errHandler.Handle,
+		},
+		Lister: stubRoutes,
+	}
+	cut.HTTPServer.init()
+	cut.init()
+
+	r := httptest.NewRequest(http.MethodGet, cut.Path+"?service=admin", nil)
+	r.Header.Set("Accept", "text/html, application/json;q=0.9")
+	w := httptest.NewRecorder()
+
+	cut.ServeHTTP(w, r)
+
+	errHandler.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.HeaderMap.Get("Content-Type"))
+
+	var routes []service.Route
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "admin", routes[0].Service)
+	assert.Equal(t, "/users", routes[0].Route)
+	assert.Equal(t, []string{"admin.example.com"}, routes[0].Hosts)
+}
+
+func TestRoutesServerServeHTTPListerPanic(t *testing.T) {
+	errHandler := new(mockErrorHook)
+	cut := RoutesServer{
+		HTTPServer: HTTPServer{
+			ErrorHook: errHandler.Handle,
+		},
+		Lister: panicRouteLister{},
+	}
+	cut.HTTPServer.init()
+	cut.init()
+
+	r := httptest.NewRequest(http.MethodGet, cut.Path, nil)
+	w := httptest.NewRecorder()
+
+	cut.ServeHTTP(w, r)
+
+	errHandler.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
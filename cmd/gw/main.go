@@ -0,0 +1,61 @@
+// Command gw serves a gateway declared in a JSON configuration
+// file, see the `config` package for the format.  SIGINT and
+// SIGTERM gracefully shut the gateway down.  SIGHUP reopens the
+// access log file, if any, and reloads the TLS certificate.
+//
+// Usage:
+//
+//	gw -config gateway.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/config"
+)
+
+func main() {
+	path := flag.String("config", "gateway.json", "path of the gateway configuration `file`")
+	flag.Parse()
+
+	if err := serve(*path); err != nil {
+		fmt.Fprintf(os.Stderr, "gw: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(path string) merry.Error {
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg.Gateway.HandleInterrupt = true
+
+	gw, services, err := cfg.Build(config.NewRegistry())
+	if err != nil {
+		return err
+	}
+
+	if cfg.Gateway.AccessLog != nil {
+		logger, err := cfg.Gateway.AccessLog.Logger()
+		if err != nil {
+			return err
+		}
+		if err := logger.Start(); err != nil {
+			return err
+		}
+		defer logger.Stop()
+		gw.CompletionHook = logger.Hook
+	}
+
+	if cfg.Gateway.TLS != nil {
+		return gw.ServeTLS(services...)
+	}
+
+	return gw.Serve(services...)
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/gateway"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/middleware"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	// limits of trusted request ids, used unless set by
+	// `TrustRequestID`
+	defaultRequestIDPattern   = "^[A-Za-z0-9._:-]+$"
+	defaultRequestIDMaxLength = httpx.DefaultRequestIDMaxLength
+)
+
+// Build creates the gateway and the services it should serve
+// from the configuration, using the registry to create the
+// declared middleware.  If `Gateway.TLS` is set the gateway
+// should be started with `ServeTLS`, otherwise `Serve`.
+func (c *Config) Build(registry *Registry) (*gateway.Gateway, []*service.Service, merry.Error) {
+	if registry == nil {
+		return nil, nil, merry.New("config: check invariants: registry is nil")
+	}
+	if len(c.Services) == 0 {
+		return nil, nil, merry.New("config: check invariants: services empty")
+	}
+
+	g, err := c.Gateway.build(registry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	services := make([]*service.Service, len(c.Services))
+	for i, svc := range c.Services {
+		services[i], err = svc.build(registry, c.Gateway.RequestIDHeaderName)
+		if err != nil {
+			return nil, nil, err.Append(svc.Name)
+		}
+	}
+
+	return g, services, nil
+}
+
+func (c Gateway) build(registry *Registry) (*gateway.Gateway, merry.Error) {
+	g := &gateway.Gateway{
+		Name:                c.Name,
+		Addr:                c.Addr,
+		HandleInterrupt:     c.HandleInterrupt,
+		DisableKeepAlive:    c.DisableKeepAlive,
+		GracePeriod:         time.Duration(c.GracePeriod),
+		DrainDelay:          time.Duration(c.DrainDelay),
+		ReadTimeout:         time.Duration(c.ReadTimeout),
+		ReadHeaderTimeout:   time.Duration(c.ReadHeaderTimeout),
+		WriteTimeout:        time.Duration(c.WriteTimeout),
+		IdleTimeout:         time.Duration(c.IdleTimeout),
+		MaxHeaderBytes:      c.MaxHeaderBytes,
+		RequestIDHeaderName: c.RequestIDHeaderName,
+		HandlersTimeout:     time.Duration(c.HandlersTimeout),
+		MaxConcurrency:      c.MaxConcurrency,
+		MaxQueueDepth:       c.MaxQueueDepth,
+		QueueTimeout:        time.Duration(c.QueueTimeout),
+
+		Maintenance:               c.Maintenance,
+		MaintenanceRetryAfter:     time.Duration(c.MaintenanceRetryAfter),
+		MaintenanceAllowedClients: c.MaintenanceAllowedClients,
+		MaintenanceAllowedActors:  c.MaintenanceAllowedActors,
+	}
+
+	if c.TrustRequestID != nil {
+		validator, err := c.TrustRequestID.validator()
+		if err != nil {
+			return nil, err.Prepend("config: build gateway")
+		}
+		g.RequestIDValidator = validator
+	}
+
+	if c.TLS != nil {
+		g.CertificateLoader = &httpx.CertificateLoader{
+			CertFile:     c.TLS.CertFile,
+			KeyFile:      c.TLS.KeyFile,
+			PollInterval: time.Duration(c.TLS.PollInterval),
+		}
+	}
+
+	for _, l := range c.Listeners {
+		listener := gateway.Listener{
+			Network: l.Network,
+			Addr:    l.Addr,
+		}
+		if l.TLS != nil {
+			loader := &httpx.CertificateLoader{
+				CertFile:     l.TLS.CertFile,
+				KeyFile:      l.TLS.KeyFile,
+				PollInterval: time.Duration(l.TLS.PollInterval),
+			}
+			// fail early on bad files, the gateway starts the
+			// loader to keep the certificate current
+			if err := loader.Reload(); err != nil {
+				return nil, err.Prepend("config: build gateway: load listener certificate").Append(l.Addr)
+			}
+			listener.CertificateLoader = loader
+		}
+		g.Listeners = append(g.Listeners, listener)
+	}
+
+	handlers, err := registry.buildAll(c.Middleware)
+	if err != nil {
+		return nil, err.Prepend("config: build gateway")
+	}
+	g.Handlers = handlers
+
+	return g, nil
+}
+
+func (c TrustRequestID) validator() (httpx.RequestIDValidator, merry.Error) {
+	expr := c.Pattern
+	if expr == "" {
+		expr = defaultRequestIDPattern
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, merry.Prepend(err, "parse request id pattern").Append(expr)
+	}
+
+	maxLength := c.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultRequestIDMaxLength
+	}
+
+	validators := []httpx.RequestIDValidator{httpx.RequestIDFormat(pattern, maxLength)}
+	if len(c.Networks) != 0 {
+		validator, err := httpx.RequestIDFromNetworksWithMaxLength(maxLength, c.Networks...)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, validator)
+	}
+
+	return httpx.AllRequestIDValidators(validators...), nil
+}
+
+// The request id header of the gateway is passed down to the
+// proxies so upstreams receive the id in the same header.
+func (c Service) build(registry *Registry, requestIDHeader string) (*service.Service, merry.Error) {
+	svc := &service.Service{
+		Name:     c.Name,
+		BasePath: c.BasePath,
+		Hosts:    c.Hosts,
+		Policy:   c.Policy.policy(),
+	}
+
+	handlers, err := registry.buildAll(c.Middleware)
+	if err != nil {
+		return nil, err.Prepend("config: build service")
+	}
+	svc.Handlers = handlers
+
+	svc.Endpoints, err = buildRoutes(registry, c.Routes, requestIDHeader)
+	if err != nil {
+		return nil, err.Prepend("config: build service")
+	}
+
+	svc.Groups, err = buildGroups(registry, c.Groups, requestIDHeader)
+	if err != nil {
+		return nil, err.Prepend("config: build service")
+	}
+
+	return svc, nil
+}
+
+func buildGroups(registry *Registry, groups []Group, requestIDHeader string) ([]service.Group, merry.Error) {
+	var result []service.Group
+	for _, c := range groups {
+		group := service.Group{
+			Prefix: c.Prefix,
+			Policy: c.Policy.policy(),
+		}
+
+		var err merry.Error
+		group.Handlers, err = registry.buildAll(c.Middleware)
+		if err != nil {
+			return nil, err.Append(c.Prefix)
+		}
+
+		group.Endpoints, err = buildRoutes(registry, c.Routes, requestIDHeader)
+		if err != nil {
+			return nil, err.Append(c.Prefix)
+		}
+
+		group.Groups, err = buildGroups(registry, c.Groups, requestIDHeader)
+		if err != nil {
+			return nil, err.Append(c.Prefix)
+		}
+
+		result = append(result, group)
+	}
+
+	return result, nil
+}
+
+func buildRoutes(registry *Registry, routes []Route, requestIDHeader string) ([]service.Endpoint, merry.Error) {
+	var endpoints []service.Endpoint
+	for _, c := range routes {
+		endpoint, err := c.build(registry, requestIDHeader)
+		if err != nil {
+			return nil, err.Append(c.Route)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+func (c Route) build(registry *Registry, requestIDHeader string) (service.Endpoint, merry.Error) {
+	endpoint := service.Endpoint{Route: c.Route}
+
+	handlers, err := registry.buildAll(c.Middleware)
+	if err != nil {
+		return endpoint, err
+	}
+
+	if c.Proxy != nil {
+		proxy, err := c.Proxy.build(requestIDHeader)
+		if err != nil {
+			return endpoint, err
+		}
+		handlers = append(handlers, proxy.Service)
+	}
+
+	if len(handlers) == 0 {
+		return endpoint, merry.New("config: check invariants: route requires middleware or proxy")
+	}
+
+	var schemas []httpx.ParameterSchema
+	for _, schema := range c.QuerySchemas {
+		schemas = append(schemas, schema.schema())
+	}
+
+	methods := c.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+
+	for _, method := range methods {
+		pipeline := &service.Pipeline{
+			Policy:       c.Policy.policy(),
+			Handlers:     handlers,
+			QuerySchemas: schemas,
+		}
+
+		switch strings.ToUpper(method) {
+		case http.MethodHead:
+			endpoint.Head = pipeline
+		case http.MethodGet:
+			endpoint.Get = pipeline
+		case http.MethodPut:
+			endpoint.Put = pipeline
+		case http.MethodPost:
+			endpoint.Post = pipeline
+		case http.MethodPatch:
+			endpoint.Patch = pipeline
+		case http.MethodDelete:
+			endpoint.Delete = pipeline
+		case http.MethodConnect:
+			endpoint.Connect = pipeline
+		case http.MethodOptions:
+			endpoint.Options = pipeline
+		case http.MethodTrace:
+			endpoint.Trace = pipeline
+		default:
+			return endpoint, merry.New("config: check invariants: unknown method").Append(method)
+		}
+	}
+
+	return endpoint, nil
+}
+
+func (c Proxy) build(requestIDHeader string) (*middleware.ReverseProxy, merry.Error) {
+	router, err := c.router(c.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	proxy := &middleware.ReverseProxy{
+		Router:              router,
+		RequestIDHeaderName: requestIDHeader,
+		ReplaceRequestID:    true,
+	}
+
+	if c.Mirror != nil {
+		router, err := c.router(c.Mirror.Upstream)
+		if err != nil {
+			return nil, err.Prepend("config: build mirror")
+		}
+		proxy.Mirror = &middleware.Mirror{
+			Router:         router,
+			Percentage:     c.Mirror.Percentage,
+			MaxConcurrency: c.Mirror.MaxConcurrency,
+			MaxBodySize:    c.Mirror.MaxBodySize,
+			Timeout:        time.Duration(c.Mirror.Timeout),
+		}
+	}
+
+	return proxy, nil
+}
+
+func (c Proxy) router(rawurl string) (middleware.Router, merry.Error) {
+	upstream, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, merry.Prepend(err, "config: build proxy: parse upstream")
+	}
+	if upstream.Scheme == "" || upstream.Host == "" {
+		return nil, merry.New("config: check invariants: upstream requires scheme and host").Append(rawurl)
+	}
+
+	router := func(ctx context.Context, request *httpx.Request) (*httpx.Request, merry.Error) {
+		target := *request.URL
+		target.Scheme = upstream.Scheme
+		target.Host = upstream.Host
+
+		// the escaped form is joined so escaped separators,
+		// e.g. "%2F", reach the upstream unchanged
+		escaped := joinPath(upstream.EscapedPath(), strings.TrimPrefix(target.EscapedPath(), c.StripPrefix))
+		path, err := url.PathUnescape(escaped)
+		if err != nil {
+			return nil, merry.Prepend(err, "config: proxy route: unescape path").WithHTTPCode(http.StatusBadRequest)
+		}
+		target.Path = path
+		target.RawPath = escaped
+		request.URL = &target
+
+		if !c.PreserveHost {
+			request.Host = upstream.Host
+		}
+
+		return request, nil
+	}
+
+	return router, nil
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, "/"):
+		return prefix + path[1:]
+	case !strings.HasSuffix(prefix, "/") && !strings.HasPrefix(path, "/"):
+		return prefix + "/" + path
+	}
+
+	return prefix + path
+}
@@ -0,0 +1,400 @@
+package config
+
+import (
+	stdctx "context"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/gatewaytest"
+	"github.com/shisa-platform/core/httpx"
+)
+
+func TestBuild(t *testing.T) {
+	cfg, err := Load(strings.NewReader(exampleConfig))
+	assert.NoError(t, err)
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+	assert.NotNil(t, g)
+
+	assert.Equal(t, "edge", g.Name)
+	assert.Equal(t, time.Second*5, g.GracePeriod)
+	assert.Nil(t, g.CertificateLoader)
+	assert.Len(t, g.Listeners, 1)
+	assert.Equal(t, "unix", g.Listeners[0].Network)
+	assert.Len(t, g.Handlers, 1)
+
+	assert.Len(t, services, 1)
+	svc := services[0]
+	assert.Equal(t, "accounts", svc.Name)
+	assert.Equal(t, "/v2", svc.BasePath)
+	assert.Equal(t, time.Second*2, svc.Policy.TimeBudget)
+	assert.Len(t, svc.Endpoints, 1)
+	assert.NotNil(t, svc.Endpoints[0].Get)
+	assert.NotNil(t, svc.Endpoints[0].Put)
+	assert.Nil(t, svc.Endpoints[0].Post)
+	assert.Len(t, svc.Endpoints[0].Get.QuerySchemas, 1)
+	assert.Len(t, svc.Groups, 1)
+	assert.Len(t, svc.Groups[0].Handlers, 1)
+	assert.NotNil(t, svc.Groups[0].Endpoints[0].Get)
+}
+
+func TestBuildNilRegistry(t *testing.T) {
+	cfg := &Config{Services: []Service{{Name: "test"}}}
+
+	g, services, err := cfg.Build(nil)
+	assert.Error(t, err)
+	assert.Nil(t, g)
+	assert.Nil(t, services)
+}
+
+func TestBuildServicesEmpty(t *testing.T) {
+	cfg := &Config{}
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.Error(t, err)
+	assert.Nil(t, g)
+	assert.Nil(t, services)
+}
+
+func TestBuildErrors(t *testing.T) {
+	proxy := &Proxy{Upstream: "http://example.com"}
+
+	for name, cfg := range map[string]Config{
+		"gateway middleware": {
+			Gateway:  Gateway{Middleware: []Middleware{{Name: "lolwut"}}},
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: proxy}}}},
+		},
+		"listener certificate": {
+			Gateway:  Gateway{Listeners: []Listener{{Addr: ":9443", TLS: &TLS{CertFile: "/no/such/cert.pem", KeyFile: "/no/such/key.pem"}}}},
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: proxy}}}},
+		},
+		"service middleware": {
+			Services: []Service{{Name: "test", Middleware: []Middleware{{Name: "lolwut"}}, Routes: []Route{{Route: "/", Proxy: proxy}}}},
+		},
+		"route empty": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/"}}}},
+		},
+		"route middleware": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Middleware: []Middleware{{Name: "lolwut"}}}}}},
+		},
+		"unknown method": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Methods: []string{"FETCH"}, Proxy: proxy}}}},
+		},
+		"upstream relative": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: &Proxy{Upstream: "/accounts"}}}}},
+		},
+		"mirror upstream relative": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: &Proxy{Upstream: "http://example.com", Mirror: &Mirror{Upstream: "/shadow"}}}}}},
+		},
+		"upstream malformed": {
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: &Proxy{Upstream: "http://%zz"}}}}},
+		},
+		"request id pattern": {
+			Gateway:  Gateway{TrustRequestID: &TrustRequestID{Pattern: "[a-z"}},
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: proxy}}}},
+		},
+		"request id network": {
+			Gateway:  Gateway{TrustRequestID: &TrustRequestID{Networks: []string{"10.0.0.0/zalgo"}}},
+			Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: proxy}}}},
+		},
+		"group route": {
+			Services: []Service{{Name: "test", Groups: []Group{{Prefix: "/v2", Routes: []Route{{Route: "/"}}}}}},
+		},
+		"group middleware": {
+			Services: []Service{{Name: "test", Groups: []Group{{Prefix: "/v2", Middleware: []Middleware{{Name: "lolwut"}}}}}},
+		},
+		"nested group": {
+			Services: []Service{{Name: "test", Groups: []Group{{Prefix: "/v2", Groups: []Group{{Prefix: "/a", Routes: []Route{{Route: "/"}}}}}}}},
+		},
+	} {
+		g, services, err := cfg.Build(NewRegistry())
+		assert.Error(t, err, name)
+		assert.Nil(t, g, name)
+		assert.Nil(t, services, name)
+	}
+}
+
+func TestBuildTrustRequestID(t *testing.T) {
+	cfg := &Config{
+		Gateway: Gateway{
+			TrustRequestID: &TrustRequestID{
+				Pattern:   "^[a-z-]+$",
+				MaxLength: 16,
+				Networks:  []string{"10.0.0.0/8"},
+			},
+		},
+		Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: &Proxy{Upstream: "http://example.com"}}}}},
+	}
+
+	g, _, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+	assert.NotNil(t, g.RequestIDValidator)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:5150"
+	request := &httpx.Request{Request: r}
+	ctx := context.New(stdctx.Background())
+
+	assert.True(t, g.RequestIDValidator(ctx, request, "zalgo-he-comes"))
+	assert.False(t, g.RequestIDValidator(ctx, request, "zalgo-he-comes-and-goes"))
+	assert.False(t, g.RequestIDValidator(ctx, request, "Zalgo"))
+
+	r.RemoteAddr = "192.168.1.1:5150"
+	assert.False(t, g.RequestIDValidator(ctx, request, "zalgo-he-comes"))
+}
+
+func TestJoinPath(t *testing.T) {
+	assert.Equal(t, "/a", joinPath("", "/a"))
+	assert.Equal(t, "/a", joinPath("/a", ""))
+	assert.Equal(t, "/a/b", joinPath("/a/", "/b"))
+	assert.Equal(t, "/a/b", joinPath("/a", "b"))
+	assert.Equal(t, "/a/b", joinPath("/a", "/b"))
+	assert.Equal(t, "/a/b", joinPath("/a/", "b"))
+}
+
+func TestBuildProxy(t *testing.T) {
+	var (
+		path string
+		host string
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		host = r.Host
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	cfg := &Config{
+		Services: []Service{
+			{
+				Name:     "accounts",
+				BasePath: "/v2",
+				Routes: []Route{
+					{
+						Route: "/accounts/:id",
+						Proxy: &Proxy{Upstream: upstream.URL + "/internal", StripPrefix: "/v2"},
+					},
+					{
+						Route: "/users/:id",
+						Proxy: &Proxy{Upstream: upstream.URL, PreserveHost: true},
+					},
+				},
+			},
+		},
+	}
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+
+	harness, err := gatewaytest.New(g, services...)
+	assert.NoError(t, err)
+
+	result := harness.Do(httptest.NewRequest(http.MethodGet, "http://example.com/v2/accounts/123", nil))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, http.StatusTeapot, result.Response.StatusCode)
+	assert.Equal(t, "/internal/accounts/123", path)
+	assert.Equal(t, strings.TrimPrefix(upstream.URL, "http://"), host)
+
+	result = harness.Do(httptest.NewRequest(http.MethodGet, "http://example.com/v2/users/456", nil))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, http.StatusTeapot, result.Response.StatusCode)
+	assert.Equal(t, "/v2/users/456", path)
+	assert.Equal(t, "example.com", host)
+}
+
+func TestBuildTrustRequestIDDefaults(t *testing.T) {
+	cfg := &Config{
+		Gateway: Gateway{
+			TrustRequestID: &TrustRequestID{},
+		},
+		Services: []Service{{Name: "test", Routes: []Route{{Route: "/", Proxy: &Proxy{Upstream: "http://example.com"}}}}},
+	}
+
+	g, _, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+	assert.NotNil(t, g.RequestIDValidator)
+
+	request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	ctx := context.New(stdctx.Background())
+
+	assert.True(t, g.RequestIDValidator(ctx, request, "7c6a5e4f-0b1d-4c2e-9f3a-1b2c3d4e5f60"))
+	assert.True(t, g.RequestIDValidator(ctx, request, strings.Repeat("a", defaultRequestIDMaxLength)))
+	assert.False(t, g.RequestIDValidator(ctx, request, strings.Repeat("a", defaultRequestIDMaxLength+1)))
+	assert.False(t, g.RequestIDValidator(ctx, request, "zalgo he comes"))
+	assert.False(t, g.RequestIDValidator(ctx, request, "zalgo\x1b[31m"))
+	assert.False(t, g.RequestIDValidator(ctx, request, ""))
+}
+
+func TestBuildProxyRequestIDHeader(t *testing.T) {
+	var (
+		custom   string
+		standard string
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		custom = r.Header.Get("X-Zalgo-Id")
+		standard = r.Header.Get("X-Request-Id")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	cfg := &Config{
+		Gateway: Gateway{RequestIDHeaderName: "X-Zalgo-Id"},
+		Services: []Service{
+			{
+				Name:   "test",
+				Routes: []Route{{Route: "/test", Proxy: &Proxy{Upstream: upstream.URL}}},
+				Groups: []Group{{Prefix: "/v2", Routes: []Route{{Route: "/test", Proxy: &Proxy{Upstream: upstream.URL}}}}},
+			},
+		},
+	}
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+
+	harness, err := gatewaytest.New(g, services...)
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/test", "/v2/test"} {
+		custom, standard = "", ""
+		request := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		request.Header.Set("X-Zalgo-Id", "spoofed")
+		result := harness.Do(request)
+		assert.Empty(t, result.Errors, path)
+		assert.Equal(t, http.StatusTeapot, result.Response.StatusCode, path)
+		assert.NotEmpty(t, custom, path)
+		assert.NotEqual(t, "spoofed", custom, path)
+		assert.Equal(t, result.Response.Header.Get("X-Zalgo-Id"), custom, path)
+		assert.Empty(t, standard, path)
+	}
+}
+
+func TestBuildProxyEscapedPath(t *testing.T) {
+	var uri string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uri = r.RequestURI
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	cfg := &Config{
+		Services: []Service{
+			{
+				Name:     "files",
+				BasePath: "/v2",
+				Routes: []Route{
+					{
+						Route: "/files/:name",
+						Proxy: &Proxy{Upstream: upstream.URL + "/store", StripPrefix: "/v2"},
+					},
+				},
+			},
+		},
+	}
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+
+	harness, err := gatewaytest.New(g, services...)
+	assert.NoError(t, err)
+
+	result := harness.Do(httptest.NewRequest(http.MethodGet, "http://example.com/v2/files/a%2Fb?x=1", nil))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, http.StatusTeapot, result.Response.StatusCode)
+	assert.Equal(t, "/store/files/a%2Fb?x=1", uri)
+}
+
+func TestBuildListenerCertificateLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewTLSServer(nil)
+	certificate := ts.TLS.Certificates[0]
+	ts.Close()
+
+	key, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	assert.NoError(t, err)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600))
+
+	cfg := &Config{
+		Gateway: Gateway{
+			Listeners: []Listener{
+				{Addr: ":9443", TLS: &TLS{CertFile: certFile, KeyFile: keyFile, PollInterval: Duration(time.Hour * 24)}},
+			},
+		},
+		Services: []Service{{Name: "test", Routes: []Route{{Route: "/test", Proxy: &Proxy{Upstream: "http://localhost"}}}}},
+	}
+
+	g, _, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+	if assert.Len(t, g.Listeners, 1) && assert.NotNil(t, g.Listeners[0].CertificateLoader) {
+		loader := g.Listeners[0].CertificateLoader
+		assert.Equal(t, certFile, loader.CertFile)
+		assert.Equal(t, keyFile, loader.KeyFile)
+		assert.Equal(t, time.Hour*24, loader.PollInterval)
+		assert.Nil(t, g.Listeners[0].TLSConfig)
+	}
+}
+
+func TestBuildProxyMirror(t *testing.T) {
+	mirrored := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrored <- r.URL.Path
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	cfg := &Config{
+		Services: []Service{
+			{
+				Name: "accounts",
+				Routes: []Route{
+					{
+						Route: "/accounts/:id",
+						Proxy: &Proxy{
+							Upstream: upstream.URL,
+							Mirror:   &Mirror{Upstream: shadow.URL + "/next", Percentage: 100},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	g, services, err := cfg.Build(NewRegistry())
+	assert.NoError(t, err)
+
+	harness, err := gatewaytest.New(g, services...)
+	assert.NoError(t, err)
+
+	result := harness.Do(httptest.NewRequest(http.MethodGet, "http://example.com/accounts/123", nil))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, http.StatusTeapot, result.Response.StatusCode)
+
+	select {
+	case path := <-mirrored:
+		assert.Equal(t, "/next/accounts/123", path)
+	case <-time.After(time.Second):
+		t.Fatal("request not mirrored")
+	}
+}
@@ -0,0 +1,361 @@
+// Package config declares a gateway, its listeners and services
+// in a JSON document and builds a ready to serve gateway from
+// it.  Object keys are the names of the fields of the types in
+// this package and durations are strings such as "1.5s", e.g.
+//
+//	{
+//	  "Gateway": {"Addr": ":8080", "GracePeriod": "5s"},
+//	  "Services": [{
+//	    "Name": "accounts",
+//	    "BasePath": "/v2/accounts",
+//	    "Middleware": [{"Name": "allow-content-types", "Options": {"Permitted": ["application/json"]}}],
+//	    "Routes": [{
+//	      "Route": "/:id",
+//	      "Methods": ["GET", "PUT"],
+//	      "Policy": {"TimeBudget": "2s"},
+//	      "Proxy": {"Upstream": "http://accounts.internal:9000", "StripPrefix": "/v2"}
+//	    }]
+//	  }]
+//	}
+//
+// Middleware is referenced by name from a `Registry`.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/accesslog"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+// Config is the declaration of a gateway and its services.
+type Config struct {
+	Gateway  Gateway
+	Services []Service
+}
+
+// Gateway declares the settings of a `gateway.Gateway`.
+type Gateway struct {
+	Name                string
+	Addr                string
+	HandleInterrupt     bool
+	DisableKeepAlive    bool
+	GracePeriod         Duration
+	DrainDelay          Duration
+	ReadTimeout         Duration
+	ReadHeaderTimeout   Duration
+	WriteTimeout        Duration
+	IdleTimeout         Duration
+	MaxHeaderBytes      int
+	RequestIDHeaderName string
+	HandlersTimeout     Duration
+	MaxConcurrency      int
+	MaxQueueDepth       int
+	QueueTimeout        Duration
+
+	// Maintenance mode settings, see `gateway.Gateway`.
+	Maintenance               bool
+	MaintenanceRetryAfter     Duration
+	MaintenanceAllowedClients []string
+	MaintenanceAllowedActors  []string
+
+	// TrustRequestID optionally accepts request ids sent in
+	// the `RequestIDHeaderName` header.
+	TrustRequestID *TrustRequestID
+
+	// AccessLog optionally declares an access log.  It isn't
+	// created by `Build` since it must be started and stopped
+	// by the caller, see `AccessLog.Logger`.
+	AccessLog *AccessLog
+
+	// TLS optionally enables TLS for `Addr`.  The certificate
+	// is reloaded when the files change or on SIGHUP.
+	TLS *TLS
+
+	// Listeners are optional addresses to serve in addition to
+	// `Addr`.
+	Listeners []Listener
+
+	// Middleware are run on all requests.
+	Middleware []Middleware
+}
+
+// TLS declares the certificate and key files used for TLS.
+type TLS struct {
+	CertFile     string
+	KeyFile      string
+	PollInterval Duration
+}
+
+// TrustRequestID declares the `httpx.RequestIDValidator` of the
+// gateway.  An inbound request id is accepted only if it passes
+// all of the checks.  The pattern and length are always checked
+// so an empty value still rejects ids that are too long or
+// contain unexpected characters.
+type TrustRequestID struct {
+	Pattern   string   // regular expression the id must match, "^[A-Za-z0-9._:-]+$" if empty
+	MaxLength int      // maximum length of the id in bytes, 128 if zero
+	Networks  []string // CIDR blocks of trusted peers
+}
+
+// AccessLog declares an `accesslog.Logger`.
+type AccessLog struct {
+	Path          string   // "-" or empty for stdout
+	Format        string   // "common", "combined" or "json", "common" if empty
+	Fields        []string // see `accesslog.Field`
+	BufferSize    int
+	FlushInterval Duration
+}
+
+// Logger returns the declared access logger.  Use its `Hook` as
+// the `CompletionHook` of the gateway once it is started.
+func (c AccessLog) Logger() (*accesslog.Logger, merry.Error) {
+	logger := &accesslog.Logger{
+		Path:          c.Path,
+		BufferSize:    c.BufferSize,
+		FlushInterval: time.Duration(c.FlushInterval),
+	}
+	if c.Path == "" || c.Path == "-" {
+		logger.Path = ""
+		logger.Output = os.Stdout
+	}
+
+	if c.Format != "" {
+		format, err := accesslog.ParseFormat(c.Format)
+		if err != nil {
+			return nil, err.Prepend("config: build access log")
+		}
+		logger.Format = format
+	}
+
+	for _, name := range c.Fields {
+		field := accesslog.Field(name)
+		known := false
+		for _, f := range accesslog.DefaultFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, merry.New("config: build access log: unknown field").Append(name)
+		}
+		logger.Fields = append(logger.Fields, field)
+	}
+
+	return logger, nil
+}
+
+// Listener declares an additional gateway listener.  The
+// certificate of a TLS listener is reloaded on SIGHUP and, if
+// `TLS.PollInterval` is set, when its files change.
+type Listener struct {
+	Network string // "tcp" or "unix", "tcp" if empty
+	Addr    string
+	TLS     *TLS
+}
+
+// Middleware references a middleware in a `Registry` by name,
+// along with the options used to create it.
+type Middleware struct {
+	Name    string
+	Options json.RawMessage
+}
+
+// Service declares a `service.Service`.
+type Service struct {
+	Name       string
+	BasePath   string
+	Hosts      []string
+	Policy     Policy
+	Middleware []Middleware
+	Routes     []Route
+	Groups     []Group
+}
+
+// Group declares a `service.Group`.
+type Group struct {
+	Prefix     string
+	Policy     Policy
+	Middleware []Middleware
+	Routes     []Route
+	Groups     []Group
+}
+
+// Route declares an endpoint.  The same pipeline is used for
+// each of its methods.  At least one of `Middleware` or `Proxy`
+// is required, the proxy is run after the middleware.
+type Route struct {
+	Route        string
+	Methods      []string // "GET" if empty
+	Policy       Policy
+	QuerySchemas []QuerySchema
+	Middleware   []Middleware
+	Proxy        *Proxy
+}
+
+// QuerySchema declares an `httpx.ParameterSchema`.
+type QuerySchema struct {
+	Name         string
+	Default      string
+	Multiplicity uint
+	Required     bool
+}
+
+// Proxy declares a reverse proxy to an upstream server.  The
+// request id is sent upstream in the `RequestIDHeaderName`
+// header of the gateway.
+type Proxy struct {
+	// Upstream is the URL requests are sent to.  Its path, if
+	// any, is prepended to the request path.  Required.
+	Upstream string
+
+	// StripPrefix is optionally removed from the start of the
+	// request path before it is sent upstream.
+	StripPrefix string
+
+	// PreserveHost sends the Host header of the request
+	// upstream instead of the host of `Upstream`.
+	PreserveHost bool
+
+	// Mirror optionally sends a copy of a percentage of
+	// requests to a shadow upstream.
+	Mirror *Mirror
+}
+
+// Mirror declares a `middleware.Mirror` to a shadow upstream.
+// The path of the request is handled as for the primary
+// upstream.
+type Mirror struct {
+	Upstream       string
+	Percentage     float64
+	MaxConcurrency int
+	MaxBodySize    int64
+	Timeout        Duration
+}
+
+// Policy declares a `service.Policy`.  Omitted fields are
+// inherited, false and -1 override an inherited setting or
+// limit.
+type Policy struct {
+	AllowMalformedQueryParameters *bool
+	AllowUnknownQueryParameters   *bool
+	AllowTrailingSlashRedirects   *bool
+	PreserveEscapedPathParameters *bool
+	AllowImplicitHead             *bool
+	RejectMalformedPathParameters *bool
+	TimeBudget                    Duration
+	MaxBodySize                   int64
+	BodyReadTimeout               Duration
+	MinBodyReadRate               int64
+	MaxConcurrency                int
+	MaxQueueDepth                 int
+	QueueTimeout                  Duration
+}
+
+func (p Policy) policy() service.Policy {
+	return service.Policy{
+		AllowMalformedQueryParameters: p.AllowMalformedQueryParameters,
+		AllowUnknownQueryParameters:   p.AllowUnknownQueryParameters,
+		AllowTrailingSlashRedirects:   p.AllowTrailingSlashRedirects,
+		PreserveEscapedPathParameters: p.PreserveEscapedPathParameters,
+		AllowImplicitHead:             p.AllowImplicitHead,
+		RejectMalformedPathParameters: p.RejectMalformedPathParameters,
+		TimeBudget:                    time.Duration(p.TimeBudget),
+		MaxBodySize:                   p.MaxBodySize,
+		BodyReadTimeout:               time.Duration(p.BodyReadTimeout),
+		MinBodyReadRate:               p.MinBodyReadRate,
+		MaxConcurrency:                p.MaxConcurrency,
+		MaxQueueDepth:                 p.MaxQueueDepth,
+		QueueTimeout:                  time.Duration(p.QueueTimeout),
+	}
+}
+
+func (s QuerySchema) schema() httpx.ParameterSchema {
+	return httpx.ParameterSchema{
+		Name:         s.Name,
+		Default:      s.Default,
+		Multiplicity: s.Multiplicity,
+		Required:     s.Required,
+	}
+}
+
+// Duration is a `time.Duration` encoded in JSON as a string,
+// e.g. "1.5s".  A number of nanoseconds is also accepted.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if len(data) != 0 && data[0] != '"' {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return merry.Prepend(err, "config: parse duration")
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return merry.Prepend(err, "config: parse duration")
+	}
+
+	value, err := time.ParseDuration(s)
+	if err != nil {
+		return merry.Prepend(err, "config: parse duration")
+	}
+	*d = Duration(value)
+
+	return nil
+}
+
+// Load reads a configuration from JSON.  Unknown keys are an
+// error.
+func Load(r io.Reader) (*Config, merry.Error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, merry.Prepend(err, "config: load")
+	}
+
+	return &cfg, nil
+}
+
+// LoadFile reads a configuration from a JSON file.
+func LoadFile(path string) (*Config, merry.Error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, merry.Prepend(err, "config: load")
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// decodeOptions decodes the options of a middleware
+// declaration, which may be empty.  Unknown keys are an error.
+func decodeOptions(options json.RawMessage, v interface{}) merry.Error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return merry.Prepend(err, "decode options")
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/accesslog"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	exampleConfig = `{
+  "Gateway": {
+    "Name": "edge",
+    "Addr": ":8080",
+    "GracePeriod": "5s",
+    "ReadTimeout": 1000000,
+    "Listeners": [{"Network": "unix", "Addr": "/tmp/gw.sock"}],
+    "Middleware": [{"Name": "restrict-content-types", "Options": {"Forbidden": ["text/xml"]}}]
+  },
+  "Services": [{
+    "Name": "accounts",
+    "BasePath": "/v2",
+    "Policy": {"TimeBudget": "2s"},
+    "Routes": [{
+      "Route": "/accounts/:id",
+      "Methods": ["GET", "put"],
+      "QuerySchemas": [{"Name": "fields", "Multiplicity": 1}],
+      "Proxy": {"Upstream": "http://accounts.internal:9000"}
+    }],
+    "Groups": [{
+      "Prefix": "/admin",
+      "Middleware": [{"Name": "allow-content-types", "Options": {"Permitted": ["application/json"]}}],
+      "Routes": [{"Route": "/status", "Proxy": {"Upstream": "http://admin.internal"}}]
+    }]
+  }]
+}`
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(strings.NewReader(exampleConfig))
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg)
+
+	assert.Equal(t, "edge", cfg.Gateway.Name)
+	assert.Equal(t, Duration(time.Second*5), cfg.Gateway.GracePeriod)
+	assert.Equal(t, Duration(time.Millisecond), cfg.Gateway.ReadTimeout)
+	assert.Len(t, cfg.Gateway.Listeners, 1)
+	assert.Len(t, cfg.Gateway.Middleware, 1)
+	assert.Len(t, cfg.Services, 1)
+	assert.Equal(t, Duration(time.Second*2), cfg.Services[0].Policy.TimeBudget)
+	assert.Len(t, cfg.Services[0].Routes, 1)
+	assert.Len(t, cfg.Services[0].Groups, 1)
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	cfg, err := Load(strings.NewReader(`{"Gateway": {"Adress": ":8080"}}`))
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestLoadMalformed(t *testing.T) {
+	cfg, err := Load(strings.NewReader(`{"Gateway": `))
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestLoadBadDuration(t *testing.T) {
+	cfg, err := Load(strings.NewReader(`{"Gateway": {"GracePeriod": "5 seconds"}}`))
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+
+	cfg, err = Load(strings.NewReader(`{"Gateway": {"GracePeriod": true}}`))
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	cfg, err := LoadFile("/no/such/gateway.json")
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestDurationMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Duration(time.Millisecond * 1500))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1.5s"`, string(data))
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	var cut Policy
+	data := `{"AllowImplicitHead": false, "AllowTrailingSlashRedirects": true, "TimeBudget": -1, "MaxBodySize": -1}`
+	assert.NoError(t, json.Unmarshal([]byte(data), &cut))
+
+	expected := service.Policy{
+		AllowImplicitHead:           httpx.Bool(false),
+		AllowTrailingSlashRedirects: httpx.Bool(true),
+		TimeBudget:                  httpx.NoLimit,
+		MaxBodySize:                 httpx.NoLimit,
+	}
+	assert.Equal(t, expected, cut.policy())
+}
+
+func TestAccessLogLogger(t *testing.T) {
+	cfg := AccessLog{
+		Path:          "/var/log/gw/access.log",
+		Format:        "json",
+		Fields:        []string{"request_id", "status"},
+		FlushInterval: Duration(time.Second * 5),
+	}
+
+	logger, err := cfg.Logger()
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/log/gw/access.log", logger.Path)
+	assert.Nil(t, logger.Output)
+	assert.Equal(t, accesslog.JSONFormat, logger.Format)
+	assert.Equal(t, []accesslog.Field{accesslog.FieldRequestID, accesslog.FieldStatus}, logger.Fields)
+	assert.Equal(t, time.Second*5, logger.FlushInterval)
+}
+
+func TestAccessLogLoggerStdout(t *testing.T) {
+	logger, err := AccessLog{Path: "-"}.Logger()
+	assert.NoError(t, err)
+	assert.Empty(t, logger.Path)
+	assert.Equal(t, os.Stdout, logger.Output)
+	assert.Equal(t, accesslog.CommonLogFormat, logger.Format)
+	assert.Empty(t, logger.Fields)
+}
+
+func TestAccessLogLoggerErrors(t *testing.T) {
+	_, err := AccessLog{Format: "zalgo"}.Logger()
+	assert.Error(t, err)
+
+	_, err = AccessLog{Fields: []string{"zalgo"}}.Logger()
+	assert.Error(t, err)
+}
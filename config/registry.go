@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/authn"
+	"github.com/shisa-platform/core/contenttype"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/middleware"
+	"github.com/shisa-platform/core/ratelimit"
+)
+
+// MiddlewareFactory creates a handler from the options of a
+// middleware declaration.  The options may be empty.
+type MiddlewareFactory func(options json.RawMessage) (httpx.Handler, merry.Error)
+
+// Registry maps middleware names to the factories that create
+// them.  It is safe for concurrent use.
+type Registry struct {
+	mtx       sync.RWMutex
+	factories map[string]MiddlewareFactory
+}
+
+// NewRegistry returns a registry with the middleware that don't
+// require application dependencies:
+//
+//	allow-content-types     middleware.AllowContentTypes, options {"Permitted": ["application/json"]}
+//	restrict-content-types  middleware.RestrictContentTypes, options {"Forbidden": ["text/xml"]}
+//	csrf                    middleware.CSRFProtector, options {"SiteURL": "https://example.com", "CookieName": "", "TokenLength": 0}
+//
+// Authentication and rate limiting depend on an application's
+// identity and rate limit providers, use `AuthenticationFactory`
+// and `RateLimitFactory` to register them.
+func NewRegistry() *Registry {
+	r := &Registry{
+		factories: make(map[string]MiddlewareFactory),
+	}
+	r.Register("allow-content-types", allowContentTypes)
+	r.Register("restrict-content-types", restrictContentTypes)
+	r.Register("csrf", csrf)
+
+	return r
+}
+
+// Register adds a factory for the given name, replacing any
+// existing one.
+func (r *Registry) Register(name string, factory MiddlewareFactory) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.factories == nil {
+		r.factories = make(map[string]MiddlewareFactory)
+	}
+	r.factories[name] = factory
+}
+
+// Build creates the handler for a middleware declaration.
+func (r *Registry) Build(m Middleware) (httpx.Handler, merry.Error) {
+	r.mtx.RLock()
+	factory, ok := r.factories[m.Name]
+	r.mtx.RUnlock()
+
+	if !ok {
+		return nil, merry.New("config: build middleware: unknown name").Append(m.Name)
+	}
+
+	handler, err := factory(m.Options)
+	if err != nil {
+		return nil, err.Prepend("config: build middleware").Append(m.Name)
+	}
+	if handler == nil {
+		return nil, merry.New("config: build middleware: result is nil").Append(m.Name)
+	}
+
+	return handler, nil
+}
+
+func (r *Registry) buildAll(ms []Middleware) ([]httpx.Handler, merry.Error) {
+	var handlers []httpx.Handler
+	for _, m := range ms {
+		handler, err := r.Build(m)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, handler)
+	}
+
+	return handlers, nil
+}
+
+// AuthenticationFactory returns a factory for authentication
+// middleware using the given authenticator.  If the "Passive"
+// option is true unauthenticated requests are allowed through,
+// e.g. `{"Passive": true}`.
+func AuthenticationFactory(authenticator authn.Authenticator) MiddlewareFactory {
+	return func(raw json.RawMessage) (httpx.Handler, merry.Error) {
+		var options struct {
+			Passive bool
+		}
+		if err := decodeOptions(raw, &options); err != nil {
+			return nil, err
+		}
+
+		if options.Passive {
+			return (&middleware.PassiveAuthentication{Authenticator: authenticator}).Service, nil
+		}
+
+		return (&middleware.Authentication{Authenticator: authenticator}).Service, nil
+	}
+}
+
+// RateLimitFactory returns a factory for rate limiting
+// middleware using the given provider.  The "By" option selects
+// whether requests are throttled by "client" IP address, the
+// default, or the "user" of the context, e.g. `{"By": "user"}`.
+func RateLimitFactory(provider ratelimit.Provider) MiddlewareFactory {
+	return func(raw json.RawMessage) (httpx.Handler, merry.Error) {
+		var options struct {
+			By string
+		}
+		if err := decodeOptions(raw, &options); err != nil {
+			return nil, err
+		}
+
+		var (
+			limiter *middleware.RateLimiter
+			err     merry.Error
+		)
+		switch options.By {
+		case "", "client":
+			limiter, err = middleware.NewClientLimiter(provider)
+		case "user":
+			limiter, err = middleware.NewUserLimiter(provider)
+		default:
+			return nil, merry.New("check invariants: unknown rate limit key").Append(options.By)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return limiter.Service, nil
+	}
+}
+
+func allowContentTypes(raw json.RawMessage) (httpx.Handler, merry.Error) {
+	var options struct {
+		Permitted []string
+	}
+	if err := decodeOptions(raw, &options); err != nil {
+		return nil, err
+	}
+
+	types, err := parseContentTypes(options.Permitted)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&middleware.AllowContentTypes{Permitted: types}).Service, nil
+}
+
+func restrictContentTypes(raw json.RawMessage) (httpx.Handler, merry.Error) {
+	var options struct {
+		Forbidden []string
+	}
+	if err := decodeOptions(raw, &options); err != nil {
+		return nil, err
+	}
+
+	types, err := parseContentTypes(options.Forbidden)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&middleware.RestrictContentTypes{Forbidden: types}).Service, nil
+}
+
+func parseContentTypes(values []string) ([]contenttype.ContentType, merry.Error) {
+	if len(values) == 0 {
+		return nil, merry.New("check invariants: content types empty")
+	}
+
+	types := make([]contenttype.ContentType, len(values))
+	for i, value := range values {
+		ct, err := contenttype.Parse(value)
+		if err != nil {
+			return nil, merry.Prepend(err, "parse content type").Append(value)
+		}
+		types[i] = *ct
+	}
+
+	return types, nil
+}
+
+func csrf(raw json.RawMessage) (httpx.Handler, merry.Error) {
+	var options struct {
+		SiteURL     string
+		CookieName  string
+		TokenLength int
+	}
+	if err := decodeOptions(raw, &options); err != nil {
+		return nil, err
+	}
+
+	site, err := url.Parse(options.SiteURL)
+	if err != nil {
+		return nil, merry.Prepend(err, "parse site url")
+	}
+	if site.Scheme == "" || site.Host == "" {
+		return nil, merry.New("check invariants: site url requires scheme and host").Append(options.SiteURL)
+	}
+
+	m := &middleware.CSRFProtector{
+		SiteURL:     site,
+		CookieName:  options.CookieName,
+		TokenLength: options.TokenLength,
+	}
+
+	return m.Service, nil
+}
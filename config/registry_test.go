@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/authn"
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/ratelimit"
+)
+
+func TestRegistryUnknown(t *testing.T) {
+	cut := NewRegistry()
+
+	handler, err := cut.Build(Middleware{Name: "lolwut"})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestRegistryRegister(t *testing.T) {
+	cut := new(Registry)
+
+	var options json.RawMessage
+	cut.Register("custom", func(raw json.RawMessage) (httpx.Handler, merry.Error) {
+		options = raw
+		return func(context.Context, *httpx.Request) httpx.Response { return nil }, nil
+	})
+
+	handler, err := cut.Build(Middleware{Name: "custom", Options: json.RawMessage(`{"a": 1}`)})
+	assert.NoError(t, err)
+	assert.NotNil(t, handler)
+	assert.Equal(t, `{"a": 1}`, string(options))
+}
+
+func TestRegistryFactoryError(t *testing.T) {
+	cut := new(Registry)
+	cut.Register("broken", func(json.RawMessage) (httpx.Handler, merry.Error) {
+		return nil, merry.New("i blewed up!")
+	})
+	cut.Register("nil", func(json.RawMessage) (httpx.Handler, merry.Error) {
+		return nil, nil
+	})
+
+	handler, err := cut.Build(Middleware{Name: "broken"})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "nil"})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestRegistryContentTypes(t *testing.T) {
+	cut := NewRegistry()
+
+	for _, m := range []Middleware{
+		{Name: "allow-content-types", Options: json.RawMessage(`{"Permitted": ["application/json"]}`)},
+		{Name: "restrict-content-types", Options: json.RawMessage(`{"Forbidden": ["text/xml"]}`)},
+	} {
+		handler, err := cut.Build(m)
+		assert.NoError(t, err, m.Name)
+		assert.NotNil(t, handler, m.Name)
+	}
+
+	for _, m := range []Middleware{
+		{Name: "allow-content-types"},
+		{Name: "allow-content-types", Options: json.RawMessage(`{"Permitted": ["json"]}`)},
+		{Name: "restrict-content-types", Options: json.RawMessage(`{"Forbidden": []}`)},
+		{Name: "restrict-content-types", Options: json.RawMessage(`{"Allowed": ["text/xml"]}`)},
+	} {
+		handler, err := cut.Build(m)
+		assert.Error(t, err, string(m.Options))
+		assert.Nil(t, handler, string(m.Options))
+	}
+}
+
+func TestRegistryCSRF(t *testing.T) {
+	cut := NewRegistry()
+
+	handler, err := cut.Build(Middleware{Name: "csrf", Options: json.RawMessage(`{"SiteURL": "https://example.com"}`)})
+	assert.NoError(t, err)
+	assert.NotNil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "csrf", Options: json.RawMessage(`{"SiteURL": "example.com"}`)})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "csrf", Options: json.RawMessage(`{"SiteURL": "%"}`)})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestAuthenticationFactory(t *testing.T) {
+	cut := NewRegistry()
+	cut.Register("authn", AuthenticationFactory(authn.NewFakeAuthenticatorDefaultFatal(t)))
+
+	handler, err := cut.Build(Middleware{Name: "authn"})
+	assert.NoError(t, err)
+	assert.NotNil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "authn", Options: json.RawMessage(`{"Passive": true}`)})
+	assert.NoError(t, err)
+	assert.NotNil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "authn", Options: json.RawMessage(`{"Passive": "yes"}`)})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestRateLimitFactory(t *testing.T) {
+	cut := NewRegistry()
+	cut.Register("ratelimit", RateLimitFactory(ratelimit.NewFakeProviderDefaultFatal(t)))
+	cut.Register("broken", RateLimitFactory(nil))
+
+	for _, options := range []string{``, `{"By": "client"}`, `{"By": "user"}`} {
+		handler, err := cut.Build(Middleware{Name: "ratelimit", Options: json.RawMessage(options)})
+		assert.NoError(t, err, options)
+		assert.NotNil(t, handler, options)
+	}
+
+	handler, err := cut.Build(Middleware{Name: "ratelimit", Options: json.RawMessage(`{"By": "lolwut"}`)})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+
+	handler, err = cut.Build(Middleware{Name: "broken"})
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
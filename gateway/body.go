@@ -0,0 +1,263 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	bodyChunkSize     = 32 * 1024
+	bodyTooLargeError = "gateway: route: request body too large"
+)
+
+var (
+	bodyRateInterval = time.Second
+)
+
+func newBodyTooLargeError() merry.Error {
+	return merry.New(bodyTooLargeError).WithHTTPCode(http.StatusRequestEntityTooLarge)
+}
+
+func newRequestAbortedError() merry.Error {
+	return merry.New("gateway: route: request aborted")
+}
+
+// limitedBody enforces the body limits of a policy while the
+// handlers read the body.  Reads fail once more than the maximum
+// number of bytes have been read, the read timeout has expired
+// or the average rate has dropped below the minimum.  The
+// failure is recorded so the response can be replaced even if
+// the handlers ignore the error.
+//
+// If there is a deadline or minimum rate the underlying body is
+// read by a background goroutine, started by the first read and
+// stopped when the request is done, so a stalled client can't
+// block the handlers.
+type limitedBody struct {
+	read int64 // first for 64-bit alignment
+	io.ReadCloser
+	remaining int64 // negative if the size is unlimited
+	deadline  time.Time
+	minRate   int64
+	start     time.Time
+	done      <-chan struct{}
+	failed    atomic.Value
+	chunk     []byte
+	requests  chan int
+	results   chan bodyRead
+	timer     *time.Timer
+	ticker    *time.Ticker
+}
+
+type bodyRead struct {
+	n   int
+	err error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if err := b.failure(); err != nil {
+		return 0, err
+	}
+
+	if b.deadline.IsZero() && b.minRate == 0 {
+		return b.readLimited(p)
+	}
+
+	if err := b.checkRate(time.Now()); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-b.done:
+		return 0, b.fail(newRequestAbortedError())
+	default:
+	}
+
+	if b.results == nil {
+		b.startReader()
+	}
+
+	// data is read into a private chunk because the read may
+	// finish after the handlers have moved on
+	size := len(p)
+	if size > len(b.chunk) {
+		size = len(b.chunk)
+	}
+	b.requests <- size
+
+	var expired, tick <-chan time.Time
+	if b.timer != nil {
+		expired = b.timer.C
+	}
+	if b.ticker != nil {
+		tick = b.ticker.C
+	}
+
+	for {
+		select {
+		case result := <-b.results:
+			return copy(p, b.chunk[:result.n]), result.err
+		case <-expired:
+			return 0, b.fail(merry.New("gateway: route: request body read timeout").WithHTTPCode(http.StatusRequestTimeout))
+		case now := <-tick:
+			if err := b.checkRate(now); err != nil {
+				return 0, err
+			}
+		case <-b.done:
+			return 0, b.fail(newRequestAbortedError())
+		}
+	}
+}
+
+// startReader starts the background reader along with the
+// deadline and rate checks shared by all reads of the body.
+func (b *limitedBody) startReader() {
+	b.chunk = make([]byte, bodyChunkSize)
+	b.requests = make(chan int, 1)
+	b.results = make(chan bodyRead, 1)
+	if !b.deadline.IsZero() {
+		b.timer = time.NewTimer(time.Until(b.deadline))
+	}
+	if b.minRate != 0 {
+		b.ticker = time.NewTicker(bodyRateInterval)
+	}
+
+	go b.reader()
+}
+
+// reader services reads until the request is done.  A read that
+// is still blocked then finishes once the body is closed.
+func (b *limitedBody) reader() {
+	defer func() {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		if b.ticker != nil {
+			b.ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case size := <-b.requests:
+			n, err := b.readLimited(b.chunk[:size])
+			b.results <- bodyRead{n: n, err: err}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// readLimited reads from the underlying body, failing if the
+// maximum size is exceeded.
+func (b *limitedBody) readLimited(p []byte) (int, error) {
+	if b.remaining < 0 {
+		n, err := b.ReadCloser.Read(p)
+		atomic.AddInt64(&b.read, int64(n))
+		return n, err
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		atomic.AddInt64(&b.read, int64(n))
+		return n, err
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+	atomic.AddInt64(&b.read, int64(n))
+
+	return n, b.fail(newBodyTooLargeError())
+}
+
+// checkRate fails if the average rate the body has been read at
+// is below the minimum.  The rate is first checked once the body
+// has been read for one interval.
+func (b *limitedBody) checkRate(now time.Time) merry.Error {
+	if b.minRate == 0 {
+		return nil
+	}
+
+	elapsed := now.Sub(b.start)
+	if elapsed < bodyRateInterval {
+		return nil
+	}
+
+	if float64(atomic.LoadInt64(&b.read))/elapsed.Seconds() < float64(b.minRate) {
+		return b.fail(merry.New("gateway: route: request body read too slowly").WithHTTPCode(http.StatusRequestTimeout))
+	}
+
+	return nil
+}
+
+// fail records the first failure reading the body and returns
+// it.
+func (b *limitedBody) fail(err merry.Error) merry.Error {
+	if existing := b.failure(); existing != nil {
+		return existing
+	}
+	b.failed.Store(err)
+
+	return err
+}
+
+// failure returns the error that ended reading the body, if
+// any.  It is safe to call while a handler is still reading.
+func (b *limitedBody) failure() merry.Error {
+	if err, ok := b.failed.Load().(merry.Error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// limitBody applies the body limits of a policy to a request.
+// Requests that declare a body larger than the limit are
+// rejected outright, otherwise the body is wrapped so the limits
+// are enforced as the handlers read it and the returned body
+// records any failure.
+func limitBody(ctx context.Context, request *httpx.Request, policy service.Policy) (*limitedBody, merry.Error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if policy.MaxBodySize > 0 && request.ContentLength > policy.MaxBodySize {
+		return nil, newBodyTooLargeError()
+	}
+
+	if policy.MaxBodySize <= 0 && policy.BodyReadTimeout <= 0 && policy.MinBodyReadRate <= 0 {
+		return nil, nil
+	}
+
+	body := &limitedBody{
+		ReadCloser: request.Body,
+		remaining:  -1,
+		start:      time.Now(),
+		done:       ctx.Done(),
+	}
+	if policy.MaxBodySize > 0 {
+		body.remaining = policy.MaxBodySize
+	}
+	if policy.MinBodyReadRate > 0 {
+		body.minRate = policy.MinBodyReadRate
+	}
+	if policy.BodyReadTimeout > 0 {
+		body.deadline = body.start.Add(policy.BodyReadTimeout)
+	}
+	request.Body = body
+
+	return body, nil
+}
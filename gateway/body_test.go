@@ -0,0 +1,380 @@
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+// streamingBody hides the length of a body so the request is
+// treated as chunked
+type streamingBody struct {
+	io.Reader
+}
+
+func TestLimitedBody(t *testing.T) {
+	cut := &limitedBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("0123456789")),
+		remaining:  10,
+	}
+
+	data, err := ioutil.ReadAll(cut)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+	assert.Nil(t, cut.failure())
+}
+
+func TestLimitedBodyTooLarge(t *testing.T) {
+	cut := &limitedBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("0123456789")),
+		remaining:  4,
+	}
+
+	data, err := ioutil.ReadAll(cut)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, merry.HTTPCode(err))
+	assert.Equal(t, "0123", string(data))
+	if assert.NotNil(t, cut.failure()) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, merry.HTTPCode(cut.failure()))
+	}
+
+	n, err := cut.Read(make([]byte, 4))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestLimitedBodyChecksShared(t *testing.T) {
+	done := make(chan struct{})
+	cut := &limitedBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("0123456789")),
+		remaining:  -1,
+		deadline:   time.Now().Add(time.Minute),
+		minRate:    1,
+		start:      time.Now(),
+		done:       done,
+	}
+
+	buf := make([]byte, 2)
+	n, err := cut.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "01", string(buf[:n]))
+
+	timer, ticker, results := cut.timer, cut.ticker, cut.results
+	assert.NotNil(t, timer)
+	assert.NotNil(t, ticker)
+
+	data, err := ioutil.ReadAll(cut)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456789", string(data))
+	assert.True(t, timer == cut.timer, "timer replaced")
+	assert.True(t, ticker == cut.ticker, "ticker replaced")
+	assert.True(t, results == cut.results, "reader restarted")
+	assert.Nil(t, cut.failure())
+
+	close(done)
+	_, err = cut.Read(buf)
+	assert.Error(t, err)
+	assert.NotNil(t, cut.failure())
+}
+
+func TestRouterBodyContentLengthTooLarge(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MaxBodySize: 4}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, strings.NewReader("0123456789"))
+	cut.ServeHTTP(w, request)
+
+	assert.False(t, handlerCalled, "handler called")
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRouterBodyStreamTooLarge(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var readErr error
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		_, readErr = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MaxBodySize: 4}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, streamingBody{strings.NewReader("0123456789")})
+	cut.ServeHTTP(w, request)
+
+	assert.Error(t, readErr)
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRouterBodyLimitRemovedByEndpoint(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var body []byte
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		body, _ = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MaxBodySize: httpx.NoLimit}
+	svc := newFakeService([]service.Endpoint{service.PostEndpointWithPolicy(expectedRoute, policy, handler)})
+	svc.Policy = service.Policy{MaxBodySize: 4}
+	installService(t, cut, svc)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, strings.NewReader("0123456789"))
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", string(body))
+}
+
+func TestRouterBodyWithinLimit(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var body []byte
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		body, _ = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MaxBodySize: 10}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, streamingBody{strings.NewReader("0123456789")})
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", string(body))
+}
+
+func TestRouterBodyStreamedWithTimeout(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var (
+		body   []byte
+		length int64
+	)
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		length = r.ContentLength
+		body, _ = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{BodyReadTimeout: time.Second}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, streamingBody{strings.NewReader("0123456789")})
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", string(body))
+	assert.Equal(t, int64(-1), length)
+}
+
+func TestRouterBodyStreamedBeforeComplete(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	body, writer := io.Pipe()
+	go writer.Write([]byte("0123"))
+
+	var first, rest []byte
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		// the start of the body is available before the client
+		// has finished sending it
+		first = make([]byte, 4)
+		n, _ := io.ReadFull(r.Body, first)
+		first = first[:n]
+		go func() {
+			writer.Write([]byte("456789"))
+			writer.Close()
+		}()
+		rest, _ = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{BodyReadTimeout: time.Second, MinBodyReadRate: 1}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, body)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123", string(first))
+	assert.Equal(t, "456789", string(rest))
+}
+
+func TestRouterBodyStreamedTooLarge(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var readErr error
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		_, readErr = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MaxBodySize: 4, BodyReadTimeout: time.Second}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, streamingBody{strings.NewReader("0123456789")})
+	cut.ServeHTTP(w, request)
+
+	assert.Error(t, readErr)
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRouterBodyReadTimeout(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var readErr error
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		_, readErr = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{BodyReadTimeout: time.Millisecond * 5}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, body)
+	cut.ServeHTTP(w, request)
+
+	assert.Error(t, readErr)
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+func TestRouterBodyReadTimeoutIgnoredByHandler(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		r.Body.Read(make([]byte, 4))
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{BodyReadTimeout: time.Millisecond * 5}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, body)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+func TestRouterBodyReadTooSlowly(t *testing.T) {
+	interval := bodyRateInterval
+	bodyRateInterval = time.Millisecond * 10
+	defer func() { bodyRateInterval = interval }()
+
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var readErr error
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		_, readErr = ioutil.ReadAll(r.Body)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{MinBodyReadRate: 1024 * 1024}
+	endpoint := service.PostEndpointWithPolicy(expectedRoute, policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write([]byte("0"))
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, expectedRoute, body)
+	cut.ServeHTTP(w, request)
+
+	assert.Error(t, readErr)
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"net/url"
+	"regexp"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+// constraint validates the values of a path parameter.
+// The expression is either the name of a builtin constraint,
+// e.g. `:id{uint}`, or a regular expression that must match the
+// entire value, e.g. `:name{[a-z0-9-]+}`.
+type constraint struct {
+	name  string
+	expr  string
+	match func(string) bool
+}
+
+func newConstraint(name, expr string) (*constraint, merry.Error) {
+	if expr == "" {
+		return nil, merry.New("wildcard constraint must not be empty")
+	}
+
+	if builtin, ok := service.BuiltinConstraints[expr]; ok {
+		return &constraint{name: name, expr: expr, match: builtin.Match}, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, merry.Prepend(err, "wildcard constraint")
+	}
+
+	return &constraint{name: name, expr: expr, match: re.MatchString}, nil
+}
+
+func (c *constraint) check(value string) merry.Error {
+	if unescaped, err := url.PathUnescape(value); err == nil && c.match(unescaped) {
+		return nil
+	}
+
+	return httpx.MalformedPathParameter.Appendf("%q does not match %q", value, c.expr)
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/httpx"
+)
+
+func TestConstraintBuiltins(t *testing.T) {
+	tests := []struct {
+		expr    string
+		valid   []string
+		invalid []string
+	}{
+		{"int", []string{"0", "-42", "42"}, []string{"", "4.2", "x"}},
+		{"uint", []string{"0", "42"}, []string{"", "-42", "x"}},
+		{"alpha", []string{"abc", "ABC"}, []string{"", "a1", "a-b"}},
+		{"alnum", []string{"abc", "a1"}, []string{"", "a-b", "a%20b"}},
+		{"uuid", []string{"6ba7b810-9dad-11d1-80b4-00c04fd430c8"}, []string{"", "6ba7b810"}},
+	}
+
+	for _, test := range tests {
+		cut, err := newConstraint("param", test.expr)
+		assert.NoError(t, err)
+		assert.NotNil(t, cut)
+		for _, value := range test.valid {
+			assert.NoError(t, cut.check(value), "%s: %q", test.expr, value)
+		}
+		for _, value := range test.invalid {
+			err := cut.check(value)
+			assert.Error(t, err, "%s: %q", test.expr, value)
+			assert.True(t, merry.Is(err, httpx.MalformedPathParameter))
+		}
+	}
+}
+
+func TestConstraintRegexp(t *testing.T) {
+	cut, err := newConstraint("name", "[a-z ]+")
+	assert.NoError(t, err)
+	assert.NotNil(t, cut)
+
+	assert.NoError(t, cut.check("hello"))
+	assert.NoError(t, cut.check("hello%20world"))
+	assert.Error(t, cut.check("hello-world"))
+	assert.Error(t, cut.check("xhellox1"))
+	assert.Error(t, cut.check("hello%zz"))
+}
+
+func TestConstraintBadExpression(t *testing.T) {
+	cut, err := newConstraint("name", "")
+	assert.Error(t, err)
+	assert.Nil(t, cut)
+
+	cut, err = newConstraint("name", "[a-z")
+	assert.Error(t, err)
+	assert.Nil(t, cut)
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"expvar"
+	"sync/atomic"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/auxiliary"
+	"github.com/shisa-platform/core/context"
+)
+
+// Ready returns true if the gateway is serving and hasn't begun
+// to shut down.
+func (g *Gateway) Ready() bool {
+	return atomic.LoadUint32(&g.ready) == 1
+}
+
+// InFlight returns the number of requests currently being
+// serviced.  During `Shutdown` this is the number of requests
+// remaining to be drained.
+func (g *Gateway) InFlight() int64 {
+	if v, ok := gatewayExpvar.Get("in_flight").(*expvar.Int); ok {
+		return v.Value()
+	}
+
+	return 0
+}
+
+// Readiness returns a healthchecker that fails when the gateway
+// isn't ready to accept requests.  Add it to the `Checkers` of
+// an `auxiliary.HealthcheckServer` used by load balancers so
+// that they stop sending requests as soon as `Shutdown` begins.
+func (g *Gateway) Readiness() auxiliary.Healthchecker {
+	return readiness{g}
+}
+
+type readiness struct {
+	gateway *Gateway
+}
+
+func (r readiness) Name() string {
+	return r.gateway.Name
+}
+
+func (r readiness) Healthcheck(context.Context) merry.Error {
+	if r.gateway.Ready() {
+		return nil
+	}
+
+	return merry.New("gateway: not ready").WithUserMessage("not ready")
+}
+
+func (g *Gateway) setReady(ready bool) {
+	var value uint32
+	if ready {
+		value = 1
+	}
+	atomic.StoreUint32(&g.ready, value)
+}
+
+// withdraw marks the gateway as not ready, then removes the
+// healthcheck and registration of the gateway if they were
+// added.  It is safe to call more than once, later calls
+// return the result of the first.
+func (g *Gateway) withdraw() merry.Error {
+	g.setReady(false)
+
+	g.drainMtx.Lock()
+	defer g.drainMtx.Unlock()
+
+	if g.withdrawn {
+		return g.withdrawErr
+	}
+	g.withdrawn = true
+
+	if g.checked {
+		if err := g.removeHealthcheckSafely(); err != nil {
+			g.withdrawErr = err.Prepend("gateway: serve: remove healthcheck")
+		}
+	}
+	if g.registered {
+		if err := g.deregisterSafely(); err != nil && g.withdrawErr == nil {
+			g.withdrawErr = err.Prepend("gateway: serve: deregister gateway")
+		}
+	}
+
+	return g.withdrawErr
+}
@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/sd"
+	"github.com/shisa-platform/core/service"
+)
+
+func TestGatewayReadinessNotStarted(t *testing.T) {
+	cut := &Gateway{Name: "test"}
+
+	assert.False(t, cut.Ready())
+
+	check := cut.Readiness()
+	assert.Equal(t, "test", check.Name())
+	assert.Error(t, check.Healthcheck(nil))
+}
+
+func TestGatewayInFlight(t *testing.T) {
+	cut := &Gateway{}
+	cut.init()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		close(started)
+		<-release
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installHandler(t, cut, handler)
+
+	assert.Equal(t, int64(0), cut.InFlight())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+		wg.Done()
+	}()
+
+	<-started
+	assert.Equal(t, int64(1), cut.InFlight())
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(0), cut.InFlight())
+}
+
+func TestGatewayShutdownDrainSequence(t *testing.T) {
+	var mtx sync.Mutex
+	var events []string
+	record := func(event string) {
+		mtx.Lock()
+		events = append(events, event)
+		mtx.Unlock()
+	}
+
+	registrar := &sd.FakeRegistrar{
+		RegisterHook: func(string, *url.URL) merry.Error {
+			return nil
+		},
+		DeregisterHook: func(string) merry.Error {
+			record("deregister")
+			return nil
+		},
+		AddCheckHook: func(string, *url.URL) merry.Error {
+			return nil
+		},
+		RemoveChecksHook: func(string) merry.Error {
+			record("remove checks")
+			return nil
+		},
+	}
+	cut := &Gateway{
+		Name:                "test",
+		Addr:                "127.0.0.1:0",
+		DrainDelay:          100 * time.Millisecond,
+		Registrar:           registrar,
+		RegistrationURLHook: func() (u *url.URL, err merry.Error) { return },
+		CheckURLHook:        func() (u *url.URL, err merry.Error) { return },
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.Serve(svc)
+		assert.NoError(t, err)
+		record("stopped")
+		wg.Done()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, cut.Ready())
+	assert.NoError(t, cut.Readiness().Healthcheck(nil))
+
+	go cut.Shutdown()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.False(t, cut.Ready())
+	assert.Error(t, cut.Readiness().Healthcheck(nil))
+
+	// requests are still serviced during the drain delay
+	response, err := http.Get("http://" + cut.Address() + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+	record("request")
+
+	wg.Wait()
+
+	assert.Equal(t, []string{"remove checks", "deregister", "request", "stopped"}, events)
+	registrar.AssertDeregisterCalledOnce(t)
+	registrar.AssertRemoveChecksCalledOnce(t)
+}
@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/ansel1/merry"
 
@@ -13,15 +14,96 @@ import (
 type endpoint struct {
 	service.Endpoint
 	serviceName       string
+	allowed           string
 	badQueryHandler   httpx.Handler
 	notAllowedHandler httpx.Handler
 	redirectHandler   httpx.Handler
+	overloadedHandler httpx.Handler
 	iseHandler        httpx.ErrorHandler
+	limiters          map[*service.Pipeline]*limiter
+}
+
+// allowedMethods returns the value of the `Allow` header for
+// the endpoint.  OPTIONS is always included because the gateway
+// answers it when the endpoint doesn't have a pipeline for it.
+func (e endpoint) allowedMethods() string {
+	methods := make([]string, 0, 9)
+	if e.Head != nil || e.implicitHead() {
+		methods = append(methods, http.MethodHead)
+	}
+	if e.Get != nil {
+		methods = append(methods, http.MethodGet)
+	}
+	if e.Put != nil {
+		methods = append(methods, http.MethodPut)
+	}
+	if e.Post != nil {
+		methods = append(methods, http.MethodPost)
+	}
+	if e.Patch != nil {
+		methods = append(methods, http.MethodPatch)
+	}
+	if e.Delete != nil {
+		methods = append(methods, http.MethodDelete)
+	}
+	if e.Connect != nil {
+		methods = append(methods, http.MethodConnect)
+	}
+	methods = append(methods, http.MethodOptions)
+	if e.Trace != nil {
+		methods = append(methods, http.MethodTrace)
+	}
+
+	return strings.Join(methods, ", ")
+}
+
+// route returns the description of the endpoint.
+func (e endpoint) route(hosts []string) service.Route {
+	route := service.Route{
+		Service: e.serviceName,
+		Route:   e.Route,
+		Hosts:   hosts,
+	}
+
+	pipelines := []struct {
+		method   string
+		pipeline *service.Pipeline
+	}{
+		{http.MethodHead, e.Head},
+		{http.MethodGet, e.Get},
+		{http.MethodPut, e.Put},
+		{http.MethodPost, e.Post},
+		{http.MethodPatch, e.Patch},
+		{http.MethodDelete, e.Delete},
+		{http.MethodConnect, e.Connect},
+		{http.MethodOptions, e.Options},
+		{http.MethodTrace, e.Trace},
+	}
+	for _, p := range pipelines {
+		if p.pipeline == nil {
+			continue
+		}
+		route.Methods = append(route.Methods, service.RouteMethod{
+			Method:       p.method,
+			Policy:       p.pipeline.Policy,
+			QuerySchemas: p.pipeline.QuerySchemas,
+		})
+	}
+
+	return route
+}
+
+// implicitHead returns true if HEAD requests should be
+// serviced by the GET pipeline.
+func (e endpoint) implicitHead() bool {
+	return e.Head == nil && e.Get != nil && httpx.IsTrue(e.Get.Policy.AllowImplicitHead)
 }
 
 func (e endpoint) handleNotAllowed(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
 	if e.notAllowedHandler == nil {
-		return httpx.NewEmpty(http.StatusMethodNotAllowed), nil
+		response := httpx.NewEmpty(http.StatusMethodNotAllowed)
+		response.Headers().Set(httpx.AllowHeaderKey, e.allowed)
+		return response, nil
 	}
 
 	response, exception := e.notAllowedHandler.InvokeSafely(ctx, request)
@@ -30,9 +112,20 @@ func (e endpoint) handleNotAllowed(ctx context.Context, request *httpx.Request)
 		response = httpx.NewEmpty(http.StatusMethodNotAllowed)
 	}
 
+	if response != nil && response.StatusCode() == http.StatusMethodNotAllowed && response.Headers().Get(httpx.AllowHeaderKey) == "" {
+		response.Headers().Set(httpx.AllowHeaderKey, e.allowed)
+	}
+
 	return response, exception
 }
 
+func (e endpoint) handleOptions(ctx context.Context, request *httpx.Request) httpx.Response {
+	response := httpx.NewEmpty(http.StatusOK)
+	response.Headers().Set(httpx.AllowHeaderKey, e.allowed)
+
+	return response
+}
+
 func (e endpoint) handleRedirect(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
 	if e.redirectHandler == nil {
 		return redirect(ctx, request), nil
@@ -76,6 +169,20 @@ func (e endpoint) handleBadQuery(ctx context.Context, request *httpx.Request) (h
 	return response, exception
 }
 
+func (e endpoint) handleOverloaded(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
+	if e.overloadedHandler == nil {
+		return httpx.NewEmpty(http.StatusServiceUnavailable), nil
+	}
+
+	response, exception := e.overloadedHandler.InvokeSafely(ctx, request)
+	if exception != nil {
+		exception = exception.Prepend("gateway: route: run OverloadedHandler")
+		response = httpx.NewEmpty(http.StatusServiceUnavailable)
+	}
+
+	return response, exception
+}
+
 func (e endpoint) handleError(ctx context.Context, request *httpx.Request, err merry.Error) (httpx.Response, merry.Error) {
 	if e.iseHandler == nil {
 		return httpx.NewEmptyError(merry.HTTPCode(err), err), nil
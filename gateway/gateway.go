@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -18,13 +20,16 @@ import (
 	"github.com/shisa-platform/core/auxiliary"
 	"github.com/shisa-platform/core/errorx"
 	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/metrics"
 	"github.com/shisa-platform/core/sd"
+	"github.com/shisa-platform/core/service"
 )
 
 const (
 	defaultName                    = "gateway"
 	defaultRequestIDResponseHeader = "X-Request-ID"
 	timeFormat                     = "2006-01-02T15:04:05+00:00"
+	gatewayMetricsName             = "shisa_gateway"
 )
 
 var (
@@ -49,6 +54,27 @@ type Gateway struct {
 	GracePeriod      time.Duration // Timeout for graceful shutdown of open connections
 	TLSConfig        *tls.Config   // optional TLS config, used by ServeTLS
 
+	// CertificateLoader optionally provides the certificate
+	// used by ServeTLS, replacing any in `TLSConfig`.  The
+	// certificate is reloaded while the gateway is running when
+	// the files change or on SIGHUP.  Reload failures keep the
+	// current certificate and are passed to `ErrorHook` if the
+	// loader doesn't have its own.
+	CertificateLoader *httpx.CertificateLoader
+
+	// DrainDelay is the time `Shutdown` waits after the gateway
+	// is marked not ready and its registration is removed
+	// before open connections are drained.  This gives load
+	// balancers and service discovery clients time to stop
+	// sending requests to the gateway.
+	// If zero draining starts immediately.
+	DrainDelay time.Duration
+
+	// Listeners are optional addresses to serve in addition to
+	// `Addr`, e.g. a TLS port or a Unix domain socket.  Each has
+	// its own TLS configuration independent of `TLSConfig`.
+	Listeners []Listener
+
 	// ReadTimeout is the maximum duration for reading the entire
 	// request, including the body.
 	//
@@ -95,10 +121,19 @@ type Gateway struct {
 	TLSNextProto map[string]func(*http.Server, *tls.Conn, http.Handler)
 
 	// RequestIDHeaderName optionally customizes the name of the
-	// response header for the request id.
+	// response header for the request id, and of the request
+	// header checked by `RequestIDValidator`.
 	// If empty "X-Request-Id" will be used.
 	RequestIDHeaderName string
 
+	// RequestIDValidator optionally enables accepting a request
+	// id sent in the `RequestIDHeaderName` header of the
+	// request, e.g. by a load balancer in front of the gateway.
+	// The id is used if the validator returns true, otherwise a
+	// new id is generated.
+	// If nil inbound request ids are ignored.
+	RequestIDValidator httpx.RequestIDValidator
+
 	// RequestIDGenerator optionally customizes how request ids
 	// are generated.
 	// If nil then `httpx.Request.GenerateID` will be used.
@@ -114,6 +149,62 @@ type Gateway struct {
 	// If the timeout is exceeded the entire request is aborted.
 	HandlersTimeout time.Duration
 
+	// ResponseHandlers define handlers to run on all responses
+	// before they are sent to the user agent, after any service
+	// and endpoint response handlers.
+	// Example uses would be adding headers or recording
+	// response sizes.
+	ResponseHandlers []httpx.ResponseHandler
+
+	// MaxConcurrency is the maximum number of requests the
+	// gateway will service concurrently.  Limits for individual
+	// pipelines are set with `service.Policy`.
+	// If zero concurrency is unlimited.
+	MaxConcurrency int
+
+	// MaxQueueDepth is the maximum number of requests that will
+	// wait for the gateway once `MaxConcurrency` is reached.
+	// Requests beyond this are rejected immediately.
+	// If negative the queue is unbounded.
+	MaxQueueDepth int
+
+	// QueueTimeout is the maximum time a request will wait in
+	// the queue before being rejected.
+	// If zero requests wait until they are serviced or aborted.
+	QueueTimeout time.Duration
+
+	// Maintenance puts the whole gateway into maintenance mode
+	// when it starts.  Use `SetMaintenance` and
+	// `SetServiceMaintenance` to change maintenance mode while
+	// the gateway is running.  Requests in maintenance are
+	// answered by `MaintenanceHandler` after the gateway
+	// handlers run and before any service pipeline.
+	Maintenance bool
+
+	// MaintenanceRetryAfter is the value of the "Retry-After"
+	// header of the default maintenance response.
+	// If zero one minute is used.
+	MaintenanceRetryAfter time.Duration
+
+	// MaintenanceAllowedClients are the IP addresses or CIDR
+	// blocks of user agents that are serviced while in
+	// maintenance mode.  The address of a user agent is taken
+	// from the `RemoteAddr` field of the request, not from
+	// forwarding headers which may be spoofed.
+	MaintenanceAllowedClients []string
+
+	// MaintenanceAllowedActors are the IDs of actors, e.g. set
+	// by authentication in `Handlers`, that are serviced while
+	// in maintenance mode.
+	MaintenanceAllowedActors []string
+
+	// MaintenanceHandler optionally customizes the response
+	// returned to the user agent when a request is rejected
+	// because of maintenance mode.
+	// If nil the default handler will return a 503 status code
+	// with a "Retry-After" header and an empty body.
+	MaintenanceHandler httpx.Handler
+
 	// InternalServerErrorHandler optionally customizes the
 	// response returned to the user agent when the gateway
 	// encounters an error trying to service the requst before
@@ -129,6 +220,14 @@ type Gateway struct {
 	// with an empty body.
 	NotFoundHandler httpx.Handler
 
+	// OverloadedHandler optionally customizes the response
+	// returned to the user agent when a request is rejected
+	// because the gateway has reached its `MaxConcurrency` and
+	// `MaxQueueDepth` limits, e.g. to return 429 instead.
+	// If nil the default handler will return a 503 status code
+	// with an empty body.
+	OverloadedHandler httpx.Handler
+
 	// Registrar implements sd.Registrar and registers
 	// the gateway service with a service registry, using the
 	// Gateway's `Name` field. If nil, no registration occurs.
@@ -159,15 +258,32 @@ type Gateway struct {
 
 	base      http.Server
 	listener  net.Listener
+	listeners []net.Listener
+	mtx       sync.RWMutex
 	tree      *node
+	hosts     map[string]*node
+	routes    []service.Route
+	limiter   *limiter
+	installed bool
 	started   bool
-	interrupt chan os.Signal
+
+	maintenance         uint32
+	maintenanceServices atomic.Value
+	maintenanceNets     []*net.IPNet
+	interrupt           chan os.Signal
+
+	ready       uint32
+	drainMtx    sync.Mutex
+	registered  bool
+	checked     bool
+	withdrawn   bool
+	withdrawErr merry.Error
 }
 
 func (g *Gateway) init() {
 	start := time.Now().UTC()
 
-	gatewayExpvar = gatewayExpvar.Init()
+	gatewayExpvar.Init()
 	startTime := new(expvar.String)
 	startTime.Set(start.Format(timeFormat))
 	gatewayExpvar.Set("start-time", startTime)
@@ -176,8 +292,22 @@ func (g *Gateway) init() {
 		return now.Sub(start).String()
 	}))
 	gatewayExpvar.Set("settings", g)
+	gatewayExpvar.Set("in_flight", new(expvar.Int))
+	gatewayExpvar.Set("ready", expvar.Func(func() interface{} {
+		return g.Ready()
+	}))
 	gatewayExpvar.Set("auxiliary", auxiliary.AuxiliaryStats)
 
+	// the auxiliary servers expose their own stats, and the
+	// per service and limit stats are keyed by names that
+	// aren't bounded and may collide once sanitized, the
+	// request metrics cover them with labels instead
+	metrics.DefaultRegistry.Register(gatewayMetricsName, &metrics.ExpvarCollector{
+		Namespace: gatewayMetricsName,
+		Var:       gatewayExpvar,
+		Skip:      []string{"settings", "auxiliary", "services", "limits"},
+	})
+
 	g.base.Addr = g.Addr
 	g.base.TLSConfig = g.TLSConfig
 	g.base.ReadTimeout = g.ReadTimeout
@@ -203,10 +333,13 @@ func (g *Gateway) init() {
 
 	g.tree = new(node)
 
-	if g.HandleInterrupt {
-		g.interrupt = make(chan os.Signal, 1)
-		go g.handleInterrupt()
-		signal.Notify(g.interrupt, syscall.SIGINT, syscall.SIGTERM)
+	g.SetMaintenance(g.Maintenance)
+	g.maintenanceServices.Store(make(map[string]bool))
+
+	g.limiter = nil
+	if g.MaxConcurrency > 0 {
+		g.limiter = newLimiter(g.MaxConcurrency, g.MaxQueueDepth, g.QueueTimeout)
+		gatewayExpvar.Set("limit", g.limiter)
 	}
 }
 
@@ -239,6 +372,7 @@ func (g *Gateway) String() string {
 		"HandleInterrupt":   g.HandleInterrupt,
 		"DisableKeepAlive":  g.DisableKeepAlive,
 		"GracePeriod":       g.GracePeriod.String(),
+		"DrainDelay":        g.DrainDelay.String(),
 		"ReadTimeout":       g.ReadTimeout.String(),
 		"ReadHeaderTimeout": g.ReadHeaderTimeout.String(),
 		"WriteTimeout":      g.WriteTimeout.String(),
@@ -251,21 +385,49 @@ func (g *Gateway) String() string {
 	} else {
 		repr["TLSConfig"] = "configured"
 	}
+	if g.CertificateLoader == nil {
+		repr["CertificateLoader"] = "unset"
+	} else {
+		repr["CertificateLoader"] = "configured"
+	}
 	if len(g.TLSNextProto) == 0 {
 		repr["TLSNextProto"] = "unset"
 	} else {
 		repr["TLSNextProto"] = "configured"
 	}
 
+	repr["Listeners"] = len(g.Listeners)
+
 	repr["RequestIDHeaderName"] = g.RequestIDHeaderName
 	if g.RequestIDGenerator == nil {
 		repr["RequestIDGenerator"] = "unset"
 	} else {
 		repr["RequestIDGenerator"] = "configured"
 	}
+	if g.RequestIDValidator == nil {
+		repr["RequestIDValidator"] = "unset"
+	} else {
+		repr["RequestIDValidator"] = "configured"
+	}
 
 	repr["Handlers"] = len(g.Handlers)
 	repr["HandlersTimeout"] = g.HandlersTimeout.String()
+	repr["ResponseHandlers"] = len(g.ResponseHandlers)
+	repr["MaxConcurrency"] = g.MaxConcurrency
+	repr["MaxQueueDepth"] = g.MaxQueueDepth
+	repr["QueueTimeout"] = g.QueueTimeout.String()
+
+	maintenance := g.MaintenanceStatus()
+	repr["Maintenance"] = maintenance.Enabled
+	repr["MaintenanceServices"] = maintenance.Services
+	repr["MaintenanceRetryAfter"] = g.MaintenanceRetryAfter.String()
+	repr["MaintenanceAllowedClients"] = len(g.MaintenanceAllowedClients)
+	repr["MaintenanceAllowedActors"] = len(g.MaintenanceAllowedActors)
+	if g.MaintenanceHandler == nil {
+		repr["MaintenanceHandler"] = "unset"
+	} else {
+		repr["MaintenanceHandler"] = "configured"
+	}
 
 	if g.InternalServerErrorHandler == nil {
 		repr["InternalServerErrorHandler"] = "unset"
@@ -277,6 +439,11 @@ func (g *Gateway) String() string {
 	} else {
 		repr["NotFoundHandler"] = "configured"
 	}
+	if g.OverloadedHandler == nil {
+		repr["OverloadedHandler"] = "unset"
+	} else {
+		repr["OverloadedHandler"] = "configured"
+	}
 	if g.Registrar == nil {
 		repr["Registrar"] = "unset"
 	} else {
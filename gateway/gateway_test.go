@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/shisa-platform/core/context"
 	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/metrics"
 	"github.com/shisa-platform/core/sd"
 	"github.com/shisa-platform/core/service"
 )
@@ -62,6 +64,23 @@ func TestGatewayInit(t *testing.T) {
 	assert.Equal(t, defaultRequestIDResponseHeader, cut.RequestIDHeaderName)
 }
 
+func TestGatewayInitMetrics(t *testing.T) {
+	cut := &Gateway{}
+	cut.init()
+	installHandler(t, cut, dummyHandler)
+
+	var buf bytes.Buffer
+	assert.NoError(t, metrics.DefaultRegistry.WriteText(&buf))
+
+	text := buf.String()
+	assert.Contains(t, text, "shisa_gateway_in_flight 0\n")
+	assert.Contains(t, text, "shisa_gateway_ready 0\n")
+	assert.NotContains(t, text, "shisa_gateway_settings")
+	assert.NotContains(t, text, "shisa_gateway_auxiliary")
+	assert.NotContains(t, text, "shisa_gateway_services")
+	assert.NotContains(t, text, "shisa_gateway_limits")
+}
+
 func TestGatewaySignal(t *testing.T) {
 	cut := &Gateway{
 		Addr:            ":0",
@@ -148,24 +167,41 @@ func TestGatewayRepr(t *testing.T) {
 		"lolwut": func(*http.Server, *tls.Conn, http.Handler) {},
 	}
 	cut := &Gateway{
-		Addr:              ":9001",
-		DisableKeepAlive:  true,
-		TLSConfig:         &config,
-		ReadTimeout:       time.Millisecond * 5,
-		ReadHeaderTimeout: time.Millisecond * 10,
-		WriteTimeout:      time.Millisecond * 15,
-		IdleTimeout:       time.Millisecond * 20,
-		MaxHeaderBytes:    1024,
-		TLSNextProto:      nextProto,
+		Addr:                      ":9001",
+		DisableKeepAlive:          true,
+		DrainDelay:                time.Millisecond * 25,
+		TLSConfig:                 &config,
+		ReadTimeout:               time.Millisecond * 5,
+		ReadHeaderTimeout:         time.Millisecond * 10,
+		WriteTimeout:              time.Millisecond * 15,
+		IdleTimeout:               time.Millisecond * 20,
+		MaxHeaderBytes:            1024,
+		TLSNextProto:              nextProto,
+		MaxConcurrency:            8,
+		MaxQueueDepth:             16,
+		QueueTimeout:              time.Millisecond * 30,
+		Maintenance:               true,
+		MaintenanceRetryAfter:     time.Second * 30,
+		MaintenanceAllowedClients: []string{"10.0.0.0/8"},
+		MaintenanceAllowedActors:  []string{"admin", "ops"},
+		MaintenanceHandler: func(context.Context, *httpx.Request) httpx.Response {
+			return nil
+		},
 		RequestIDGenerator: func(context.Context, *httpx.Request) (string, merry.Error) {
 			return "", nil
 		},
+		RequestIDValidator: func(context.Context, *httpx.Request, string) bool {
+			return false
+		},
 		InternalServerErrorHandler: func(context.Context, *httpx.Request, merry.Error) httpx.Response {
 			return nil
 		},
 		NotFoundHandler: func(context.Context, *httpx.Request) httpx.Response {
 			return nil
 		},
+		OverloadedHandler: func(context.Context, *httpx.Request) httpx.Response {
+			return nil
+		},
 		Registrar: sd.NewFakeRegistrarDefaultFatal(t),
 		CheckURLHook: func() (*url.URL, merry.Error) {
 			return nil, nil
@@ -186,6 +222,9 @@ func TestGatewayRepr(t *testing.T) {
 	assert.Contains(t, expvars, "uptime")
 	assert.Contains(t, expvars, "auxiliary")
 	assert.Contains(t, expvars, "settings")
+	assert.Contains(t, expvars, "in_flight")
+	assert.Contains(t, expvars, "ready")
+	assert.Contains(t, expvars, "limit")
 
 	settings, ok := expvars["settings"].(map[string]interface{})
 	assert.True(t, ok)
@@ -196,6 +235,7 @@ func TestGatewayRepr(t *testing.T) {
 		"HandleInterrupt":            false,
 		"DisableKeepAlive":           true,
 		"GracePeriod":                "0s",
+		"DrainDelay":                 "25ms",
 		"ReadTimeout":                "5ms",
 		"ReadHeaderTimeout":          "10ms",
 		"WriteTimeout":               "15ms",
@@ -203,12 +243,26 @@ func TestGatewayRepr(t *testing.T) {
 		"MaxHeaderBytes":             float64(1024),
 		"Handlers":                   float64(0),
 		"HandlersTimeout":            "0s",
+		"ResponseHandlers":           float64(0),
+		"MaxConcurrency":             float64(8),
+		"MaxQueueDepth":              float64(16),
+		"QueueTimeout":               "30ms",
+		"Maintenance":                true,
+		"MaintenanceServices":        []interface{}{},
+		"MaintenanceRetryAfter":      "30s",
+		"MaintenanceAllowedClients":  float64(1),
+		"MaintenanceAllowedActors":   float64(2),
+		"MaintenanceHandler":         "configured",
+		"Listeners":                  float64(0),
 		"RequestIDHeaderName":        defaultRequestIDResponseHeader,
 		"TLSConfig":                  "configured",
+		"CertificateLoader":          "unset",
 		"TLSNextProto":               "configured",
 		"RequestIDGenerator":         "configured",
+		"RequestIDValidator":         "configured",
 		"InternalServerErrorHandler": "configured",
 		"NotFoundHandler":            "configured",
+		"OverloadedHandler":          "configured",
 		"Registrar":                  "configured",
 		"CheckURLHook":               "configured",
 		"ErrorHook":                  "configured",
@@ -234,6 +288,7 @@ func TestGatewayReprEmpty(t *testing.T) {
 		"HandleInterrupt":            false,
 		"DisableKeepAlive":           false,
 		"GracePeriod":                "0s",
+		"DrainDelay":                 "0s",
 		"ReadTimeout":                "0s",
 		"ReadHeaderTimeout":          "0s",
 		"WriteTimeout":               "0s",
@@ -241,12 +296,26 @@ func TestGatewayReprEmpty(t *testing.T) {
 		"MaxHeaderBytes":             float64(0),
 		"Handlers":                   float64(0),
 		"HandlersTimeout":            "0s",
+		"ResponseHandlers":           float64(0),
+		"MaxConcurrency":             float64(0),
+		"MaxQueueDepth":              float64(0),
+		"QueueTimeout":               "0s",
+		"Maintenance":                false,
+		"MaintenanceServices":        []interface{}{},
+		"MaintenanceRetryAfter":      "0s",
+		"MaintenanceAllowedClients":  float64(0),
+		"MaintenanceAllowedActors":   float64(0),
+		"MaintenanceHandler":         "unset",
+		"Listeners":                  float64(0),
 		"RequestIDHeaderName":        defaultRequestIDResponseHeader,
 		"TLSConfig":                  "unset",
+		"CertificateLoader":          "unset",
 		"TLSNextProto":               "unset",
 		"RequestIDGenerator":         "unset",
+		"RequestIDValidator":         "unset",
 		"InternalServerErrorHandler": "unset",
 		"NotFoundHandler":            "unset",
+		"OverloadedHandler":          "unset",
 		"Registrar":                  "unset",
 		"CheckURLHook":               "unset",
 		"ErrorHook":                  "unset",
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ansel1/merry"
+)
+
+// checkHost verifies that a service host is either an exact
+// host name or a wildcard of the form "*.example.com".
+func checkHost(host string) merry.Error {
+	if host == "" {
+		return merry.New("gateway: check invariants: service host empty")
+	}
+
+	wildcard := strings.LastIndexByte(host, '*')
+	switch {
+	case wildcard == -1:
+	case wildcard != 0:
+		return merry.New("gateway: check invariants: host wildcard must be the leftmost label")
+	case len(host) < 3 || host[1] != '.':
+		return merry.New("gateway: check invariants: host wildcard must be followed by a domain")
+	}
+
+	if strings.ContainsAny(host, "/:") {
+		return merry.New("gateway: check invariants: host must not contain a scheme, port or path")
+	}
+
+	return nil
+}
+
+// normalizeHost returns the lower-case host name without any port
+// or trailing dot.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	return strings.ToLower(host)
+}
@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHost(t *testing.T) {
+	assert.NoError(t, checkHost("example.com"))
+	assert.NoError(t, checkHost("*.example.com"))
+
+	assert.Error(t, checkHost(""))
+	assert.Error(t, checkHost("*"))
+	assert.Error(t, checkHost("*example.com"))
+	assert.Error(t, checkHost("api.*.example.com"))
+	assert.Error(t, checkHost("example.com:8080"))
+	assert.Error(t, checkHost("http://example.com"))
+}
+
+func TestNormalizeHost(t *testing.T) {
+	assert.Equal(t, "example.com", normalizeHost("example.com"))
+	assert.Equal(t, "example.com", normalizeHost("Example.COM"))
+	assert.Equal(t, "example.com", normalizeHost("example.com:8080"))
+	assert.Equal(t, "example.com", normalizeHost("example.com."))
+	assert.Equal(t, "::1", normalizeHost("[::1]:8080"))
+	assert.Equal(t, "", normalizeHost(""))
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/service"
+)
+
+// limiter bounds the number of requests serviced concurrently,
+// with an optional bounded queue of requests waiting for a
+// slot.  A negative queue depth leaves the queue unbounded.
+type limiter struct {
+	queued   int64 // first for 64-bit alignment
+	rejected int64
+	slots    chan struct{}
+	maxQueue int64
+	timeout  time.Duration
+}
+
+func newLimiter(concurrency, queue int, timeout time.Duration) *limiter {
+	return &limiter{
+		slots:    make(chan struct{}, concurrency),
+		maxQueue: int64(queue),
+		timeout:  timeout,
+	}
+}
+
+// acquire returns true if the request may proceed, in which
+// case `release` must be called when it completes.  If no slot
+// is free the request waits in the queue until one is, the
+// queue timeout expires or the context is done.
+func (l *limiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if n := atomic.AddInt64(&l.queued, 1); l.maxQueue >= 0 && n > l.maxQueue {
+		atomic.AddInt64(&l.queued, -1)
+		atomic.AddInt64(&l.rejected, 1)
+		return false
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+
+	var expired <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+	case <-expired:
+	}
+
+	atomic.AddInt64(&l.rejected, 1)
+	return false
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// installLimiters creates a limiter for each pipeline of the
+// endpoint with a concurrency limit, returning their expvars
+// keyed by method or nil if there are none.
+func (e *endpoint) installLimiters() *expvar.Map {
+	pipelines := [...]struct {
+		method   string
+		pipeline *service.Pipeline
+	}{
+		{http.MethodHead, e.Head},
+		{http.MethodGet, e.Get},
+		{http.MethodPut, e.Put},
+		{http.MethodPost, e.Post},
+		{http.MethodPatch, e.Patch},
+		{http.MethodDelete, e.Delete},
+		{http.MethodConnect, e.Connect},
+		{http.MethodOptions, e.Options},
+		{http.MethodTrace, e.Trace},
+	}
+
+	var vars *expvar.Map
+	for _, p := range pipelines {
+		if p.pipeline == nil || p.pipeline.Policy.MaxConcurrency <= 0 {
+			continue
+		}
+		if vars == nil {
+			vars = new(expvar.Map)
+			e.limiters = make(map[*service.Pipeline]*limiter)
+		}
+
+		policy := p.pipeline.Policy
+		l := newLimiter(policy.MaxConcurrency, policy.MaxQueueDepth, policy.QueueTimeout)
+		e.limiters[p.pipeline] = l
+		vars.Set(p.method, l)
+	}
+
+	return vars
+}
+
+// String implements `expvar.Var.String`
+func (l *limiter) String() string {
+	repr := map[string]int64{
+		"MaxConcurrency": int64(cap(l.slots)),
+		"MaxQueueDepth":  l.maxQueue,
+		"active":         int64(len(l.slots)),
+		"queued":         atomic.LoadInt64(&l.queued),
+		"rejected":       atomic.LoadInt64(&l.rejected),
+	}
+
+	data, _ := json.Marshal(repr)
+	return string(data)
+}
@@ -0,0 +1,328 @@
+package gateway
+
+import (
+	stdctx "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+func TestLimiterAcquireRelease(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(2, 0, 0)
+
+	assert.True(t, cut.acquire(ctx))
+	assert.True(t, cut.acquire(ctx))
+	assert.False(t, cut.acquire(ctx))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.rejected))
+
+	cut.release()
+	assert.True(t, cut.acquire(ctx))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.rejected))
+}
+
+func TestLimiterQueue(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(1, 1, 0)
+
+	assert.True(t, cut.acquire(ctx))
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- cut.acquire(ctx)
+	}()
+
+	for atomic.LoadInt64(&cut.queued) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, cut.acquire(ctx), "queue overflow")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.rejected))
+
+	cut.release()
+	assert.True(t, <-acquired)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&cut.queued))
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(1, 1, time.Millisecond*5)
+
+	assert.True(t, cut.acquire(ctx))
+	assert.False(t, cut.acquire(ctx))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&cut.queued))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.rejected))
+}
+
+func TestLimiterQueueContextDone(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(1, 1, 0)
+
+	assert.True(t, cut.acquire(ctx))
+
+	ctx, cancel := ctx.WithCancel()
+	cancel()
+	assert.False(t, cut.acquire(ctx))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.rejected))
+}
+
+func TestLimiterUnboundedQueue(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(1, httpx.NoLimit, 0)
+
+	assert.True(t, cut.acquire(ctx))
+
+	acquired := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() { acquired <- cut.acquire(ctx) }()
+	}
+	for atomic.LoadInt64(&cut.queued) != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cut.release()
+	assert.True(t, <-acquired)
+	cut.release()
+	assert.True(t, <-acquired)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&cut.rejected))
+}
+
+func TestLimiterString(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+	cut := newLimiter(2, 4, 0)
+	cut.acquire(ctx)
+
+	var repr map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(cut.String()), &repr))
+
+	expected := map[string]interface{}{
+		"MaxConcurrency": float64(2),
+		"MaxQueueDepth":  float64(4),
+		"active":         float64(1),
+		"queued":         float64(0),
+		"rejected":       float64(0),
+	}
+	assert.Equal(t, expected, repr)
+}
+
+// blockingHandler holds its concurrency slot until released
+type blockingHandler struct {
+	started chan struct{}
+	done    chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (h *blockingHandler) Handle(context.Context, *httpx.Request) httpx.Response {
+	h.started <- struct{}{}
+	<-h.done
+	return httpx.NewEmpty(http.StatusOK)
+}
+
+func TestRouterGatewayOverloaded(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		MaxConcurrency: 1,
+		ErrorHook:      errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	installHandler(t, cut, handler.Handle)
+
+	first := httptest.NewRecorder()
+	go cut.ServeHTTP(first, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	close(handler.done)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get(cut.RequestIDHeaderName))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cut.limiter.rejected))
+}
+
+func TestRouterGatewayOverloadedCustomHandler(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		MaxConcurrency: 1,
+		MaxQueueDepth:  1,
+		QueueTimeout:   time.Millisecond * 5,
+		OverloadedHandler: func(context.Context, *httpx.Request) httpx.Response {
+			return httpx.NewEmpty(http.StatusTooManyRequests)
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	installHandler(t, cut, handler.Handle)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	close(handler.done)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRouterGatewayOverloadedCustomHandlerPanic(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		MaxConcurrency: 1,
+		OverloadedHandler: func(context.Context, *httpx.Request) httpx.Response {
+			panic(merry.New("i blewed up!"))
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	installHandler(t, cut, handler.Handle)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	close(handler.done)
+
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestRouterPipelineOverloaded(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	policy := service.Policy{MaxConcurrency: 1}
+	installHandlersWithPolicy(t, cut, policy, handler.Handle)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(handler.done)
+
+	limits, ok := gatewayExpvar.Get("limits").(interface{ String() string })
+	assert.True(t, ok)
+	var repr map[string]map[string]map[string]map[string]float64
+	assert.NoError(t, json.Unmarshal([]byte(limits.String()), &repr))
+	assert.Equal(t, float64(1), repr["test"][expectedRoute][http.MethodGet]["rejected"])
+}
+
+func TestRouterPipelineQueued(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	policy := service.Policy{MaxConcurrency: 1, MaxQueueDepth: 1}
+	installHandlersWithPolicy(t, cut, policy, handler.Handle)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	finished := make(chan struct{})
+	go func() {
+		cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+		close(finished)
+	}()
+
+	handler.done <- struct{}{}
+	<-handler.started
+	close(handler.done)
+	<-finished
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterPipelineOverloadedCustomHandler(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	policy := service.Policy{MaxConcurrency: 1}
+	svc := &service.Service{
+		Name:      "test",
+		Endpoints: newEndpointsWithPolicy(policy, handler.Handle),
+		OverloadedHandler: func(context.Context, *httpx.Request) httpx.Response {
+			return httpx.NewEmpty(http.StatusTooManyRequests)
+		},
+	}
+	installService(t, cut, svc)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	close(handler.done)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRouterPipelineOverloadedCustomHandlerPanic(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	handler := newBlockingHandler()
+	policy := service.Policy{MaxConcurrency: 1}
+	svc := &service.Service{
+		Name:      "test",
+		Endpoints: newEndpointsWithPolicy(policy, handler.Handle),
+		OverloadedHandler: func(context.Context, *httpx.Request) httpx.Response {
+			panic(merry.New("i blewed up!"))
+		},
+	}
+	installService(t, cut, svc)
+
+	go cut.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	<-handler.started
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	close(handler.done)
+
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+)
+
+// Listener is an additional address served by a Gateway.  All
+// listeners share the services, handlers and lifecycle of the
+// gateway.
+type Listener struct {
+	// Network is either "tcp" or "unix".
+	// If empty "tcp" is used.
+	Network string
+
+	// Addr is the TCP address or Unix socket path to listen on.
+	// Required.
+	Addr string
+
+	// TLSConfig optionally enables TLS for connections to this
+	// listener.  It must provide a certificate either with
+	// `Certificates` or `GetCertificate`.  HTTP/2 is negotiated
+	// unless the gateway's `TLSNextProto` is set.
+	TLSConfig *tls.Config
+
+	// CertificateLoader optionally provides the certificate of
+	// the listener, replacing any in `TLSConfig`, and enables
+	// TLS if `TLSConfig` is nil.  It is started and stopped with
+	// the gateway, so rotated certificates are used without a
+	// restart.  Reload failures are passed to the gateway's
+	// `ErrorHook` if the loader doesn't have its own.
+	CertificateLoader *httpx.CertificateLoader
+}
+
+func (l Listener) listen(http2 bool) (net.Listener, merry.Error) {
+	var listener net.Listener
+	var err merry.Error
+	switch l.Network {
+	case "", "tcp":
+		if l.Addr == "" {
+			return nil, merry.New("check invariants: listener address empty")
+		}
+		listener, err = httpx.HTTPListenerForAddress(l.Addr)
+	case "unix":
+		listener, err = httpx.UnixListenerForPath(l.Addr)
+	default:
+		return nil, merry.New("check invariants: unsupported listener network").Append(l.Network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if l.TLSConfig == nil && l.CertificateLoader == nil {
+		return listener, nil
+	}
+
+	var config *tls.Config
+	if l.CertificateLoader != nil {
+		config = l.CertificateLoader.TLSConfig(l.TLSConfig)
+	} else {
+		config = l.TLSConfig.Clone()
+	}
+	if http2 && !contains(config.NextProtos, "h2") {
+		config.NextProtos = append([]string{"h2"}, config.NextProtos...)
+	}
+	if !contains(config.NextProtos, "http/1.1") {
+		config.NextProtos = append(config.NextProtos, "http/1.1")
+	}
+
+	return tls.NewListener(listener, config), nil
+}
+
+// listen opens the additional listeners of the gateway.  If any
+// listener fails those already opened are closed.
+func (g *Gateway) listen() merry.Error {
+	listeners := make([]net.Listener, 0, len(g.Listeners))
+	defer func() {
+		g.mtx.Lock()
+		g.listeners = listeners
+		g.mtx.Unlock()
+	}()
+
+	for _, l := range g.Listeners {
+		if l.CertificateLoader != nil {
+			if err := g.startLoader(l.CertificateLoader); err != nil {
+				for _, listener := range listeners {
+					listener.Close()
+				}
+				g.closeListeners()
+				g.stopListenerCertificateLoaders()
+				return err.Append(l.Addr)
+			}
+		}
+
+		listener, err := l.listen(g.TLSNextProto == nil)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			g.closeListeners()
+			g.stopListenerCertificateLoaders()
+			return err.Append(l.Addr)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return nil
+}
+
+// startLoader starts a certificate loader, passing reload
+// failures to the gateway's error hook if the loader doesn't have
+// its own.
+func (g *Gateway) startLoader(loader *httpx.CertificateLoader) merry.Error {
+	if loader.ErrorHook == nil {
+		loader.ErrorHook = func(ctx context.Context, request *httpx.Request, err merry.Error) {
+			g.invokeErrorHookSafely(ctx, request, err.Prepend("gateway"))
+		}
+	}
+
+	return loader.Start()
+}
+
+func (g *Gateway) stopListenerCertificateLoaders() {
+	for _, l := range g.Listeners {
+		if l.CertificateLoader != nil {
+			l.CertificateLoader.Stop()
+		}
+	}
+}
+
+func (g *Gateway) closeListeners() {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	if g.listener != nil {
+		g.listener.Close()
+	}
+	for _, l := range g.listeners {
+		l.Close()
+	}
+}
+
+// Addresses returns the addresses of all listeners of the
+// gateway, starting with `Addr`.
+func (g *Gateway) Addresses() []string {
+	addrs := []string{g.Address()}
+
+	g.mtx.RLock()
+	listeners := g.listeners
+	g.mtx.RUnlock()
+
+	if listeners != nil {
+		for _, l := range listeners {
+			addrs = append(addrs, l.Addr().String())
+		}
+		return addrs
+	}
+
+	for _, l := range g.Listeners {
+		addrs = append(addrs, l.Addr)
+	}
+
+	return addrs
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	stdctx "context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+func TestListenerBadNetwork(t *testing.T) {
+	cut := Listener{Network: "udp", Addr: ":0"}
+
+	listener, err := cut.listen(true)
+	assert.Error(t, err)
+	assert.Nil(t, listener)
+}
+
+func TestListenerEmptyAddress(t *testing.T) {
+	cut := Listener{}
+
+	listener, err := cut.listen(true)
+	assert.Error(t, err)
+	assert.Nil(t, listener)
+}
+
+func TestListenerTLSNextProtos(t *testing.T) {
+	config := &tls.Config{}
+	cut := Listener{Addr: "127.0.0.1:0", TLSConfig: config}
+
+	listener, err := cut.listen(true)
+	assert.NoError(t, err)
+	assert.NotNil(t, listener)
+	listener.Close()
+	assert.Empty(t, config.NextProtos, "config modified")
+
+	listener, err = cut.listen(false)
+	assert.NoError(t, err)
+	assert.NotNil(t, listener)
+	listener.Close()
+}
+
+func TestGatewayListenerFailureClosesListeners(t *testing.T) {
+	cut := &Gateway{
+		Addr: "127.0.0.1:0",
+		Listeners: []Listener{
+			{Addr: "127.0.0.1:0"},
+			{Network: "unix"},
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	err := cut.Serve(svc)
+	assert.Error(t, err)
+	assert.Len(t, cut.listeners, 1)
+
+	_, err1 := cut.listeners[0].Accept()
+	assert.Error(t, err1, "listener not closed")
+}
+
+func TestGatewayMultipleListeners(t *testing.T) {
+	// borrow the certificate and trusting client of httptest
+	ts := httptest.NewTLSServer(nil)
+	certificate := ts.TLS.Certificates[0]
+	tlsClient := ts.Client()
+	ts.Close()
+
+	dir, err := ioutil.TempDir("", "gateway")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "gateway.sock")
+
+	cut := &Gateway{
+		Addr: "127.0.0.1:0",
+		Listeners: []Listener{
+			{
+				Addr:      "127.0.0.1:0",
+				TLSConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+			},
+			{
+				Network: "unix",
+				Addr:    socket,
+			},
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.Serve(svc)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	addrs := cut.Addresses()
+	assert.Len(t, addrs, 3)
+	assert.Equal(t, socket, addrs[2])
+
+	response, err := http.Get("http://" + addrs[0] + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	response, err = tlsClient.Get("https://" + addrs[1] + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.NotNil(t, response.TLS)
+	}
+
+	unixClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(stdctx.Context, string, string) (net.Conn, error) {
+				return net.Dial("unix", socket)
+			},
+		},
+	}
+	response, err = unixClient.Get("http://gateway" + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	assert.NoError(t, cut.Shutdown())
+	wg.Wait()
+
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err), "socket not removed")
+}
+
+// writeTestCertificate writes the certificate of httptest as
+// PEM files and returns their paths and a client trusting it.
+func writeTestCertificate(t *testing.T, dir string) (string, string, *http.Client) {
+	ts := httptest.NewTLSServer(nil)
+	certificate := ts.TLS.Certificates[0]
+	client := ts.Client()
+	ts.Close()
+
+	key, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	assert.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	return certFile, keyFile, client
+}
+
+func TestGatewayServeTLSCertificateLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, client := writeTestCertificate(t, dir)
+
+	cut := &Gateway{
+		Addr:      "127.0.0.1:0",
+		TLSConfig: &tls.Config{},
+		CertificateLoader: &httpx.CertificateLoader{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			IgnoreHangup: true,
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.ServeTLS(svc)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	response, err := client.Get("https://" + cut.Address() + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+	assert.NotNil(t, cut.CertificateLoader.ErrorHook, "error hook not installed")
+
+	cut.Shutdown()
+	wg.Wait()
+}
+
+func TestGatewayServeTLSCertificateLoaderMissingFiles(t *testing.T) {
+	cut := &Gateway{
+		Addr: "127.0.0.1:0",
+		CertificateLoader: &httpx.CertificateLoader{
+			CertFile:     "/zalgo/cert.pem",
+			KeyFile:      "/zalgo/key.pem",
+			IgnoreHangup: true,
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	err := cut.ServeTLS(svc)
+	assert.Error(t, err)
+	assert.Nil(t, cut.listener, "listener opened")
+}
+
+func TestGatewayListenerCertificateLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, client := writeTestCertificate(t, dir)
+
+	loader := &httpx.CertificateLoader{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		IgnoreHangup: true,
+	}
+	cut := &Gateway{
+		Addr: "127.0.0.1:0",
+		Listeners: []Listener{
+			{Addr: "127.0.0.1:0", CertificateLoader: loader},
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.Serve(svc)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	addrs := cut.Addresses()
+	assert.Len(t, addrs, 2)
+	response, err := client.Get("https://" + addrs[1] + expectedRoute)
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+	assert.NotNil(t, loader.ErrorHook, "error hook not installed")
+
+	cut.Shutdown()
+	wg.Wait()
+}
+
+func TestGatewayListenerCertificateLoaderMissingFiles(t *testing.T) {
+	cut := &Gateway{
+		Addr: "127.0.0.1:0",
+		Listeners: []Listener{
+			{
+				Addr: "127.0.0.1:0",
+				CertificateLoader: &httpx.CertificateLoader{
+					CertFile:     "/zalgo/cert.pem",
+					KeyFile:      "/zalgo/key.pem",
+					IgnoreHangup: true,
+				},
+			},
+		},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	err := cut.Serve(svc)
+	assert.Error(t, err)
+	assert.Empty(t, cut.listeners)
+}
@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+const (
+	defaultMaintenanceRetryAfter = time.Minute
+	retryAfterHeaderKey          = "Retry-After"
+)
+
+// SetMaintenance puts the whole gateway into, or takes it out
+// of, maintenance mode.
+func (g *Gateway) SetMaintenance(enabled bool) {
+	var value uint32
+	if enabled {
+		value = 1
+	}
+	atomic.StoreUint32(&g.maintenance, value)
+}
+
+// SetServiceMaintenance puts the named service into, or takes
+// it out of, maintenance mode.  The service must be installed.
+func (g *Gateway) SetServiceMaintenance(name string, enabled bool) merry.Error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	found := false
+	for _, route := range g.routes {
+		if route.Service == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return merry.New("gateway: set service maintenance: unknown service").Append(name)
+	}
+
+	current, _ := g.maintenanceServices.Load().(map[string]bool)
+	services := make(map[string]bool, len(current)+1)
+	for k := range current {
+		services[k] = true
+	}
+	if enabled {
+		services[name] = true
+	} else {
+		delete(services, name)
+	}
+	g.maintenanceServices.Store(services)
+
+	return nil
+}
+
+// MaintenanceStatus returns which parts of the gateway are in
+// maintenance mode.
+func (g *Gateway) MaintenanceStatus() service.Maintenance {
+	status := service.Maintenance{
+		Enabled:  atomic.LoadUint32(&g.maintenance) == 1,
+		Services: make([]string, 0),
+	}
+
+	services, _ := g.maintenanceServices.Load().(map[string]bool)
+	for name := range services {
+		status.Services = append(status.Services, name)
+	}
+	sort.Strings(status.Services)
+
+	return status
+}
+
+// pruneMaintenance removes services that are no longer
+// installed from maintenance mode.  The caller must hold the
+// write lock.
+func (g *Gateway) pruneMaintenance() {
+	current, _ := g.maintenanceServices.Load().(map[string]bool)
+	if len(current) == 0 {
+		return
+	}
+
+	installed := make(map[string]bool)
+	for _, route := range g.routes {
+		installed[route.Service] = true
+	}
+
+	services := make(map[string]bool, len(current))
+	for name := range current {
+		if installed[name] {
+			services[name] = true
+		}
+	}
+	g.maintenanceServices.Store(services)
+}
+
+func (g *Gateway) parseMaintenanceAllowlist() merry.Error {
+	g.maintenanceNets = nil
+	for _, client := range g.MaintenanceAllowedClients {
+		if ip := net.ParseIP(client); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			g.maintenanceNets = append(g.maintenanceNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(client)
+		if err != nil {
+			return merry.Prepend(err, "gateway: check invariants: parse maintenance allowed client").Append(client)
+		}
+		g.maintenanceNets = append(g.maintenanceNets, network)
+	}
+
+	return nil
+}
+
+// inMaintenance returns true if requests for the named service,
+// or the gateway as a whole if the name is empty, are in
+// maintenance mode and the user agent isn't allowed through.
+func (g *Gateway) inMaintenance(ctx context.Context, request *httpx.Request, serviceName string) bool {
+	if serviceName == "" {
+		if atomic.LoadUint32(&g.maintenance) == 0 {
+			return false
+		}
+	} else {
+		services, _ := g.maintenanceServices.Load().(map[string]bool)
+		if !services[serviceName] {
+			return false
+		}
+	}
+
+	if actor := ctx.Actor(); actor != nil && len(g.MaintenanceAllowedActors) != 0 {
+		id := actor.ID()
+		for _, allowed := range g.MaintenanceAllowedActors {
+			if id == allowed {
+				return false
+			}
+		}
+	}
+
+	if len(g.maintenanceNets) != 0 {
+		host, _, err := net.SplitHostPort(request.RemoteAddr)
+		if err != nil {
+			host = request.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, network := range g.maintenanceNets {
+				if network.Contains(ip) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (g *Gateway) handleMaintenance(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
+	if g.MaintenanceHandler == nil {
+		return g.defaultMaintenanceResponse(), nil
+	}
+
+	response, exception := g.MaintenanceHandler.InvokeSafely(ctx, request)
+	if exception != nil {
+		err := exception.Prepend("gateway: route: run MaintenanceHandler")
+		return g.defaultMaintenanceResponse(), err
+	}
+
+	return response, nil
+}
+
+func (g *Gateway) defaultMaintenanceResponse() httpx.Response {
+	retryAfter := g.MaintenanceRetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultMaintenanceRetryAfter
+	}
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+
+	response := httpx.NewEmpty(http.StatusServiceUnavailable)
+	response.Headers().Set(retryAfterHeaderKey, strconv.FormatInt(seconds, 10))
+
+	return response
+}
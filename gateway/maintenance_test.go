@@ -0,0 +1,256 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/auxiliary"
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/models"
+	"github.com/shisa-platform/core/service"
+)
+
+var _ auxiliary.MaintenanceController = &Gateway{}
+
+func newMaintenanceGateway(t *testing.T, g *Gateway) (*bool, http.Handler) {
+	var called bool
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		called = true
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, handler)
+	h, err := g.Handler(newFakeService([]service.Endpoint{endpoint}))
+	assert.NoError(t, err)
+
+	return &called, h
+}
+
+func TestMaintenanceGateway(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook:   errHook.Handle,
+		Maintenance: true,
+	}
+	called, handler := newMaintenanceGateway(t, cut)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.False(t, *called)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get(retryAfterHeaderKey))
+	assert.Equal(t, service.Maintenance{Enabled: true, Services: []string{}}, cut.MaintenanceStatus())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lolwut", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	cut.SetMaintenance(false)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.True(t, *called)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, cut.MaintenanceStatus().Enabled)
+}
+
+func TestMaintenanceRetryAfter(t *testing.T) {
+	cut := &Gateway{
+		Maintenance:           true,
+		MaintenanceRetryAfter: time.Millisecond * 1500,
+	}
+	_, handler := newMaintenanceGateway(t, cut)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get(retryAfterHeaderKey))
+}
+
+func TestMaintenanceService(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	called, handler := newMaintenanceGateway(t, cut)
+
+	assert.Error(t, cut.SetServiceMaintenance("lolwut", true))
+	assert.NoError(t, cut.SetServiceMaintenance("test", true))
+	assert.Equal(t, service.Maintenance{Services: []string{"test"}}, cut.MaintenanceStatus())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.False(t, *called)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lolwut", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.NoError(t, cut.SetServiceMaintenance("test", false))
+	assert.Empty(t, cut.MaintenanceStatus().Services)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	assert.True(t, *called)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMaintenanceServicePrunedOnReload(t *testing.T) {
+	cut := &Gateway{}
+	newMaintenanceGateway(t, cut)
+
+	assert.NoError(t, cut.SetServiceMaintenance("test", true))
+
+	svc := &service.Service{
+		Name:      "other",
+		Endpoints: []service.Endpoint{service.GetEndpoint(expectedRoute, dummyHandler)},
+	}
+	assert.NoError(t, cut.Reload(svc))
+
+	assert.Empty(t, cut.MaintenanceStatus().Services)
+}
+
+func TestMaintenanceAllowedClients(t *testing.T) {
+	cut := &Gateway{
+		Maintenance:               true,
+		MaintenanceAllowedClients: []string{"192.0.2.0/24", "2001:db8::1"},
+	}
+	called, handler := newMaintenanceGateway(t, cut)
+
+	for addr, expected := range map[string]int{
+		"192.0.2.10:1234":      http.StatusOK,
+		"[2001:db8::1]:1234":   http.StatusOK,
+		"2001:db8::1":          http.StatusOK,
+		"198.51.100.1:1234":    http.StatusServiceUnavailable,
+		"[2001:db8::2]:1234":   http.StatusServiceUnavailable,
+		"192.0.2.256.10:12345": http.StatusServiceUnavailable,
+	} {
+		*called = false
+		request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+		request.RemoteAddr = addr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+
+		assert.Equal(t, expected, w.Code, addr)
+		assert.Equal(t, expected == http.StatusOK, *called, addr)
+	}
+}
+
+func TestMaintenanceAllowedClientsIgnoresForwardingHeaders(t *testing.T) {
+	cut := &Gateway{
+		Maintenance:               true,
+		MaintenanceAllowedClients: []string{"192.0.2.0/24", "2001:db8::1"},
+	}
+	called, handler := newMaintenanceGateway(t, cut)
+
+	for _, header := range []string{"X-Real-IP", "X-Forwarded-For"} {
+		for _, value := range []string{"192.0.2.10", "2001:db8::1"} {
+			*called = false
+			request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+			request.RemoteAddr = "198.51.100.1:1234"
+			request.Header.Set(header, value)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, header+": "+value)
+			assert.False(t, *called, header+": "+value)
+		}
+	}
+}
+
+func TestMaintenanceAllowedClientsMalformed(t *testing.T) {
+	cut := &Gateway{
+		MaintenanceAllowedClients: []string{"192.0.2.0/33"},
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	handler, err := cut.Handler(newFakeService([]service.Endpoint{endpoint}))
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestMaintenanceAllowedActors(t *testing.T) {
+	authenticate := func(ctx context.Context, request *httpx.Request) httpx.Response {
+		if id := request.Header.Get("X-User"); id != "" {
+			ctx.WithActor(&models.FakeUser{IDHook: func() string { return id }})
+		}
+		return nil
+	}
+	cut := &Gateway{
+		Handlers:                 []httpx.Handler{authenticate},
+		MaintenanceAllowedActors: []string{"admin"},
+	}
+	called, handler := newMaintenanceGateway(t, cut)
+	assert.NoError(t, cut.SetServiceMaintenance("test", true))
+
+	for user, expected := range map[string]int{
+		"admin":  http.StatusOK,
+		"zalgo":  http.StatusServiceUnavailable,
+		"":       http.StatusServiceUnavailable,
+		"admin2": http.StatusServiceUnavailable,
+	} {
+		*called = false
+		request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+		request.Header.Set("X-User", user)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+
+		assert.Equal(t, expected, w.Code, user)
+		assert.Equal(t, expected == http.StatusOK, *called, user)
+	}
+}
+
+func TestMaintenanceCustomHandler(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook:   errHook.Handle,
+		Maintenance: true,
+		MaintenanceHandler: func(context.Context, *httpx.Request) httpx.Response {
+			return httpx.NewEmpty(http.StatusTeapot)
+		},
+	}
+	_, handler := newMaintenanceGateway(t, cut)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestMaintenanceCustomHandlerPanic(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook:   errHook.Handle,
+		Maintenance: true,
+		MaintenanceHandler: func(context.Context, *httpx.Request) httpx.Response {
+			panic(merry.New("i blewed up!"))
+		},
+	}
+	_, handler := newMaintenanceGateway(t, cut)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get(retryAfterHeaderKey))
+}
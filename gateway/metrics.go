@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/metrics"
+)
+
+const (
+	phaseGatewayHandlers = "gateway_handlers"
+	phasePipeline        = "pipeline"
+	phaseSerialization   = "serialization"
+
+	// label values of requests that don't match an endpoint
+	unmatchedService = "unmatched"
+	unmatchedRoute   = "unmatched"
+
+	// label value of requests with a nonstandard method
+	otherMethod = "OTHER"
+)
+
+var (
+	requestsTotal = mustCounter(metrics.NewCounter(
+		"shisa_gateway_requests_total",
+		"Requests served by the gateway by service, route, method and status class.",
+		"service", "route", "method", "code",
+	))
+	requestErrorsTotal = mustCounter(metrics.NewCounter(
+		"shisa_gateway_request_errors_total",
+		"Requests that failed with an error by service, route and method.",
+		"service", "route", "method",
+	))
+	requestDuration = mustHistogram(metrics.NewHistogram(
+		"shisa_gateway_request_duration_seconds",
+		"Time taken to serve requests by service, route and method.",
+		nil,
+		"service", "route", "method",
+	))
+	phaseDuration = mustHistogram(metrics.NewHistogram(
+		"shisa_gateway_request_phase_duration_seconds",
+		"Time spent in each phase of serving requests by service, route and method.",
+		nil,
+		"service", "route", "method", "phase",
+	))
+)
+
+func mustCounter(c *metrics.Counter, err merry.Error) *metrics.Counter {
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func mustHistogram(h *metrics.Histogram, err merry.Error) *metrics.Histogram {
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// timing is the duration of a phase of servicing a request.
+type timing struct {
+	start   time.Time
+	elapsed time.Duration
+}
+
+func (t *timing) begin() {
+	t.start = time.Now()
+}
+
+func (t *timing) end() {
+	t.elapsed = time.Since(t.start)
+}
+
+// requestTimings are the durations of the phases of servicing
+// a request, matching the spans created by `ServeHTTP`.
+type requestTimings struct {
+	handlers      timing
+	pipeline      timing
+	serialization timing
+}
+
+// recordMetrics updates the RED metrics for the request.  The
+// labels are limited to values known in advance, i.e. the route
+// template rather than the path, to keep the number of series
+// bounded.
+func recordMetrics(request *httpx.Request, snapshot httpx.ResponseSnapshot, timings *requestTimings, failed bool) {
+	service, route := unmatchedService, unmatchedRoute
+	if snapshot.Route != "" {
+		service, route = snapshot.Service, snapshot.Route
+	}
+	method := methodLabel(request.Method)
+
+	requestsTotal.Inc(service, route, method, statusClass(snapshot.StatusCode))
+	if failed {
+		requestErrorsTotal.Inc(service, route, method)
+	}
+	requestDuration.Observe(snapshot.Elapsed.Seconds(), service, route, method)
+
+	for _, phase := range []struct {
+		name string
+		t    timing
+	}{
+		{phaseGatewayHandlers, timings.handlers},
+		{phasePipeline, timings.pipeline},
+		{phaseSerialization, timings.serialization},
+	} {
+		if phase.t.start.IsZero() {
+			continue
+		}
+		phaseDuration.Observe(phase.t.elapsed.Seconds(), service, route, method, phase.name)
+	}
+}
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodHead, http.MethodGet, http.MethodPut, http.MethodPost,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace:
+		return method
+	}
+
+	return otherMethod
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(code/100) + "xx"
+}
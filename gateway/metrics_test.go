@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/metrics"
+	"github.com/shisa-platform/core/service"
+)
+
+func TestMethodLabel(t *testing.T) {
+	assert.Equal(t, http.MethodGet, methodLabel(http.MethodGet))
+	assert.Equal(t, http.MethodPatch, methodLabel(http.MethodPatch))
+	assert.Equal(t, otherMethod, methodLabel("ZALGO"))
+}
+
+func TestStatusClass(t *testing.T) {
+	assert.Equal(t, "2xx", statusClass(http.StatusOK))
+	assert.Equal(t, "4xx", statusClass(http.StatusNotFound))
+	assert.Equal(t, "5xx", statusClass(http.StatusGatewayTimeout))
+	assert.Equal(t, "unknown", statusClass(0))
+	assert.Equal(t, "unknown", statusClass(666))
+}
+
+func TestRouterMetricsRouteTemplate(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		Handlers: []httpx.Handler{
+			func(context.Context, *httpx.Request) httpx.Response { return nil },
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	route := "/metrics/template/:id"
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		time.Sleep(time.Millisecond * 5)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installEndpoints(t, cut, []service.Endpoint{service.GetEndpoint(route, handler)})
+
+	labels := []string{"test", route, http.MethodGet}
+	requests := requestsTotal.Value(append(labels, "2xx")...)
+	errors := requestErrorsTotal.Value(labels...)
+	durations := requestDuration.Count(labels...)
+	handlers := phaseDuration.Count(append(labels, phaseGatewayHandlers)...)
+	pipeline := phaseDuration.Count(append(labels, phasePipeline)...)
+	pipelineSum := phaseDuration.Sum(append(labels, phasePipeline)...)
+	serialization := phaseDuration.Count(append(labels, phaseSerialization)...)
+
+	for _, path := range []string{"/metrics/template/1", "/metrics/template/2"} {
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, requests+2, requestsTotal.Value(append(labels, "2xx")...))
+	assert.Equal(t, errors, requestErrorsTotal.Value(labels...))
+	assert.Equal(t, durations+2, requestDuration.Count(labels...))
+	assert.Equal(t, handlers+2, phaseDuration.Count(append(labels, phaseGatewayHandlers)...))
+	assert.Equal(t, pipeline+2, phaseDuration.Count(append(labels, phasePipeline)...))
+	assert.True(t, phaseDuration.Sum(append(labels, phasePipeline)...)-pipelineSum >= 0.01)
+	assert.Equal(t, serialization+2, phaseDuration.Count(append(labels, phaseSerialization)...))
+}
+
+func TestRouterMetricsErrors(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{ErrorHook: errHook.Handle}
+	cut.init()
+
+	route := "/metrics/errors"
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		panic("i blewed up!")
+	}
+	installEndpoints(t, cut, []service.Endpoint{service.GetEndpoint(route, handler)})
+
+	labels := []string{"test", route, http.MethodGet}
+	requests := requestsTotal.Value(append(labels, "5xx")...)
+	errors := requestErrorsTotal.Value(labels...)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, route, nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	errHook.assertCalled(t)
+	assert.Equal(t, requests+1, requestsTotal.Value(append(labels, "5xx")...))
+	assert.Equal(t, errors+1, requestErrorsTotal.Value(labels...))
+}
+
+func TestRouterMetricsUnmatched(t *testing.T) {
+	cut := &Gateway{}
+	cut.init()
+	installHandler(t, cut, dummyHandler)
+
+	labels := []string{unmatchedService, unmatchedRoute, otherMethod}
+	requests := requestsTotal.Value(append(labels, "4xx")...)
+	pipeline := phaseDuration.Count(append(labels, phasePipeline)...)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest("ZALGO", "/he/comes/1", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, requests+1, requestsTotal.Value(append(labels, "4xx")...))
+	assert.Equal(t, pipeline, phaseDuration.Count(append(labels, phasePipeline)...))
+}
+
+func TestRouterMetricsExposed(t *testing.T) {
+	cut := &Gateway{}
+	cut.init()
+	installHandler(t, cut, dummyHandler)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	var buf bytes.Buffer
+	assert.NoError(t, metrics.DefaultRegistry.WriteText(&buf))
+
+	text := buf.String()
+	assert.True(t, strings.Contains(text, `shisa_gateway_requests_total{service="test",route="/test",method="GET",code="2xx"}`))
+	assert.True(t, strings.Contains(text, `shisa_gateway_request_duration_seconds_count{service="test",route="/test",method="GET"}`))
+	assert.True(t, strings.Contains(text, `shisa_gateway_request_phase_duration_seconds_count{service="test",route="/test",method="GET",phase="pipeline"}`))
+}
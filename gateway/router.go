@@ -15,6 +15,7 @@ import (
 	"github.com/shisa-platform/core/errorx"
 	"github.com/shisa-platform/core/httpx"
 	"github.com/shisa-platform/core/service"
+	"github.com/shisa-platform/core/tracecontext"
 )
 
 var (
@@ -25,13 +26,36 @@ var (
 )
 
 func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	parent := opentracing.StartSpan("ServiceRequest", routerTags)
+	gatewayExpvar.Add("in_flight", 1)
+	defer gatewayExpvar.Add("in_flight", -1)
+
+	tracer := opentracing.GlobalTracer()
+	spanOpts := []opentracing.StartSpanOption{routerTags}
+	carrier := opentracing.HTTPHeadersCarrier(r.Header)
+	if spanCtx, err := tracer.Extract(opentracing.HTTPHeaders, carrier); err == nil {
+		spanOpts = append(spanOpts, opentracing.ChildOf(spanCtx))
+	}
+	parent := tracer.StartSpan("ServiceRequest", spanOpts...)
 	defer parent.Finish()
 
+	// the span is the W3C child of the caller, its id is the
+	// parent id of the calls made while serving the request
+	tc, tcErr := tracecontext.Extract(r.Header)
+	if tcErr == nil {
+		parent.SetTag("w3c.parent_id", tc.ParentID.String())
+		tc = tc.Child()
+	} else {
+		_, noop := tracer.(opentracing.NoopTracer)
+		tc = tracecontext.New(!noop)
+	}
+	parent.SetTag("w3c.trace_id", tc.TraceID.String())
+	parent.SetTag("w3c.span_id", tc.ParentID.String())
+
 	ri := httpx.NewInterceptor(w)
 
 	ctx := context.New(r.Context())
 	ctx = ctx.WithSpan(parent)
+	ctx = tracecontext.WithTraceContext(ctx, tc)
 
 	request := httpx.GetRequest(r)
 	defer httpx.PutRequest(request)
@@ -54,27 +78,45 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	if cn, ok := w.(http.CloseNotifier); ok {
+		// must be called before ServeHTTP returns
+		closed := cn.CloseNotify()
+		done := ctx.Done()
 		go func() {
 			select {
-			case <-cn.CloseNotify():
+			case <-closed:
 				cancel()
-			case <-ctx.Done():
+			case <-done:
 			}
 		}()
 	}
 
 	var (
-		path       string = request.URL.EscapedPath()
-		endpoint   *endpoint
-		pipeline   *service.Pipeline
-		err        merry.Error
-		response   httpx.Response
-		tsr        bool
-		responseCh chan httpx.Response = make(chan httpx.Response, 1)
+		path        string = request.URL.EscapedPath()
+		endpoint    *endpoint
+		pipeline    *service.Pipeline
+		policy      service.Policy
+		pipelineCtx context.Context
+		body        *limitedBody
+		err         merry.Error
+		response    httpx.Response
+		tsr         bool
+		malformed   bool
+		headOnly    bool
+		responseCh  chan httpx.Response = make(chan httpx.Response, 1)
+		timings     requestTimings
 	)
 
+	if g.limiter != nil {
+		if !g.limiter.acquire(ctx) {
+			response, err = g.handleOverloaded(ctx, request)
+			goto finish
+		}
+		defer g.limiter.release()
+	}
+
 	span = ctx.StartSpan("RunGatewayHandlers")
-	pipelineCtx := ctx
+	timings.handlers.begin()
+	pipelineCtx = ctx
 	pipelineCtx.WithSpan(span)
 
 	if g.HandlersTimeout != 0 {
@@ -101,20 +143,28 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				err = err.WithHTTPCode(http.StatusGatewayTimeout)
 			}
 			response = g.handleError(pipelineCtx, request, err)
+			timings.handlers.end()
 			span.Finish()
 			goto finish
 		case response = <-responseCh:
 			if response != nil {
+				timings.handlers.end()
 				span.Finish()
 				goto finish
 			}
 		}
 	}
+	timings.handlers.end()
 	span.Finish()
 	ctx = ctx.WithSpan(parent)
 
+	if g.inMaintenance(ctx, request, "") {
+		response, err = g.handleMaintenance(ctx, request)
+		goto finish
+	}
+
 	span = ctx.StartSpan("FindEndpoint")
-	endpoint, request.PathParams, tsr, err = g.tree.getValue(path)
+	endpoint, request.PathParams, tsr, err = g.routingTree(request.Host).getValue(path)
 	span.Finish()
 
 	if err != nil {
@@ -128,9 +178,21 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		goto finish
 	}
 
+	request.Service = endpoint.serviceName
+	request.Route = endpoint.Route
+
+	if g.inMaintenance(ctx, request, endpoint.serviceName) {
+		response, err = g.handleMaintenance(ctx, request)
+		goto finish
+	}
+
 	switch request.Method {
 	case http.MethodHead:
 		pipeline = endpoint.Head
+		if endpoint.implicitHead() {
+			pipeline = endpoint.Get
+			headOnly = true
+		}
 	case http.MethodGet:
 		pipeline = endpoint.Get
 	case http.MethodPut:
@@ -149,17 +211,31 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		pipeline = endpoint.Trace
 	}
 
+	for _, param := range request.PathParams {
+		if param.Err != nil {
+			malformed = true
+			break
+		}
+	}
+
 	if pipeline == nil {
-		if tsr {
+		if tsr || malformed {
 			response, err = g.handleNotFound(ctx, request)
+		} else if request.Method == http.MethodOptions {
+			response = endpoint.handleOptions(ctx, request)
 		} else {
 			response, err = endpoint.handleNotAllowed(ctx, request)
 		}
 		goto finish
 	}
 
+	// a copy so a handler modifying it can't affect the
+	// pipeline or other requests
+	policy = pipeline.Policy
+	request.Policy = &policy
+
 	if tsr {
-		if path != "/" && pipeline.Policy.AllowTrailingSlashRedirects {
+		if path != "/" && httpx.IsTrue(pipeline.Policy.AllowTrailingSlashRedirects) {
 			response, err = endpoint.handleRedirect(ctx, request)
 		} else {
 			response, err = g.handleNotFound(ctx, request)
@@ -167,7 +243,16 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		goto finish
 	}
 
-	if !parseOK && !pipeline.Policy.AllowMalformedQueryParameters {
+	if malformed {
+		if httpx.IsTrue(pipeline.Policy.RejectMalformedPathParameters) {
+			response, err = endpoint.handleBadQuery(ctx, request)
+		} else {
+			response, err = g.handleNotFound(ctx, request)
+		}
+		goto finish
+	}
+
+	if !parseOK && !httpx.IsTrue(pipeline.Policy.AllowMalformedQueryParameters) {
 		response, err = endpoint.handleBadQuery(ctx, request)
 		goto finish
 	}
@@ -180,18 +265,18 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		span.Finish()
 		goto finish
-	} else if malformed && !pipeline.Policy.AllowMalformedQueryParameters {
+	} else if malformed && !httpx.IsTrue(pipeline.Policy.AllowMalformedQueryParameters) {
 		response, err = endpoint.handleBadQuery(ctx, request)
 		span.Finish()
 		goto finish
-	} else if unknown && !pipeline.Policy.AllowUnknownQueryParameters {
+	} else if unknown && !httpx.IsTrue(pipeline.Policy.AllowUnknownQueryParameters) {
 		response, err = endpoint.handleBadQuery(ctx, request)
 		span.Finish()
 		goto finish
 	}
 	span.Finish()
 
-	if !pipeline.Policy.PreserveEscapedPathParameters {
+	if !httpx.IsTrue(pipeline.Policy.PreserveEscapedPathParameters) {
 		for i := range request.PathParams {
 			if esc, r := url.PathUnescape(request.PathParams[i].Value); r == nil {
 				request.PathParams[i].Value = esc
@@ -199,16 +284,36 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	span = ctx.StartSpan("ReadRequestBody")
+	body, err = limitBody(ctx, request, pipeline.Policy)
+	if err != nil {
+		response = g.handleEndpointError(endpoint, ctx, request, err)
+		span.Finish()
+		goto finish
+	}
+	span.Finish()
+
 	span = ctx.StartSpan("RunPipelineHandlers")
+	timings.pipeline.begin()
 	pipelineCtx = ctx
 	pipelineCtx.WithSpan(span)
 
-	if pipeline.Policy.TimeBudget != 0 {
+	if pipeline.Policy.TimeBudget > 0 {
 		var subCancel stdctx.CancelFunc
 		pipelineCtx, subCancel = pipelineCtx.WithTimeout(pipeline.Policy.TimeBudget - ri.Elapsed())
 		defer subCancel()
 	}
 
+	if l := endpoint.limiters[pipeline]; l != nil {
+		if !l.acquire(pipelineCtx) {
+			response, err = endpoint.handleOverloaded(pipelineCtx, request)
+			timings.pipeline.end()
+			span.Finish()
+			goto finish
+		}
+		defer l.release()
+	}
+
 endpointHandlers:
 	for _, handler := range pipeline.Handlers {
 		go func() {
@@ -227,6 +332,7 @@ endpointHandlers:
 				err = err.WithHTTPCode(http.StatusGatewayTimeout)
 			}
 			response = g.handleEndpointError(endpoint, pipelineCtx, request, err)
+			timings.pipeline.end()
 			span.Finish()
 			goto finish
 		case response = <-responseCh:
@@ -239,10 +345,14 @@ endpointHandlers:
 			}
 		}
 	}
+	timings.pipeline.end()
 	span.Finish()
 	ctx = ctx.WithSpan(parent)
 
-	if response == nil {
+	if body != nil && body.failure() != nil {
+		err = body.failure()
+		response = g.handleEndpointError(endpoint, pipelineCtx, request, err)
+	} else if response == nil {
 		err = merry.New("gateway: route: no response from pipeline")
 		response = g.handleEndpointError(endpoint, pipelineCtx, request, err)
 	}
@@ -250,7 +360,12 @@ endpointHandlers:
 finish:
 	ctx = ctx.WithSpan(parent)
 
+	if !merry.Is(ctx.Err(), stdctx.Canceled) {
+		response = g.runResponseHandlers(ctx, request, pipeline, response)
+	}
+
 	span = ctx.StartSpan("SerializeResponse")
+	timings.serialization.begin()
 	var (
 		writeErr merry.Error
 		snapshot httpx.ResponseSnapshot
@@ -261,7 +376,11 @@ finish:
 		span.LogFields(otlog.String("error", writeErr.Error()))
 		snapshot = ri.Snapshot()
 	} else {
-		writeErr = ri.WriteResponse(response)
+		if headOnly {
+			writeErr = ri.WriteHeadResponse(response)
+		} else {
+			writeErr = ri.WriteResponse(response)
+		}
 		writeErr = merry.Prepend(writeErr, "gateway: route: serialize response")
 		if writeErr != nil {
 			ext.Error.Set(span, true)
@@ -269,10 +388,18 @@ finish:
 		}
 		snapshot = ri.Flush()
 	}
+	timings.serialization.end()
 	span.Finish()
+	snapshot.Variant = request.Variant
+	snapshot.Service = request.Service
+	snapshot.Route = request.Route
+	snapshot.Policy = request.Policy
 
 	ext.HTTPStatusCode.Set(parent, uint16(snapshot.StatusCode))
 
+	failed := err != nil || writeErr != nil || response.Err() != nil
+	recordMetrics(request, snapshot, &timings, failed)
+
 	g.invokeCompletionHookSafely(ctx, request, snapshot)
 
 	if idErr != nil {
@@ -299,6 +426,29 @@ func (g *Gateway) generateRequestID(ctx context.Context, request *httpx.Request)
 	span := ctx.StartSpan("GenerateRequestID")
 	defer span.Finish()
 
+	var validationErr merry.Error
+	if g.RequestIDValidator != nil {
+		if inbound := request.Header.Get(g.RequestIDHeaderName); inbound != "" {
+			ok, exception := g.RequestIDValidator.InvokeSafely(ctx, request, inbound)
+			if exception != nil {
+				validationErr = exception.Prepend("gateway: route: validate request id")
+				span.LogFields(otlog.String("exception", validationErr.Error()))
+			} else if ok {
+				span.SetTag("inbound", true)
+				return inbound, nil
+			}
+		}
+	}
+
+	requestID, err := g.newRequestID(ctx, span, request)
+	if err == nil {
+		err = validationErr
+	}
+
+	return requestID, err
+}
+
+func (g *Gateway) newRequestID(ctx context.Context, span opentracing.Span, request *httpx.Request) (string, merry.Error) {
 	if g.RequestIDGenerator == nil {
 		return request.ID(), nil
 	}
@@ -321,6 +471,39 @@ func (g *Gateway) generateRequestID(ctx context.Context, request *httpx.Request)
 	return requestID, err
 }
 
+// runResponseHandlers invokes the response handlers of the
+// pipeline, if any, followed by those of the gateway.
+func (g *Gateway) runResponseHandlers(ctx context.Context, request *httpx.Request, pipeline *service.Pipeline, response httpx.Response) httpx.Response {
+	var handlers []httpx.ResponseHandler
+	if pipeline != nil {
+		handlers = pipeline.ResponseHandlers
+	}
+	if len(handlers) == 0 && len(g.ResponseHandlers) == 0 {
+		return response
+	}
+
+	span := ctx.StartSpan("RunResponseHandlers")
+	defer span.Finish()
+
+	for _, handlers := range [][]httpx.ResponseHandler{handlers, g.ResponseHandlers} {
+		for _, handler := range handlers {
+			result, exception := handler.InvokeSafely(ctx, request, response)
+			if exception != nil {
+				exception = exception.Prepend("gateway: route: run response handler")
+				ext.Error.Set(span, true)
+				span.LogFields(otlog.String("exception", exception.Error()))
+				g.invokeErrorHookSafely(ctx, request, exception)
+				continue
+			}
+			if result != nil {
+				response = result
+			}
+		}
+	}
+
+	return response
+}
+
 func (g *Gateway) handleNotFound(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
 	if g.NotFoundHandler == nil {
 		return httpx.NewEmpty(http.StatusNotFound), nil
@@ -335,6 +518,20 @@ func (g *Gateway) handleNotFound(ctx context.Context, request *httpx.Request) (h
 	return response, nil
 }
 
+func (g *Gateway) handleOverloaded(ctx context.Context, request *httpx.Request) (httpx.Response, merry.Error) {
+	if g.OverloadedHandler == nil {
+		return httpx.NewEmpty(http.StatusServiceUnavailable), nil
+	}
+
+	response, exception := g.OverloadedHandler.InvokeSafely(ctx, request)
+	if exception != nil {
+		err := exception.Prepend("gateway: route: run OverloadedHandler")
+		return httpx.NewEmpty(http.StatusServiceUnavailable), err
+	}
+
+	return response, nil
+}
+
 func (g *Gateway) handleError(ctx context.Context, request *httpx.Request, err merry.Error) httpx.Response {
 	if g.InternalServerErrorHandler == nil {
 		return httpx.NewEmptyError(merry.HTTPCode(err), err)
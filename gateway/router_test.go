@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/ansel1/merry"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/shisa-platform/core/authn"
@@ -17,6 +19,7 @@ import (
 	"github.com/shisa-platform/core/middleware"
 	"github.com/shisa-platform/core/models"
 	"github.com/shisa-platform/core/service"
+	"github.com/shisa-platform/core/tracecontext"
 )
 
 func failingResponse(status int) httpx.Response {
@@ -272,6 +275,240 @@ func TestRouterDefaultRequestIDGenerator(t *testing.T) {
 	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
 }
 
+func TestRouterInboundRequestIDAccepted(t *testing.T) {
+	var validatorCalled bool
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		RequestIDValidator: func(_ context.Context, _ *httpx.Request, id string) bool {
+			validatorCalled = true
+			assert.Equal(t, "zalgo-he-comes", id)
+			return true
+		},
+		RequestIDGenerator: func(context.Context, *httpx.Request) (string, merry.Error) {
+			t.Fatal("unexpected call to request id generator")
+			return "", nil
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		assert.Equal(t, "zalgo-he-comes", ctx.RequestID())
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installHandler(t, cut, handler)
+
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	r.Header.Set(cut.RequestIDHeaderName, "zalgo-he-comes")
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	assert.True(t, validatorCalled, "request id validator not called")
+	assert.True(t, handlerCalled, "handler not called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "zalgo-he-comes", w.HeaderMap.Get(cut.RequestIDHeaderName))
+}
+
+func TestRouterInboundRequestIDRejected(t *testing.T) {
+	var validatorCalled bool
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		RequestIDValidator: func(context.Context, *httpx.Request, string) bool {
+			validatorCalled = true
+			return false
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	r.Header.Set(cut.RequestIDHeaderName, "zalgo-he-comes")
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	assert.True(t, validatorCalled, "request id validator not called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
+	assert.NotEqual(t, "zalgo-he-comes", w.HeaderMap.Get(cut.RequestIDHeaderName))
+}
+
+func TestRouterInboundRequestIDValidatorPanic(t *testing.T) {
+	var validatorCalled bool
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		RequestIDValidator: func(context.Context, *httpx.Request, string) bool {
+			validatorCalled = true
+			panic(merry.New("i blewed up!"))
+		},
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	r.Header.Set(cut.RequestIDHeaderName, "zalgo-he-comes")
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	assert.True(t, validatorCalled, "request id validator not called")
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
+	assert.NotEqual(t, "zalgo-he-comes", w.HeaderMap.Get(cut.RequestIDHeaderName))
+}
+
+func TestRouterInboundRequestIDIgnored(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	r.Header.Set(cut.RequestIDHeaderName, "zalgo-he-comes")
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
+	assert.NotEqual(t, "zalgo-he-comes", w.HeaderMap.Get(cut.RequestIDHeaderName))
+}
+
+func TestRouterTraceContextInbound(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var (
+		handlerCalled bool
+		spanID        string
+	)
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		tc, ok := tracecontext.FromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tc.TraceID.String())
+		assert.NotEqual(t, "00f067aa0ba902b7", tc.ParentID.String())
+		assert.True(t, tc.Sampled())
+		assert.Equal(t, "congo=t61rcWkgMzE", tc.State)
+		spanID = tc.ParentID.String()
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installHandler(t, cut, handler)
+
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	assert.True(t, handlerCalled, "handler not called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var parent *mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName == "ServiceRequest" {
+			parent = span
+		}
+	}
+	if assert.NotNil(t, parent) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.Tag("w3c.trace_id"))
+		assert.Equal(t, "00f067aa0ba902b7", parent.Tag("w3c.parent_id"))
+		assert.Equal(t, spanID, parent.Tag("w3c.span_id"))
+	}
+}
+
+func TestRouterTraceContextNew(t *testing.T) {
+	for name, traceparent := range map[string]string{
+		"missing":   "",
+		"malformed": "00-zalgo",
+	} {
+		errHook := new(mockErrorHook)
+		cut := &Gateway{
+			ErrorHook: errHook.Handle,
+		}
+		cut.init()
+
+		var handlerCalled bool
+		handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+			handlerCalled = true
+			tc, ok := tracecontext.FromContext(ctx)
+			assert.True(t, ok, name)
+			assert.True(t, tc.TraceID.IsValid(), name)
+			assert.False(t, tc.Sampled(), name)
+			assert.Empty(t, tc.State, name)
+			return httpx.NewEmpty(http.StatusOK)
+		}
+		installHandler(t, cut, handler)
+
+		r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+		if traceparent != "" {
+			r.Header.Set("traceparent", traceparent)
+		}
+		r.Header.Set("tracestate", "congo=t61rcWkgMzE")
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, r)
+
+		assert.True(t, handlerCalled, name)
+		errHook.assertNotCalled(t)
+		assert.Equal(t, http.StatusOK, w.Code, name)
+	}
+}
+
+func TestRouterTracerParent(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	upstream := tracer.StartSpan("upstream")
+	r := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	carrier := opentracing.HTTPHeadersCarrier(r.Header)
+	assert.NoError(t, tracer.Inject(upstream.Context(), opentracing.HTTPHeaders, carrier))
+
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, r)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var parent *mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName == "ServiceRequest" {
+			parent = span
+		}
+	}
+	assert.NotNil(t, parent)
+	upstreamCtx := upstream.Context().(mocktracer.MockSpanContext)
+	assert.Equal(t, upstreamCtx.TraceID, parent.SpanContext.TraceID)
+	assert.Equal(t, upstreamCtx.SpanID, parent.ParentID)
+	assert.NotEmpty(t, parent.Tag("w3c.trace_id"))
+}
+
 func TestRouterCustomRequestIDHeaderKey(t *testing.T) {
 	headerKey := "x-zalgo"
 	errHook := new(mockErrorHook)
@@ -555,6 +792,146 @@ func TestRouterHeadMethod(t *testing.T) {
 	assert.Equal(t, 0, w.Body.Len())
 }
 
+func TestRouterImplicitHeadMethod(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		response := httpx.NewOK(nil)
+		response.Headers().Set("x-zalgo", "he comes")
+		return response
+	}
+
+	policy := service.Policy{AllowImplicitHead: httpx.Bool(true)}
+	installHandlersWithPolicy(t, cut, policy, handler)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodHead, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	assert.True(t, handlerCalled, "handler not called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+	assert.Equal(t, "he comes", w.HeaderMap.Get("x-zalgo"))
+	assert.Equal(t, "5", w.HeaderMap.Get(httpx.ContentLengthHeaderKey))
+
+	w = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodOptions, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HEAD, GET, OPTIONS", w.HeaderMap.Get(httpx.AllowHeaderKey))
+}
+
+func TestRouterImplicitHeadMethodForbidden(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	installHandler(t, cut, handler)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodHead, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	assert.False(t, handlerCalled, "handler called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.HeaderMap.Get(httpx.AllowHeaderKey))
+}
+
+func TestRouterConstrainedPathParameter(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var id string
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		id = r.PathParams[0].Value
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	endpoint := service.GetEndpoint("/users/:id{uint}", handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	cut.ServeHTTP(w, request)
+
+	assert.Equal(t, "42", id)
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterMalformedPathParameter(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	endpoint := service.GetEndpoint("/users/:id{uint}", handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodOptions} {
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(method, "/users/bob", nil)
+		cut.ServeHTTP(w, request)
+
+		assert.False(t, handlerCalled, "handler called")
+		errHook.assertNotCalled(t)
+		assert.Equal(t, http.StatusNotFound, w.Code, method)
+	}
+}
+
+func TestRouterMalformedPathParameterRejected(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var handlerCalled bool
+	handler := func(ctx context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		return httpx.NewEmpty(http.StatusOK)
+	}
+
+	policy := service.Policy{RejectMalformedPathParameters: httpx.Bool(true)}
+	endpoint := service.GetEndpointWithPolicy("/users/:id{uint}", policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/users/bob", nil)
+	cut.ServeHTTP(w, request)
+
+	assert.False(t, handlerCalled, "handler called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestRouterGetMethod(t *testing.T) {
 	errHook := new(mockErrorHook)
 	cut := &Gateway{
@@ -784,6 +1161,59 @@ func TestRouterBadMethod(t *testing.T) {
 
 	errHook.assertNotCalled(t)
 	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.HeaderMap.Get(httpx.AllowHeaderKey))
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestRouterBadMethodAllowHeader(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	pipeline := &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+	endpoint := service.Endpoint{
+		Route:  expectedRoute,
+		Head:   pipeline,
+		Get:    pipeline,
+		Post:   pipeline,
+		Delete: pipeline,
+		Trace:  pipeline,
+	}
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "HEAD, GET, POST, DELETE, OPTIONS, TRACE", w.HeaderMap.Get(httpx.AllowHeaderKey))
+}
+
+func TestRouterAutomaticOptionsMethod(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	pipeline := &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+	endpoint := service.Endpoint{
+		Route: expectedRoute,
+		Get:   pipeline,
+		Put:   pipeline,
+	}
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodOptions, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "GET, PUT, OPTIONS", w.HeaderMap.Get(httpx.AllowHeaderKey))
 	assert.Equal(t, 0, w.Body.Len())
 }
 
@@ -809,6 +1239,7 @@ func TestRouterBadMethodCustomHandler(t *testing.T) {
 	assert.True(t, handlerCalled, "handler not called")
 	errHook.assertNotCalled(t)
 	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.HeaderMap.Get(httpx.AllowHeaderKey))
 	assert.Equal(t, 0, w.Body.Len())
 }
 
@@ -834,6 +1265,7 @@ func TestRouterBadMethodCustomHandlerPanic(t *testing.T) {
 	assert.True(t, handlerCalled, "handler not called")
 	errHook.assertCalledN(t, 1)
 	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.HeaderMap.Get(httpx.AllowHeaderKey))
 	assert.Equal(t, 0, w.Body.Len())
 }
 
@@ -982,7 +1414,7 @@ func TestRouterExtraSlashRedirectAllowed(t *testing.T) {
 	}
 	cut.init()
 
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	installHandlersWithPolicy(t, cut, policy, dummyHandler)
 
 	w := httptest.NewRecorder()
@@ -996,6 +1428,27 @@ func TestRouterExtraSlashRedirectAllowed(t *testing.T) {
 	assert.Equal(t, expectedRoute, w.HeaderMap.Get(httpx.LocationHeaderKey))
 }
 
+func TestRouterExtraSlashRedirectOverriddenByEndpoint(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(false)}
+	svc := newFakeService(newEndpointsWithPolicy(policy, dummyHandler))
+	svc.Policy = service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
+	installService(t, cut, svc)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, expectedRoute+"/", nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.HeaderMap.Get(httpx.LocationHeaderKey))
+}
+
 func TestRouterExtraSlashRedirectForbiddenCustomHandler(t *testing.T) {
 	errHook := new(mockErrorHook)
 	cut := &Gateway{
@@ -1030,7 +1483,7 @@ func TestRouterExtraSlashRedirectAllowedCustomHandler(t *testing.T) {
 	cut.init()
 
 	var handlerCalled bool
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	svc := newFakeService(newEndpointsWithPolicy(policy, dummyHandler))
 	svc.RedirectHandler = func(context.Context, *httpx.Request) httpx.Response {
 		handlerCalled = true
@@ -1057,7 +1510,7 @@ func TestRouterExtraSlashRedirectAllowedCustomHandlerPanic(t *testing.T) {
 	cut.init()
 
 	var handlerCalled bool
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	svc := newFakeService(newEndpointsWithPolicy(policy, dummyHandler))
 	svc.RedirectHandler = func(context.Context, *httpx.Request) httpx.Response {
 		handlerCalled = true
@@ -1130,7 +1583,7 @@ func TestRouterMissingSlashRedirectAllowed(t *testing.T) {
 
 	route := expectedRoute + "/"
 
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(route, policy, dummyHandler)
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
 
@@ -1153,7 +1606,7 @@ func TestRouterMissingSlashRedirectAllowedForPutMethod(t *testing.T) {
 
 	route := expectedRoute + "/"
 
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	endpoint := service.PutEndpointWithPolicy(route, policy, dummyHandler)
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
 
@@ -1201,7 +1654,7 @@ func TestRouterMissingSlashRedirectAllowedCustomHandler(t *testing.T) {
 	cut.init()
 
 	var handlerCalled bool
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute+"/", policy, dummyHandler)
 	svc := newFakeService([]service.Endpoint{endpoint})
 	svc.RedirectHandler = func(context.Context, *httpx.Request) httpx.Response {
@@ -1228,7 +1681,7 @@ func TestRouterMissingSlashRedirectAllowedCustomHandlerPanic(t *testing.T) {
 	cut.init()
 
 	var handlerCalled bool
-	policy := service.Policy{AllowTrailingSlashRedirects: true}
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute+"/", policy, dummyHandler)
 	svc := newFakeService([]service.Endpoint{endpoint})
 	svc.RedirectHandler = func(context.Context, *httpx.Request) httpx.Response {
@@ -1298,7 +1751,7 @@ func TestRouterPathParamtersPreserveEscaping(t *testing.T) {
 		return httpx.NewEmpty(http.StatusOK)
 	}
 
-	policy := service.Policy{PreserveEscapedPathParameters: true}
+	policy := service.Policy{PreserveEscapedPathParameters: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy("/:outer/:inner", policy, handler)
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
 
@@ -1438,7 +1891,7 @@ func TestRouterQueryParametersAllowMalformed(t *testing.T) {
 	}
 
 	endpoint := service.GetEndpoint(expectedRoute, handler)
-	endpoint.Get.Policy = service.Policy{AllowMalformedQueryParameters: true}
+	endpoint.Get.Policy = service.Policy{AllowMalformedQueryParameters: httpx.Bool(true)}
 	endpoint.Get.QuerySchemas = []httpx.ParameterSchema{{Name: "good"}, {Name: "bad"}}
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
 
@@ -1555,7 +2008,7 @@ func TestRouterQueryParametersRequiredFieldMissingAllowMalformed(t *testing.T) {
 		return httpx.NewEmpty(http.StatusOK)
 	}
 
-	policy := service.Policy{AllowMalformedQueryParameters: true}
+	policy := service.Policy{AllowMalformedQueryParameters: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute, policy, handler)
 	endpoint.Get.QuerySchemas = []httpx.ParameterSchema{
 		{Name: "zalgo", Required: true},
@@ -1668,7 +2121,7 @@ func TestRouterQueryParametersWithFieldMalformedQueryAllowMalformed(t *testing.T
 		return httpx.NewEmpty(http.StatusOK)
 	}
 
-	policy := service.Policy{AllowMalformedQueryParameters: true}
+	policy := service.Policy{AllowMalformedQueryParameters: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute, policy, handler)
 	endpoint.Get.QuerySchemas = []httpx.ParameterSchema{{Name: "zalgo"}, {Name: "waits"}}
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
@@ -1920,7 +2373,7 @@ func TestRouterQueryParametersFieldValidationFailsAllowMalformed(t *testing.T) {
 		return httpx.NewEmpty(http.StatusOK)
 	}
 
-	policy := service.Policy{AllowMalformedQueryParameters: true}
+	policy := service.Policy{AllowMalformedQueryParameters: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute, policy, handler)
 	validator := func(p httpx.QueryParameter) merry.Error {
 		if p.Values[0] != "he:comes" {
@@ -2009,7 +2462,7 @@ func TestRouterQueryParametersWithFieldUnknownParameterAllow(t *testing.T) {
 		return httpx.NewEmpty(http.StatusOK)
 	}
 
-	policy := service.Policy{AllowUnknownQueryParameters: true}
+	policy := service.Policy{AllowUnknownQueryParameters: httpx.Bool(true)}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute, policy, handler)
 	endpoint.Get.QuerySchemas = []httpx.ParameterSchema{{Name: "zalgo"}, {Name: "waits"}}
 	installEndpoints(t, cut, []service.Endpoint{endpoint})
@@ -2061,8 +2514,8 @@ func TestRouterQueryParametersWithFieldUnknownInvalidParameterAllow(t *testing.T
 	}
 
 	policy := service.Policy{
-		AllowMalformedQueryParameters: true,
-		AllowUnknownQueryParameters:   true,
+		AllowMalformedQueryParameters: httpx.Bool(true),
+		AllowUnknownQueryParameters:   httpx.Bool(true),
 	}
 	endpoint := service.GetEndpointWithPolicy(expectedRoute, policy, handler)
 	endpoint.Get.QuerySchemas = []httpx.ParameterSchema{{Name: "zalgo"}, {Name: "waits"}}
@@ -2518,6 +2971,123 @@ func TestRouter(t *testing.T) {
 	assert.NotEmpty(t, w.HeaderMap.Get(cut.RequestIDHeaderName))
 }
 
+func TestRouterRecordsMatchedRoute(t *testing.T) {
+	errHook := new(mockErrorHook)
+	policy := service.Policy{AllowUnknownQueryParameters: httpx.Bool(true)}
+	var handlerCalled, completionHookCalled bool
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		CompletionHook: func(_ context.Context, r *httpx.Request, s httpx.ResponseSnapshot) {
+			completionHookCalled = true
+			assert.Equal(t, "test", s.Service)
+			assert.Equal(t, "/users/:id", s.Route)
+			if assert.NotNil(t, s.Policy) {
+				assert.Equal(t, policy, *s.Policy)
+			}
+			assert.Equal(t, "/users/:id", r.Route)
+		},
+	}
+	cut.init()
+
+	handler := func(_ context.Context, r *httpx.Request) httpx.Response {
+		handlerCalled = true
+		assert.Equal(t, "test", r.Service)
+		assert.Equal(t, "/users/:id", r.Route)
+		if assert.NotNil(t, r.Policy) {
+			assert.True(t, httpx.IsTrue(r.Policy.AllowUnknownQueryParameters))
+		}
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	endpoint := service.GetEndpointWithPolicy("/users/:id", policy, handler)
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/123?zalgo=he+comes", nil))
+
+	assert.True(t, handlerCalled, "handler not called")
+	assert.True(t, completionHookCalled, "completion hook not called")
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRouterPolicyCopiedPerRequest(t *testing.T) {
+	errHook := new(mockErrorHook)
+	var snapshots []*httpx.Policy
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		CompletionHook: func(_ context.Context, _ *httpx.Request, s httpx.ResponseSnapshot) {
+			snapshots = append(snapshots, s.Policy)
+		},
+	}
+	cut.init()
+
+	handler := func(_ context.Context, r *httpx.Request) httpx.Response {
+		assert.False(t, httpx.IsTrue(r.Policy.AllowUnknownQueryParameters))
+		r.Policy.AllowUnknownQueryParameters = httpx.Bool(true)
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installHandler(t, cut, handler)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	errHook.assertNotCalled(t)
+	if assert.Len(t, snapshots, 2) {
+		assert.False(t, snapshots[0] == snapshots[1], "policy shared between requests")
+	}
+}
+
+func TestRouterRecordsMatchedRouteNotAllowed(t *testing.T) {
+	errHook := new(mockErrorHook)
+	var completionHookCalled bool
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		CompletionHook: func(_ context.Context, _ *httpx.Request, s httpx.ResponseSnapshot) {
+			completionHookCalled = true
+			assert.Equal(t, http.StatusMethodNotAllowed, s.StatusCode)
+			assert.Equal(t, "test", s.Service)
+			assert.Equal(t, expectedRoute, s.Route)
+			assert.Nil(t, s.Policy)
+		},
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodPut, expectedRoute, nil))
+
+	assert.True(t, completionHookCalled, "completion hook not called")
+	errHook.assertNotCalled(t)
+}
+
+func TestRouterRecordsUnmatchedRoute(t *testing.T) {
+	errHook := new(mockErrorHook)
+	var completionHookCalled bool
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		CompletionHook: func(_ context.Context, _ *httpx.Request, s httpx.ResponseSnapshot) {
+			completionHookCalled = true
+			assert.Equal(t, http.StatusNotFound, s.StatusCode)
+			assert.Empty(t, s.Service)
+			assert.Empty(t, s.Route)
+			assert.Nil(t, s.Policy)
+		},
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/zalgo", nil))
+
+	assert.True(t, completionHookCalled, "completion hook not called")
+	errHook.assertNotCalled(t)
+}
+
 func TestRouterNoCompletionHook(t *testing.T) {
 	errHook := new(mockErrorHook)
 	cut := &Gateway{
@@ -2824,3 +3394,203 @@ func TestGatewayHandlerResponseWithError(t *testing.T) {
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 	assert.Equal(t, 0, w.Body.Len())
 }
+
+func TestRouterHosts(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	newService := func(name string, status int, hosts ...string) *service.Service {
+		handler := func(context.Context, *httpx.Request) httpx.Response {
+			return httpx.NewEmpty(status)
+		}
+		return &service.Service{
+			Name:      name,
+			Hosts:     hosts,
+			Endpoints: []service.Endpoint{service.GetEndpoint(expectedRoute, handler)},
+		}
+	}
+
+	services := []*service.Service{
+		newService("api", http.StatusOK, "api.example.com"),
+		newService("admin", http.StatusAccepted, "Admin.Example.com"),
+		newService("tenants", http.StatusCreated, "*.example.com"),
+		newService("deep", http.StatusNonAuthoritativeInfo, "*.eu.example.com"),
+		newService("fallback", http.StatusTeapot),
+	}
+	if err := cut.installServices(services); err != nil {
+		t.Fatalf("install services failed: %v", err)
+	}
+
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"api.example.com", http.StatusOK},
+		{"API.example.com:8080", http.StatusOK},
+		{"admin.example.com", http.StatusAccepted},
+		{"acme.example.com", http.StatusCreated},
+		{"acme.us.example.com", http.StatusCreated},
+		{"acme.eu.example.com", http.StatusNonAuthoritativeInfo},
+		{"example.com", http.StatusTeapot},
+		{"example.org", http.StatusTeapot},
+		{"", http.StatusTeapot},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+		request.Host = test.host
+		cut.ServeHTTP(w, request)
+
+		assert.Equal(t, test.status, w.Code, test.host)
+	}
+	errHook.assertNotCalled(t)
+}
+
+func TestRouterHostsNoFallback(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	svc := newFakeService(newEndpoints(dummyHandler))
+	svc.Hosts = []string{"api.example.com"}
+	installService(t, cut, svc)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	request.Host = "admin.example.com"
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func appendHeader(value string) httpx.ResponseHandler {
+	return func(_ context.Context, _ *httpx.Request, response httpx.Response) httpx.Response {
+		response.Headers().Add("X-Order", value)
+		return nil
+	}
+}
+
+func TestRouterResponseHandlers(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook:        errHook.Handle,
+		ResponseHandlers: []httpx.ResponseHandler{appendHeader("gateway")},
+	}
+	cut.init()
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	endpoint.Get.ResponseHandlers = []httpx.ResponseHandler{appendHeader("pipeline")}
+	svc := &service.Service{
+		Name:             "test",
+		Endpoints:        []service.Endpoint{endpoint},
+		ResponseHandlers: []httpx.ResponseHandler{appendHeader("service")},
+	}
+	installService(t, cut, svc)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"pipeline", "service", "gateway"}, w.Header()["X-Order"])
+}
+
+func TestRouterResponseHandlersReplaceResponse(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	endpoint.Get.ResponseHandlers = []httpx.ResponseHandler{
+		func(context.Context, *httpx.Request, httpx.Response) httpx.Response {
+			return httpx.NewEmpty(http.StatusTeapot)
+		},
+	}
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func TestRouterResponseHandlersNotFound(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook:        errHook.Handle,
+		ResponseHandlers: []httpx.ResponseHandler{appendHeader("gateway")},
+	}
+	cut.init()
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	endpoint.Get.ResponseHandlers = []httpx.ResponseHandler{appendHeader("pipeline")}
+	installEndpoints(t, cut, []service.Endpoint{endpoint})
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"gateway"}, w.Header()["X-Order"])
+}
+
+func TestRouterResponseHandlersPanic(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		ResponseHandlers: []httpx.ResponseHandler{
+			func(context.Context, *httpx.Request, httpx.Response) httpx.Response {
+				panic(merry.New("i blewed up!"))
+			},
+			appendHeader("gateway"),
+		},
+	}
+	cut.init()
+
+	installHandler(t, cut, dummyHandler)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, expectedRoute, nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertCalledN(t, 1)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"gateway"}, w.Header()["X-Order"])
+}
+
+func TestRouterSnapshotVariant(t *testing.T) {
+	errHook := new(mockErrorHook)
+	var variant string
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+		CompletionHook: func(_ context.Context, _ *httpx.Request, s httpx.ResponseSnapshot) {
+			variant = s.Variant
+		},
+	}
+	cut.init()
+
+	handler := func(ctx context.Context, request *httpx.Request) httpx.Response {
+		request.Variant = "canary"
+		return httpx.NewEmpty(http.StatusOK)
+	}
+	installHandler(t, cut, handler)
+
+	w := httptest.NewRecorder()
+	cut.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "canary", variant)
+}
@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+// scope is the configuration an endpoint inherits from its
+// service and enclosing groups.
+type scope struct {
+	prefix           string
+	handlers         []httpx.Handler
+	responseHandlers []httpx.ResponseHandler
+	policy           service.Policy
+}
+
+type scopedEndpoint struct {
+	scope    scope
+	endpoint service.Endpoint
+}
+
+func serviceScope(svc *service.Service) (scope, merry.Error) {
+	if err := checkPrefix(svc.BasePath); err != nil {
+		return scope{}, err.Append(svc.Name).Append(svc.BasePath)
+	}
+
+	return scope{
+		prefix:           svc.BasePath,
+		handlers:         svc.Handlers,
+		responseHandlers: svc.ResponseHandlers,
+		policy:           svc.Policy,
+	}, nil
+}
+
+// nest returns the scope of a group within the receiver.
+func (s scope) nest(group service.Group) (scope, merry.Error) {
+	if err := checkPrefix(group.Prefix); err != nil {
+		return scope{}, err
+	}
+
+	result := scope{
+		prefix:           s.prefix + group.Prefix,
+		handlers:         append(append([]httpx.Handler(nil), s.handlers...), group.Handlers...),
+		responseHandlers: append(append([]httpx.ResponseHandler(nil), group.ResponseHandlers...), s.responseHandlers...),
+		policy:           s.policy.Merge(group.Policy),
+	}
+
+	return result, nil
+}
+
+// scopeEndpoints returns all the endpoints of a service,
+// including those of its groups, with the scope each inherits.
+func scopeEndpoints(svc *service.Service) ([]scopedEndpoint, merry.Error) {
+	root, err := serviceScope(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make([]scopedEndpoint, 0, len(svc.Endpoints))
+	for _, endp := range svc.Endpoints {
+		endpoints = append(endpoints, scopedEndpoint{scope: root, endpoint: endp})
+	}
+
+	return scopeGroups(root, svc.Groups, endpoints, svc.Name)
+}
+
+func scopeGroups(parent scope, groups []service.Group, endpoints []scopedEndpoint, name string) ([]scopedEndpoint, merry.Error) {
+	for _, group := range groups {
+		s, err := parent.nest(group)
+		if err != nil {
+			return nil, err.Append(name).Append(group.Prefix)
+		}
+		if len(group.Endpoints) == 0 && len(group.Groups) == 0 {
+			return nil, merry.New("gateway: check invariants: group endpoints empty").Append(name).Append(s.prefix)
+		}
+
+		for _, endp := range group.Endpoints {
+			endpoints = append(endpoints, scopedEndpoint{scope: s, endpoint: endp})
+		}
+
+		endpoints, err = scopeGroups(s, group.Groups, endpoints, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return endpoints, nil
+}
+
+func checkPrefix(prefix string) merry.Error {
+	if prefix == "" {
+		return nil
+	}
+	if prefix[0] != '/' {
+		return merry.New("gateway: check invariants: prefix must begin with '/'")
+	}
+	if prefix[len(prefix)-1] == '/' {
+		return merry.New("gateway: check invariants: prefix must not end with '/'")
+	}
+
+	return nil
+}
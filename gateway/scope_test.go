@@ -0,0 +1,222 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+func TestCheckPrefix(t *testing.T) {
+	assert.NoError(t, checkPrefix(""))
+	assert.NoError(t, checkPrefix("/v2"))
+	assert.NoError(t, checkPrefix("/v2/accounts"))
+	assert.Error(t, checkPrefix("v2"))
+	assert.Error(t, checkPrefix("/v2/"))
+	assert.Error(t, checkPrefix("/"))
+}
+
+func TestScopeNest(t *testing.T) {
+	parent := scope{
+		prefix:   "/v2",
+		handlers: []httpx.Handler{dummyHandler},
+		policy:   service.Policy{TimeBudget: time.Second},
+	}
+
+	cut, err := parent.nest(service.Group{
+		Prefix:   "/accounts",
+		Handlers: []httpx.Handler{dummyHandler},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "/v2/accounts", cut.prefix)
+	assert.Len(t, cut.handlers, 2)
+	assert.Len(t, parent.handlers, 1)
+	assert.Equal(t, parent.policy, cut.policy)
+
+	policy := service.Policy{AllowImplicitHead: httpx.Bool(true)}
+	cut, err = parent.nest(service.Group{Prefix: "/accounts", Policy: policy})
+	assert.NoError(t, err)
+	assert.Equal(t, service.Policy{AllowImplicitHead: httpx.Bool(true), TimeBudget: time.Second}, cut.policy)
+
+	policy = service.Policy{TimeBudget: time.Minute}
+	cut, err = parent.nest(service.Group{Prefix: "/accounts", Policy: policy})
+	assert.NoError(t, err)
+	assert.Equal(t, policy, cut.policy)
+
+	parent.policy = service.Policy{AllowImplicitHead: httpx.Bool(true), TimeBudget: time.Second}
+	policy = service.Policy{AllowImplicitHead: httpx.Bool(false), TimeBudget: httpx.NoLimit}
+	cut, err = parent.nest(service.Group{Prefix: "/accounts", Policy: policy})
+	assert.NoError(t, err)
+	assert.Equal(t, policy, cut.policy)
+
+	_, err = parent.nest(service.Group{Prefix: "accounts"})
+	assert.Error(t, err)
+}
+
+func TestScopeEndpoints(t *testing.T) {
+	svc := &service.Service{
+		Name:      "test",
+		BasePath:  "/v2",
+		Endpoints: []service.Endpoint{service.GetEndpoint("/status", dummyHandler)},
+		Groups: []service.Group{
+			{
+				Prefix:    "/accounts",
+				Endpoints: []service.Endpoint{service.GetEndpoint("/:id", dummyHandler)},
+				Groups: []service.Group{
+					{
+						Prefix:    "/:id/users",
+						Endpoints: []service.Endpoint{service.GetEndpoint("/:user", dummyHandler)},
+					},
+				},
+			},
+		},
+	}
+
+	endpoints, err := scopeEndpoints(svc)
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 3)
+
+	routes := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		routes[i] = e.scope.prefix + e.endpoint.Route
+	}
+	assert.Equal(t, []string{"/v2/status", "/v2/accounts/:id", "/v2/accounts/:id/users/:user"}, routes)
+}
+
+func TestScopeEndpointsBadBasePath(t *testing.T) {
+	svc := &service.Service{
+		Name:      "test",
+		BasePath:  "/v2/",
+		Endpoints: []service.Endpoint{service.GetEndpoint("/status", dummyHandler)},
+	}
+
+	endpoints, err := scopeEndpoints(svc)
+	assert.Error(t, err)
+	assert.Nil(t, endpoints)
+}
+
+func TestScopeEndpointsEmptyGroup(t *testing.T) {
+	svc := &service.Service{
+		Name:   "test",
+		Groups: []service.Group{{Prefix: "/accounts"}},
+	}
+
+	endpoints, err := scopeEndpoints(svc)
+	assert.Error(t, err)
+	assert.Nil(t, endpoints)
+}
+
+func TestInstallPipelineInheritsPolicy(t *testing.T) {
+	s := scope{policy: service.Policy{TimeBudget: time.Second}}
+
+	pipeline := &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+	result, err := installPipeline(s, pipeline)
+	assert.NoError(t, err)
+	assert.Equal(t, s.policy, result.Policy)
+
+	pipeline.Policy = service.Policy{AllowImplicitHead: httpx.Bool(true)}
+	result, err = installPipeline(s, pipeline)
+	assert.NoError(t, err)
+	assert.Equal(t, service.Policy{AllowImplicitHead: httpx.Bool(true), TimeBudget: time.Second}, result.Policy)
+
+	s.policy = service.Policy{MaxBodySize: 1024, MaxConcurrency: 4, AllowTrailingSlashRedirects: httpx.Bool(true)}
+	pipeline.Policy = service.Policy{TimeBudget: time.Minute}
+	result, err = installPipeline(s, pipeline)
+	assert.NoError(t, err)
+	expected := service.Policy{
+		MaxBodySize:                 1024,
+		MaxConcurrency:              4,
+		AllowTrailingSlashRedirects: httpx.Bool(true),
+		TimeBudget:                  time.Minute,
+	}
+	assert.Equal(t, expected, result.Policy)
+}
+
+func TestInstallPipelineOverridesPolicy(t *testing.T) {
+	s := scope{
+		policy: service.Policy{
+			AllowTrailingSlashRedirects: httpx.Bool(true),
+			MaxBodySize:                 1024,
+			MaxConcurrency:              4,
+			TimeBudget:                  time.Second,
+		},
+	}
+
+	pipeline := &service.Pipeline{
+		Policy: service.Policy{
+			AllowTrailingSlashRedirects: httpx.Bool(false),
+			MaxBodySize:                 httpx.NoLimit,
+			MaxConcurrency:              httpx.NoLimit,
+			TimeBudget:                  httpx.NoLimit,
+		},
+		Handlers: []httpx.Handler{dummyHandler},
+	}
+	result, err := installPipeline(s, pipeline)
+	assert.NoError(t, err)
+	assert.Equal(t, pipeline.Policy, result.Policy)
+	assert.False(t, httpx.IsTrue(result.Policy.AllowTrailingSlashRedirects))
+}
+
+func TestRouterGroups(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+	cut.init()
+
+	var calls []string
+	recorder := func(name string) httpx.Handler {
+		return func(context.Context, *httpx.Request) httpx.Response {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	svc := &service.Service{
+		Name:     "test",
+		BasePath: "/v2",
+		Handlers: []httpx.Handler{recorder("service")},
+		Groups: []service.Group{
+			{
+				Prefix:   "/accounts",
+				Handlers: []httpx.Handler{recorder("group")},
+				Policy:   service.Policy{AllowImplicitHead: httpx.Bool(true)},
+				Endpoints: []service.Endpoint{
+					service.GetEndpoint("/:id", recorder("endpoint"), dummyHandler),
+				},
+			},
+		},
+	}
+	installService(t, cut, svc)
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/v2/accounts/123", nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"service", "group", "endpoint"}, calls)
+
+	w = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodHead, "/v2/accounts/123", nil)
+	cut.ServeHTTP(w, request)
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/accounts/123", nil)
+	cut.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	routes := cut.Routes()
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "/v2/accounts/:id", routes[0].Route)
+}
@@ -3,10 +3,15 @@ package gateway
 import (
 	stdctx "context"
 	"expvar"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
 	"sort"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ansel1/merry"
 
@@ -21,7 +26,21 @@ func (p byName) Len() int           { return len(p) }
 func (p byName) Less(i, j int) bool { return p[i].Name < p[j].Name }
 func (p byName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+type byServiceAndRoute []service.Route
+
+func (p byServiceAndRoute) Len() int      { return len(p) }
+func (p byServiceAndRoute) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byServiceAndRoute) Less(i, j int) bool {
+	if p[i].Service == p[j].Service {
+		return p[i].Route < p[j].Route
+	}
+	return p[i].Service < p[j].Service
+}
+
 func (g *Gateway) Address() string {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
 	if g.listener != nil {
 		return g.listener.Addr().String()
 	}
@@ -37,11 +56,24 @@ func (g *Gateway) ServeTLS(services ...*service.Service) merry.Error {
 	return g.serve(services, true)
 }
 
+// Shutdown gracefully stops the gateway.  The gateway is first
+// marked not ready and its healthcheck and registration are
+// removed from the `Registrar`.  After waiting `DrainDelay` for
+// those changes to propagate the listener is closed and
+// in-flight requests are given up to `GracePeriod` to complete.
+// The number of requests remaining is reported by `InFlight`
+// and the "in_flight" expvar.
 func (g *Gateway) Shutdown() (err error) {
-	if !g.started {
+	if !g.isStarted() {
 		return
 	}
 
+	g.withdraw()
+
+	if g.DrainDelay > 0 {
+		time.Sleep(g.DrainDelay)
+	}
+
 	ctx, cancel := stdctx.WithTimeout(stdctx.Background(), g.GracePeriod)
 	defer cancel()
 
@@ -51,57 +83,141 @@ func (g *Gateway) Shutdown() (err error) {
 		signal.Stop(g.interrupt)
 		close(g.interrupt)
 	}
-	g.started = false
+	g.setStarted(false)
 
 	return
 }
 
+// Handler prepares the gateway to service requests for the
+// given services without listening for connections, returning
+// it as an `http.Handler`.  This allows the gateway to be used
+// in-process, e.g. mounted in another server or exercised by
+// tests.  Listeners, registration, healthchecks and interrupt
+// handling are not used.
+func (g *Gateway) Handler(services ...*service.Service) (http.Handler, merry.Error) {
+	if len(services) == 0 {
+		return nil, merry.New("gateway: check invariants: services empty")
+	}
+	if g.isStarted() {
+		return nil, merry.New("gateway: check invariants: already running")
+	}
+
+	g.init()
+
+	if err := g.parseMaintenanceAllowlist(); err != nil {
+		return nil, err
+	}
+
+	if err := g.installServices(services); err != nil {
+		return nil, err
+	}
+
+	g.setReady(true)
+
+	return g, nil
+}
+
 func (g *Gateway) serve(services []*service.Service, tls bool) (err merry.Error) {
 	if len(services) == 0 {
 		return merry.New("gateway: check invariants: services empty")
 	}
-	if g.started {
+	if g.isStarted() {
 		return merry.New("gateway: check invariants: already running")
 	}
 
 	g.init()
 
+	if err := g.parseMaintenanceAllowlist(); err != nil {
+		return err
+	}
+
+	if g.HandleInterrupt {
+		g.interrupt = make(chan os.Signal, 1)
+		go g.handleInterrupt()
+		signal.Notify(g.interrupt, syscall.SIGINT, syscall.SIGTERM)
+	}
+
 	if err := g.installServices(services); err != nil {
 		return err
 	}
 
-	g.listener, err = httpx.HTTPListenerForAddress(g.Addr)
+	if tls && g.CertificateLoader != nil {
+		if err := g.startCertificateLoader(); err != nil {
+			return err.Prepend("gateway: serve")
+		}
+		defer g.CertificateLoader.Stop()
+	}
+
+	listener, err := httpx.HTTPListenerForAddress(g.Addr)
 	if err != nil {
 		return err.Prepend("gateway: serve")
 	}
+	g.mtx.Lock()
+	g.listener = listener
+	g.mtx.Unlock()
 
-	if err1 := g.registerSafely(); err1 != nil {
-		g.listener.Close()
-		return err1.Prepend("gateway: serve: register gateway")
+	if err := g.listen(); err != nil {
+		return err.Prepend("gateway: serve")
 	}
+	defer g.stopListenerCertificateLoaders()
+
+	g.drainMtx.Lock()
+	g.registered, g.checked, g.withdrawn, g.withdrawErr = false, false, false, nil
+	g.drainMtx.Unlock()
 	defer func() {
-		if err1 := g.deregisterSafely(); err1 != nil && err == nil {
-			err = err1.Prepend("gateway: serve: deregister gateway")
+		if err1 := g.withdraw(); err1 != nil && err == nil {
+			err = err1
 		}
 	}()
 
+	if err1 := g.registerSafely(); err1 != nil {
+		g.closeListeners()
+		return err1.Prepend("gateway: serve: register gateway")
+	}
+	g.drainMtx.Lock()
+	g.registered = true
+	g.drainMtx.Unlock()
+
 	if err1 := g.addHealthcheckSafely(); err1 != nil {
-		g.listener.Close()
+		g.closeListeners()
 		return err1.Prepend("gateway: serve: add healthcheck")
 	}
-	defer func() {
-		if err1 := g.removeHealthcheckSafely(); err1 != nil && err == nil {
-			err = err1.Prepend("gateway: serve: remove healthcheck")
-		}
-	}()
+	g.drainMtx.Lock()
+	g.checked = true
+	g.drainMtx.Unlock()
+
+	g.setStarted(true)
+	g.setReady(true)
+
+	// all listeners share a lifecycle, if any of them stops
+	// abnormally the others are closed
+	results := make(chan error, len(g.listeners))
+	for _, listener := range g.listeners {
+		go func(listener net.Listener) {
+			err := g.base.Serve(listener)
+			if !merry.Is(err, http.ErrServerClosed) {
+				g.base.Close()
+			}
+			results <- err
+		}(listener)
+	}
 
-	g.started = true
 	var err1 error
 	if tls {
 		err1 = g.base.ServeTLS(g.listener, "", "")
 	} else {
 		err1 = g.base.Serve(g.listener)
 	}
+	if !merry.Is(err1, http.ErrServerClosed) {
+		g.base.Close()
+		g.closeListeners()
+	}
+
+	for range g.listeners {
+		if err2 := <-results; merry.Is(err1, http.ErrServerClosed) {
+			err1 = err2
+		}
+	}
 
 	if merry.Is(err1, http.ErrServerClosed) {
 		return nil
@@ -110,129 +226,292 @@ func (g *Gateway) serve(services []*service.Service, tls bool) (err merry.Error)
 	return merry.Prepend(err1, "gateway: serve: abnormal termination")
 }
 
+// startCertificateLoader loads the certificate for ServeTLS and
+// installs the loader in the TLS config of the server.
+func (g *Gateway) startCertificateLoader() merry.Error {
+	loader := g.CertificateLoader
+	if err := g.startLoader(loader); err != nil {
+		return err
+	}
+
+	g.base.TLSConfig = loader.TLSConfig(g.TLSConfig)
+
+	return nil
+}
+
+// Reload replaces the services of a running Gateway, or one
+// prepared by `Handler`.
+// The new services are validated and new routing trees are built
+// before any change is made, so an error leaves the current
+// services in place.  Requests already in progress complete
+// using the services they were routed with.
+func (g *Gateway) Reload(services ...*service.Service) merry.Error {
+	if len(services) == 0 {
+		return merry.New("gateway: check invariants: services empty")
+	}
+
+	g.mtx.RLock()
+	installed := g.installed
+	g.mtx.RUnlock()
+	if !installed {
+		return merry.New("gateway: check invariants: not running")
+	}
+
+	return g.installServices(services)
+}
+
 func (g *Gateway) installServices(services []*service.Service) merry.Error {
-	servicesExpvar := new(expvar.Map)
-	gatewayExpvar.Set("services", servicesExpvar)
+	table, err := buildRoutes(services)
+	if err != nil {
+		return err
+	}
+
+	g.mtx.Lock()
+	g.tree = table.tree
+	g.hosts = table.hosts
+	g.routes = table.routes
+	g.installed = true
+	g.pruneMaintenance()
+	// published under the lock so concurrent reloads can't
+	// leave the stats of one with the routes of another
+	gatewayExpvar.Set("services", table.vars)
+	gatewayExpvar.Set("limits", table.limits)
+	g.mtx.Unlock()
+
+	return nil
+}
+
+func (g *Gateway) isStarted() bool {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	return g.started
+}
+
+func (g *Gateway) setStarted(started bool) {
+	g.mtx.Lock()
+	g.started = started
+	g.mtx.Unlock()
+}
+
+// routingTree returns the tree for the given host, falling back
+// to the tree of services without host restrictions.
+// Exact host names are preferred to wildcards and the wildcard
+// with the longest suffix is preferred to shorter ones.
+func (g *Gateway) routingTree(host string) *node {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	if len(g.hosts) == 0 {
+		return g.tree
+	}
+
+	host = normalizeHost(host)
+	if tree, ok := g.hosts[host]; ok {
+		return tree
+	}
+
+	for i := strings.IndexByte(host, '.'); i != -1; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if tree, ok := g.hosts["*."+host]; ok {
+			return tree
+		}
+	}
+
+	return g.tree
+}
+
+// Routes returns the routes of the installed services ordered
+// by service name and route template.
+func (g *Gateway) Routes() []service.Route {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	return append([]service.Route(nil), g.routes...)
+}
+
+// routingTable is the result of validating and installing a set
+// of services.
+type routingTable struct {
+	tree   *node
+	hosts  map[string]*node
+	routes []service.Route
+	vars   *expvar.Map
+	limits *expvar.Map
+}
+
+func buildRoutes(services []*service.Service) (*routingTable, merry.Error) {
+	table := &routingTable{
+		tree:   new(node),
+		vars:   new(expvar.Map),
+		limits: new(expvar.Map),
+	}
 	for _, svc := range services {
 		if svc.Name == "" {
-			return merry.New("gateway: check invariants: service name empty")
+			return nil, merry.New("gateway: check invariants: service name empty")
 		}
-		if len(svc.Endpoints) == 0 {
-			return merry.New("gateway: check invariants: service endpoints empty").Append(svc.Name)
+		endpoints, err := scopeEndpoints(svc)
+		if err != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return nil, merry.New("gateway: check invariants: service endpoints empty").Append(svc.Name)
+		}
+
+		trees := []*node{table.tree}
+		if len(svc.Hosts) != 0 {
+			trees = trees[:0]
+			if table.hosts == nil {
+				table.hosts = make(map[string]*node)
+			}
+			for _, host := range svc.Hosts {
+				if err := checkHost(host); err != nil {
+					return nil, err.Append(svc.Name).Append(host)
+				}
+				host = normalizeHost(host)
+				hostTree, ok := table.hosts[host]
+				if !ok {
+					hostTree = new(node)
+					table.hosts[host] = hostTree
+				}
+				trees = append(trees, hostTree)
+			}
 		}
 
 		serviceVar := new(expvar.Map)
-		servicesExpvar.Set(svc.Name, serviceVar)
+		table.vars.Set(svc.Name, serviceVar)
+		var limitsVar *expvar.Map
 
-		for i, endp := range svc.Endpoints {
+		for i, scoped := range endpoints {
+			endp := scoped.endpoint
 			if endp.Route == "" {
-				return merry.New("gateway: check invariants: endpoint route emtpy").Append(svc.Name).Append(strconv.Itoa(i))
+				return nil, merry.New("gateway: check invariants: endpoint route emtpy").Append(svc.Name).Append(strconv.Itoa(i))
 			}
 			if endp.Route[0] != '/' {
-				return merry.New("gateway: check invariants: endpoint route must begin with '/'").Append(svc.Name).Append(endp.Route)
+				return nil, merry.New("gateway: check invariants: endpoint route must begin with '/'").Append(svc.Name).Append(endp.Route)
 			}
 
+			route := scoped.scope.prefix + endp.Route
+
 			e := endpoint{
 				Endpoint: service.Endpoint{
-					Route: endp.Route,
+					Route: route,
 				},
 				serviceName:       svc.Name,
 				badQueryHandler:   svc.MalformedRequestHandler,
 				notAllowedHandler: svc.MethodNotAllowedHandler,
 				redirectHandler:   svc.RedirectHandler,
+				overloadedHandler: svc.OverloadedHandler,
 				iseHandler:        svc.InternalServerErrorHandler,
 			}
 
 			foundMethod := false
 			if endp.Head != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Head)
+				pipeline, err := installPipeline(scoped.scope, endp.Head)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodHead)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodHead)
 				}
 				e.Head = pipeline
 			}
 			if endp.Get != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Get)
+				pipeline, err := installPipeline(scoped.scope, endp.Get)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodGet)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodGet)
 				}
 				e.Get = pipeline
 			}
 			if endp.Put != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Put)
+				pipeline, err := installPipeline(scoped.scope, endp.Put)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodPut)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodPut)
 				}
 				e.Put = pipeline
 			}
 			if endp.Post != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Post)
+				pipeline, err := installPipeline(scoped.scope, endp.Post)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodPost)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodPost)
 				}
 				e.Post = pipeline
 			}
 			if endp.Patch != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Patch)
+				pipeline, err := installPipeline(scoped.scope, endp.Patch)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodPatch)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodPatch)
 				}
 				e.Patch = pipeline
 			}
 			if endp.Delete != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Delete)
+				pipeline, err := installPipeline(scoped.scope, endp.Delete)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodDelete)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodDelete)
 				}
 				e.Delete = pipeline
 			}
 			if endp.Connect != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Connect)
+				pipeline, err := installPipeline(scoped.scope, endp.Connect)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodConnect)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodConnect)
 				}
 				e.Connect = pipeline
 			}
 			if endp.Options != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Options)
+				pipeline, err := installPipeline(scoped.scope, endp.Options)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodOptions)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodOptions)
 				}
 				e.Options = pipeline
 			}
 			if endp.Trace != nil {
 				foundMethod = true
-				pipeline, err := installPipeline(svc.Handlers, endp.Trace)
+				pipeline, err := installPipeline(scoped.scope, endp.Trace)
 				if err != nil {
-					return err.Append(svc.Name).Append(endp.Route).Append(http.MethodTrace)
+					return nil, err.Append(svc.Name).Append(route).Append(http.MethodTrace)
 				}
 				e.Trace = pipeline
 			}
 
 			if !foundMethod {
-				return merry.New("gateway: check invariants: endpoint requires least one method").Append(svc.Name).Append(strconv.Itoa(i))
+				return nil, merry.New("gateway: check invariants: endpoint requires least one method").Append(svc.Name).Append(strconv.Itoa(i))
 			}
 
-			if err := g.tree.addRoute(endp.Route, &e); err != nil {
-				return err
+			e.allowed = e.allowedMethods()
+
+			if vars := e.installLimiters(); vars != nil {
+				if limitsVar == nil {
+					limitsVar = new(expvar.Map)
+					table.limits.Set(svc.Name, limitsVar)
+				}
+				limitsVar.Set(e.Route, vars)
+			}
+
+			for _, t := range trees {
+				if err := t.addRoute(route, &e); err != nil {
+					return nil, err
+				}
 			}
 
 			serviceVar.Set(e.Route, e)
+			table.routes = append(table.routes, e.route(svc.Hosts))
 		}
 	}
 
-	return nil
+	sort.Sort(byServiceAndRoute(table.routes))
+
+	return table, nil
 }
 
-func installPipeline(handlers []httpx.Handler, pipeline *service.Pipeline) (*service.Pipeline, merry.Error) {
+// installPipeline returns a copy of the pipeline merged with the
+// handlers and default policy of its scope.
+func installPipeline(s scope, pipeline *service.Pipeline) (*service.Pipeline, merry.Error) {
 	for _, field := range pipeline.QuerySchemas {
 		if field.Default != "" && field.Name == "" {
 			return nil, merry.New("gateway: check invariants: field default requires name")
@@ -240,10 +519,13 @@ func installPipeline(handlers []httpx.Handler, pipeline *service.Pipeline) (*ser
 	}
 
 	result := &service.Pipeline{
-		Policy:       pipeline.Policy,
-		Handlers:     append(handlers, pipeline.Handlers...),
+		Policy:       s.policy.Merge(pipeline.Policy),
+		Handlers:     append(append([]httpx.Handler(nil), s.handlers...), pipeline.Handlers...),
 		QuerySchemas: append([]httpx.ParameterSchema(nil), pipeline.QuerySchemas...),
 	}
+	if len(pipeline.ResponseHandlers) != 0 || len(s.responseHandlers) != 0 {
+		result.ResponseHandlers = append(append([]httpx.ResponseHandler(nil), pipeline.ResponseHandlers...), s.responseHandlers...)
+	}
 	sort.Sort(byName(result.QuerySchemas))
 
 	return result, nil
@@ -1,8 +1,11 @@
 package gateway
 
 import (
+	"expvar"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -206,7 +209,7 @@ func teapotHandler(context.Context, *httpx.Request) httpx.Response {
 func TestInstallPipelineAppliesServiceHandlers(t *testing.T) {
 	pipeline := &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
 
-	augpipe, err := installPipeline([]httpx.Handler{teapotHandler}, pipeline)
+	augpipe, err := installPipeline(scope{handlers: []httpx.Handler{teapotHandler}}, pipeline)
 
 	assert.NoError(t, err)
 	assert.Len(t, augpipe.Handlers, 2)
@@ -851,3 +854,275 @@ func TestGatewayServeWithCheckURLHookRemoveChecksPanicString(t *testing.T) {
 	registrar.AssertDeregisterCalledOnce(t)
 	registrar.AssertRemoveChecksCalledOnce(t)
 }
+
+func TestGatewayReloadNotRunning(t *testing.T) {
+	cut := &Gateway{
+		Name: "test",
+		Addr: ":0",
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	err := cut.Reload(svc)
+	assert.Error(t, err)
+}
+
+func TestGatewayReloadNoServices(t *testing.T) {
+	cut := &Gateway{
+		Name: "test",
+		Addr: ":0",
+	}
+
+	err := cut.Reload()
+	assert.Error(t, err)
+}
+
+func TestGatewayReload(t *testing.T) {
+	cut := &Gateway{
+		Name: "test",
+		Addr: "127.0.0.1:0",
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	timer := time.AfterFunc(200*time.Millisecond, func() { cut.Shutdown() })
+	defer timer.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.Serve(svc)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+
+	oldTree := cut.routingTree("")
+
+	endpoint2 := service.GetEndpoint("/zalgo", dummyHandler)
+	svc2 := newFakeService([]service.Endpoint{endpoint2})
+	err := cut.Reload(svc2)
+	assert.NoError(t, err)
+
+	e, _, _, err := cut.routingTree("").getValue("/zalgo")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+
+	e, _, _, err = cut.routingTree("").getValue(expectedRoute)
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+
+	e, _, _, err = oldTree.getValue(expectedRoute)
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+
+	wg.Wait()
+}
+
+func TestGatewayReloadHandler(t *testing.T) {
+	cut := &Gateway{Name: "test"}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	handler, err := cut.Handler(newFakeService([]service.Endpoint{endpoint}))
+	assert.NoError(t, err)
+
+	endpoint2 := service.GetEndpoint("/zalgo", dummyHandler)
+	err = cut.Reload(newFakeService([]service.Endpoint{endpoint2}))
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/zalgo", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGatewayReloadConcurrentStats(t *testing.T) {
+	cut := &Gateway{Name: "test"}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	_, err := cut.Handler(newFakeService([]service.Endpoint{endpoint}))
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			svc := &service.Service{
+				Name:      "svc-" + strconv.Itoa(i),
+				Endpoints: []service.Endpoint{service.GetEndpoint(expectedRoute, dummyHandler)},
+			}
+			assert.NoError(t, cut.Reload(svc))
+		}(i)
+	}
+	wg.Wait()
+
+	routes := cut.Routes()
+	if assert.Len(t, routes, 1) {
+		var names []string
+		gatewayExpvar.Get("services").(*expvar.Map).Do(func(kv expvar.KeyValue) {
+			names = append(names, kv.Key)
+		})
+		assert.Equal(t, []string{routes[0].Service}, names)
+	}
+}
+
+func TestGatewayReloadInvalidServicesKeepsRoutes(t *testing.T) {
+	cut := &Gateway{
+		Name: "test",
+		Addr: "127.0.0.1:0",
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+
+	timer := time.AfterFunc(200*time.Millisecond, func() { cut.Shutdown() })
+	defer timer.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		err := cut.Serve(svc)
+		assert.NoError(t, err)
+		wg.Done()
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+
+	endpoint2 := service.GetEndpoint("zalgo", dummyHandler)
+	svc2 := newFakeService([]service.Endpoint{endpoint2})
+	err := cut.Reload(svc2)
+	assert.Error(t, err)
+
+	e, _, _, err := cut.routingTree("").getValue(expectedRoute)
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+
+	wg.Wait()
+}
+
+func TestGatewayServiceWithBadHost(t *testing.T) {
+	cut := &Gateway{
+		Name: "test",
+		Addr: ":0",
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc := newFakeService([]service.Endpoint{endpoint})
+	svc.Hosts = []string{"api.*.example.com"}
+
+	err := cut.Serve(svc)
+	assert.Error(t, err)
+}
+
+func TestGatewayServiceHostsRedundantRegistration(t *testing.T) {
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	svc1 := newFakeService([]service.Endpoint{endpoint})
+	svc1.Hosts = []string{"api.example.com"}
+	svc2 := newFakeService([]service.Endpoint{endpoint})
+	svc2.Name = "other"
+	svc2.Hosts = []string{"admin.example.com"}
+	svc3 := newFakeService([]service.Endpoint{endpoint})
+	svc3.Name = "fallback"
+
+	table, err := buildRoutes([]*service.Service{svc1, svc2, svc3})
+	assert.NoError(t, err)
+	assert.Len(t, table.hosts, 2)
+
+	svc2.Hosts = []string{"API.example.com"}
+	_, err = buildRoutes([]*service.Service{svc1, svc2})
+	assert.Error(t, err)
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	cut := &Gateway{}
+	cut.init()
+	assert.Empty(t, cut.Routes())
+
+	policy := service.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
+	schemas := []httpx.ParameterSchema{{Name: "b"}, {Name: "a"}}
+	get := service.GetEndpointWithPolicy("/zalgo", policy, dummyHandler)
+	get.Get.QuerySchemas = schemas
+	get.Delete = &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+	svc1 := newFakeService([]service.Endpoint{get, service.GetEndpoint(expectedRoute, dummyHandler)})
+	svc1.Name = "zed"
+	svc2 := newFakeService([]service.Endpoint{service.PostEndpoint(expectedRoute, dummyHandler)})
+	svc2.Name = "alpha"
+	svc2.Hosts = []string{"api.example.com"}
+
+	err := cut.installServices([]*service.Service{svc1, svc2})
+	assert.NoError(t, err)
+
+	routes := cut.Routes()
+	assert.Len(t, routes, 3)
+
+	assert.Equal(t, "alpha", routes[0].Service)
+	assert.Equal(t, expectedRoute, routes[0].Route)
+	assert.Equal(t, []string{"api.example.com"}, routes[0].Hosts)
+	assert.Len(t, routes[0].Methods, 1)
+	assert.Equal(t, http.MethodPost, routes[0].Methods[0].Method)
+
+	assert.Equal(t, "zed", routes[1].Service)
+	assert.Equal(t, expectedRoute, routes[1].Route)
+	assert.Empty(t, routes[1].Hosts)
+
+	assert.Equal(t, "zed", routes[2].Service)
+	assert.Equal(t, "/zalgo", routes[2].Route)
+	assert.Len(t, routes[2].Methods, 2)
+	assert.Equal(t, http.MethodGet, routes[2].Methods[0].Method)
+	assert.Equal(t, policy, routes[2].Methods[0].Policy)
+	assert.Equal(t, []httpx.ParameterSchema{{Name: "a"}, {Name: "b"}}, routes[2].Methods[0].QuerySchemas)
+	assert.Equal(t, http.MethodDelete, routes[2].Methods[1].Method)
+
+	routes[0].Service = "plonk"
+	assert.Equal(t, "alpha", cut.Routes()[0].Service)
+}
+
+func TestInstallPipelineAppliesServiceResponseHandlers(t *testing.T) {
+	teapot := func(context.Context, *httpx.Request, httpx.Response) httpx.Response {
+		return httpx.NewEmpty(http.StatusTeapot)
+	}
+	pipeline := &service.Pipeline{
+		Handlers:         []httpx.Handler{dummyHandler},
+		ResponseHandlers: []httpx.ResponseHandler{teapot},
+	}
+
+	augpipe, err := installPipeline(scope{responseHandlers: []httpx.ResponseHandler{teapot, teapot}}, pipeline)
+
+	assert.NoError(t, err)
+	assert.Len(t, augpipe.ResponseHandlers, 3)
+	assert.Len(t, pipeline.ResponseHandlers, 1)
+}
+
+func TestGatewayHandler(t *testing.T) {
+	errHook := new(mockErrorHook)
+	cut := &Gateway{
+		ErrorHook: errHook.Handle,
+	}
+
+	endpoint := service.GetEndpoint(expectedRoute, dummyHandler)
+	handler, err := cut.Handler(newFakeService([]service.Endpoint{endpoint}))
+	assert.NoError(t, err)
+	assert.Equal(t, cut, handler)
+	assert.True(t, cut.Ready())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, expectedRoute, nil))
+
+	errHook.assertNotCalled(t)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGatewayHandlerServicesEmpty(t *testing.T) {
+	cut := &Gateway{}
+
+	handler, err := cut.Handler()
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
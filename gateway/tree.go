@@ -11,6 +11,7 @@ import (
 	"github.com/ansel1/merry"
 
 	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
 )
 
 func min(a, b int) int {
@@ -23,6 +24,12 @@ func min(a, b int) int {
 func countParams(path string) uint8 {
 	var n uint
 	for i := 0; i < len(path); i++ {
+		if path[i] == '{' {
+			if end := service.ConstraintEnd(path, i); end != -1 {
+				i = end - 1
+			}
+			continue
+		}
 		if path[i] != ':' && path[i] != '*' {
 			continue
 		}
@@ -44,14 +51,15 @@ const (
 )
 
 type node struct {
-	path      string
-	wildChild bool
-	nType     nodeType
-	maxParams uint8
-	indices   string
-	children  []*node
-	endpoint  *endpoint
-	priority  uint32
+	path       string
+	wildChild  bool
+	nType      nodeType
+	maxParams  uint8
+	indices    string
+	children   []*node
+	endpoint   *endpoint
+	priority   uint32
+	constraint *constraint
 }
 
 // addRoute adds a node with the given endpoint to the path. Not concurrency-safe!
@@ -213,17 +221,33 @@ func (n *node) insertChild(numParams uint8, path string, fullPath string, endpoi
 
 		// find wildcard end (either '/' or path end)
 		end := i + 1
+		brace := -1
 		for end < max && path[end] != '/' {
 			switch path[end] {
 			// the wildcard name must not contain ':' and '*'
 			case ':', '*':
 				return merry.Errorf(
 					"only one wildcard per path segment is allowed, have: %q in path %q", path[i:], fullPath)
+			case '{':
+				brace = end
+				if end = service.ConstraintEnd(path, brace); end == -1 {
+					return merry.Errorf(
+						"unterminated wildcard constraint in path %q", fullPath)
+				}
+				if end < max && path[end] != '/' {
+					return merry.Errorf(
+						"wildcard constraint must end the path segment in path %q", fullPath)
+				}
 			default:
 				end++
 			}
 		}
 
+		nameEnd := end
+		if brace != -1 {
+			nameEnd = brace
+		}
+
 		// check if this Node existing children which would be
 		// unreachable if we insert the wildcard here
 		if len(n.children) > 0 {
@@ -232,7 +256,7 @@ func (n *node) insertChild(numParams uint8, path string, fullPath string, endpoi
 		}
 
 		// check if the wildcard has a name
-		if end-i < 2 {
+		if nameEnd-i < 2 {
 			return merry.Errorf(
 				"wildcards must be named with a non-empty name in path %q", fullPath)
 		}
@@ -248,6 +272,13 @@ func (n *node) insertChild(numParams uint8, path string, fullPath string, endpoi
 				nType:     param,
 				maxParams: numParams,
 			}
+			if brace != -1 {
+				var err merry.Error
+				child.constraint, err = newConstraint(path[i+1:nameEnd], path[brace+1:end-1])
+				if err != nil {
+					return err.Appendf("in path %q", fullPath)
+				}
+			}
 			n.children = []*node{child}
 			n.wildChild = true
 			n = child
@@ -268,7 +299,14 @@ func (n *node) insertChild(numParams uint8, path string, fullPath string, endpoi
 				n = child
 			}
 
+			// skip the wildcard, the constraint may contain ':' or '*'
+			i = end - 1
+
 		} else { // catchAll
+			if brace != -1 {
+				return merry.Errorf(
+					"catch-all routes must not have a constraint in path %q", fullPath)
+			}
 			if end != max || numParams > 1 {
 				return merry.Errorf(
 					"catch-all routes are only allowed at the end of the path in path %q", fullPath)
@@ -377,6 +415,10 @@ walk: // Outer loop for walking the tree
 					p[i].Name = n.path[1:]
 					val := path[:end]
 					p[i].Value = val
+					if n.constraint != nil {
+						p[i].Name = n.constraint.name
+						p[i].Err = n.constraint.check(val)
+					}
 
 					// we need to go deeper!
 					if end < len(path) {
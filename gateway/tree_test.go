@@ -10,6 +10,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ansel1/merry"
+
 	"github.com/shisa-platform/core/context"
 	"github.com/shisa-platform/core/httpx"
 	"github.com/shisa-platform/core/service"
@@ -129,6 +131,9 @@ func TestCountParams(t *testing.T) {
 	if countParams(strings.Repeat("/:param", 256)) != 255 {
 		t.Fail()
 	}
+	if countParams("/path/:param1{[0-9]{3}}/:param2{(?::x)*}") != 2 {
+		t.Fail()
+	}
 }
 
 func TestTreeAddAndGet(t *testing.T) {
@@ -315,6 +320,77 @@ func TestTreeDupliatePath(t *testing.T) {
 	})
 }
 
+func TestTreeConstrainedWildcard(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id{uint}",
+		"/users/:id{uint}/files/:name{[a-z0-9-]+}",
+		"/zip/:code{[0-9]{5}}/info",
+		"/tags/:tag{a:b|c\\*d}",
+	}
+	for _, route := range routes {
+		if err := tree.addRoute(route, fakeEndpoint(route)); err != nil {
+			t.Errorf("unexpected error adding route: %v", err)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/users/42", false, "/users/:id{uint}", []httpx.PathParameter{{Name: "id", Value: "42"}}},
+		{"/users/42/files/read-me", false, "/users/:id{uint}/files/:name{[a-z0-9-]+}", []httpx.PathParameter{{Name: "id", Value: "42"}, {Name: "name", Value: "read-me"}}},
+		{"/zip/12345/info", false, "/zip/:code{[0-9]{5}}/info", []httpx.PathParameter{{Name: "code", Value: "12345"}}},
+		{"/tags/a:b", false, "/tags/:tag{a:b|c\\*d}", []httpx.PathParameter{{Name: "tag", Value: "a:b"}}},
+	})
+
+	checkPriorities(t, tree)
+	checkMaxParams(t, tree)
+
+	invalid := []struct {
+		path  string
+		index int
+	}{
+		{"/users/-42", 0},
+		{"/users/abc/files/read-me", 0},
+		{"/users/42/files/READ_ME", 1},
+		{"/zip/1234/info", 0},
+		{"/tags/a:bc*d", 0},
+	}
+	for _, request := range invalid {
+		endpoint, ps, _, err := tree.getValue(request.path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if endpoint == nil {
+			t.Errorf("expected non-nil endpoint for %q", request.path)
+		}
+		if len(ps) <= request.index || ps[request.index].Err == nil {
+			t.Errorf("expected malformed param %d for %q", request.index, request.path)
+			continue
+		}
+		if !merry.Is(ps[request.index].Err, httpx.MalformedPathParameter) {
+			t.Errorf("unexpected error for %q: %v", request.path, ps[request.index].Err)
+		}
+	}
+}
+
+func TestTreeBadConstraint(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/users/:id{uint",
+		"/users/:id{}",
+		"/users/:id{[0-9}",
+		"/users/:id{uint}x",
+		"/users/:{uint}",
+		"/src/*filepath{uint}",
+	}
+	for _, route := range routes {
+		if err := tree.addRoute(route, nil); err == nil {
+			t.Errorf("expected error while inserting route with bad constraint %q", route)
+		}
+	}
+}
+
 func TestEmptyWildcardName(t *testing.T) {
 	tree := &node{}
 
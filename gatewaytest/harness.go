@@ -0,0 +1,135 @@
+// Package gatewaytest provides a harness for exercising a
+// gateway and its services in-process, without the network.
+package gatewaytest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ansel1/merry"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/gateway"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+var (
+	// the global tracer is swapped for the duration of each
+	// request so only one can be serviced at a time
+	tracerMtx sync.Mutex
+)
+
+// Result is the outcome of a request serviced by a harness.
+type Result struct {
+	// Response is the response sent to the user agent
+	Response *http.Response
+	// Snapshot is the snapshot passed to the completion hook
+	Snapshot httpx.ResponseSnapshot
+	// Errors are the errors passed to the error hook
+	Errors []merry.Error
+	// Spans are the spans finished servicing the request
+	Spans []*mocktracer.MockSpan
+}
+
+// Harness services requests with a gateway in-process and
+// captures the hook invocations and tracing spans of each.
+// The `ErrorHook` and `CompletionHook` of the gateway are still
+// invoked.
+type Harness struct {
+	gateway *gateway.Gateway
+	handler http.Handler
+	tracer  *mocktracer.MockTracer
+
+	mtx      sync.Mutex
+	snapshot httpx.ResponseSnapshot
+	errors   []merry.Error
+}
+
+// New returns a harness for the gateway configured with the
+// given services.  The gateway must not be serving and should
+// not be used directly afterwards.
+func New(g *gateway.Gateway, services ...*service.Service) (*Harness, merry.Error) {
+	h := &Harness{
+		gateway: g,
+		tracer:  mocktracer.New(),
+	}
+
+	errorHook, completionHook := g.ErrorHook, g.CompletionHook
+	g.ErrorHook = func(ctx context.Context, request *httpx.Request, err merry.Error) {
+		h.mtx.Lock()
+		h.errors = append(h.errors, err)
+		h.mtx.Unlock()
+		if errorHook != nil {
+			errorHook(ctx, request, err)
+		}
+	}
+	g.CompletionHook = func(ctx context.Context, request *httpx.Request, snapshot httpx.ResponseSnapshot) {
+		h.mtx.Lock()
+		h.snapshot = snapshot
+		h.mtx.Unlock()
+		if completionHook != nil {
+			completionHook(ctx, request, snapshot)
+		}
+	}
+
+	handler, err := g.Handler(services...)
+	if err != nil {
+		g.ErrorHook, g.CompletionHook = errorHook, completionHook
+		return nil, err.Prepend("gatewaytest: new")
+	}
+	h.handler = handler
+
+	return h, nil
+}
+
+// Gateway returns the gateway used by the harness.
+func (h *Harness) Gateway() *gateway.Gateway {
+	return h.gateway
+}
+
+// Do services a request and returns the result.  Requests are
+// serviced one at a time, across all harnesses, because the
+// global tracer is replaced while they are.
+func (h *Harness) Do(r *http.Request) *Result {
+	tracerMtx.Lock()
+	defer tracerMtx.Unlock()
+
+	previous := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(h.tracer)
+	defer opentracing.SetGlobalTracer(previous)
+
+	h.tracer.Reset()
+	h.mtx.Lock()
+	h.snapshot = httpx.ResponseSnapshot{}
+	h.errors = nil
+	h.mtx.Unlock()
+
+	w := httptest.NewRecorder()
+	h.handler.ServeHTTP(w, r)
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return &Result{
+		Response: w.Result(),
+		Snapshot: h.snapshot,
+		Errors:   append([]merry.Error(nil), h.errors...),
+		Spans:    h.tracer.FinishedSpans(),
+	}
+}
+
+// Span returns the first finished span with the given operation
+// name or nil if there isn't one.
+func (r *Result) Span(operationName string) *mocktracer.MockSpan {
+	for _, span := range r.Spans {
+		if span.OperationName == operationName {
+			return span
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package gatewaytest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/gateway"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+type message string
+
+func (m message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(m))
+}
+
+func newService(handler httpx.Handler) *service.Service {
+	return &service.Service{
+		Name:      "test",
+		Endpoints: []service.Endpoint{service.GetEndpoint("/test", handler)},
+	}
+}
+
+func TestNewNoServices(t *testing.T) {
+	cut, err := New(&gateway.Gateway{})
+	assert.Error(t, err)
+	assert.Nil(t, cut)
+}
+
+func TestNewBadService(t *testing.T) {
+	cut, err := New(&gateway.Gateway{}, &service.Service{Name: "test"})
+	assert.Error(t, err)
+	assert.Nil(t, cut)
+}
+
+func TestHarnessDo(t *testing.T) {
+	var completed bool
+	g := &gateway.Gateway{
+		CompletionHook: func(context.Context, *httpx.Request, httpx.ResponseSnapshot) {
+			completed = true
+		},
+	}
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		return httpx.NewOK(message("hello"))
+	}
+
+	cut, err := New(g, newService(handler))
+	assert.NoError(t, err)
+	assert.Equal(t, g, cut.Gateway())
+
+	tracer := opentracing.GlobalTracer()
+
+	result := cut.Do(httptest.NewRequest(http.MethodGet, "/test", nil))
+	assert.NotNil(t, result)
+	assert.True(t, completed, "completion hook not called")
+	assert.Equal(t, tracer, opentracing.GlobalTracer())
+
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+	body, _ := ioutil.ReadAll(result.Response.Body)
+	assert.Equal(t, "\"hello\"\n", string(body))
+
+	assert.Equal(t, http.StatusOK, result.Snapshot.StatusCode)
+	assert.Equal(t, len(body), result.Snapshot.Size)
+	assert.Empty(t, result.Errors)
+
+	span := result.Span("ServiceRequest")
+	assert.NotNil(t, span)
+	assert.Equal(t, uint16(http.StatusOK), span.Tag("http.status_code"))
+	assert.NotNil(t, result.Span("RunPipelineHandlers"))
+	assert.Nil(t, result.Span("lolwut"))
+}
+
+func TestHarnessDoErrors(t *testing.T) {
+	var hookErrors int
+	g := &gateway.Gateway{
+		ErrorHook: func(context.Context, *httpx.Request, merry.Error) {
+			hookErrors++
+		},
+	}
+	handler := func(context.Context, *httpx.Request) httpx.Response {
+		panic(merry.New("i blewed up!"))
+	}
+
+	cut, err := New(g, newService(handler))
+	assert.NoError(t, err)
+
+	result := cut.Do(httptest.NewRequest(http.MethodGet, "/test", nil))
+	assert.Equal(t, http.StatusInternalServerError, result.Response.StatusCode)
+	assert.Equal(t, http.StatusInternalServerError, result.Snapshot.StatusCode)
+	assert.Len(t, result.Errors, 1)
+	assert.Equal(t, 1, hookErrors)
+
+	result = cut.Do(httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, result.Response.StatusCode)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, 1, hookErrors)
+}
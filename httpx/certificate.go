@@ -0,0 +1,208 @@
+package httpx
+
+import (
+	stdctx "context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+)
+
+// CertificateLoader provides a TLS certificate and private key
+// loaded from PEM encoded files.  The files are reloaded when
+// they change or when the process receives SIGHUP so that
+// rotated certificates are used without a restart.
+// If a reload fails the current certificate continues to be
+// used and the error is passed to `ErrorHook`.
+// Use `GetCertificate` as the `tls.Config.GetCertificate`
+// callback.
+type CertificateLoader struct {
+	CertFile string // path of the certificate chain.  Required.
+	KeyFile  string // path of the private key.  Required.
+
+	// PollInterval is how often the files are checked for
+	// changes.
+	// If zero the files are only reloaded on SIGHUP or a call
+	// to `Reload`.
+	PollInterval time.Duration
+
+	// IgnoreHangup disables reloading the files on SIGHUP.
+	IgnoreHangup bool
+
+	// ErrorHook optionally receives errors encountered
+	// reloading the files after `Start`.  The request passed to
+	// the hook is always nil.
+	// If nil errors are ignored.
+	ErrorHook ErrorHook
+
+	mtx      sync.RWMutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Start loads the certificate and begins watching for changes.
+// An error is returned if the initial load fails.
+func (l *CertificateLoader) Start() merry.Error {
+	if l.CertFile == "" {
+		return merry.New("certificate loader: check invariants: certificate file empty")
+	}
+	if l.KeyFile == "" {
+		return merry.New("certificate loader: check invariants: key file empty")
+	}
+
+	if err := l.Reload(); err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.stop != nil {
+		return nil
+	}
+
+	// register for signals before returning so that none are
+	// missed once the caller considers the loader started
+	var hangup chan os.Signal
+	if !l.IgnoreHangup {
+		hangup = make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.watch(hangup, l.stop, l.done)
+
+	return nil
+}
+
+// Stop ends watching for changes.  The last certificate loaded
+// continues to be returned by `GetCertificate`.
+func (l *CertificateLoader) Stop() {
+	l.mtx.Lock()
+	stop, done := l.stop, l.done
+	l.stop, l.done = nil, nil
+	l.mtx.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Reload loads the certificate files immediately.  If loading
+// fails the current certificate is kept.
+func (l *CertificateLoader) Reload() merry.Error {
+	certTime, keyTime := modTime(l.CertFile), modTime(l.KeyFile)
+
+	cert, err := tls.LoadX509KeyPair(l.CertFile, l.KeyFile)
+	if err != nil {
+		return merry.Prepend(err, "certificate loader: reload").Append(l.CertFile).Append(l.KeyFile)
+	}
+
+	l.mtx.Lock()
+	l.cert = &cert
+	l.certTime, l.keyTime = certTime, keyTime
+	l.mtx.Unlock()
+
+	return nil
+}
+
+// TLSConfig returns a copy of `base`, or a new config if it is
+// nil, that serves the certificate of the loader.
+func (l *CertificateLoader) TLSConfig(base *tls.Config) *tls.Config {
+	config := new(tls.Config)
+	if base != nil {
+		config = base.Clone()
+	}
+	// GetCertificate isn't used for clients without SNI if
+	// Certificates isn't empty
+	config.Certificates = nil
+	config.GetCertificate = l.GetCertificate
+
+	return config
+}
+
+// GetCertificate returns the current certificate.  It is
+// suitable for `tls.Config.GetCertificate`.
+func (l *CertificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if l.cert == nil {
+		return nil, merry.New("certificate loader: no certificate loaded")
+	}
+
+	return l.cert, nil
+}
+
+func (l *CertificateLoader) watch(hangup chan os.Signal, stop, done chan struct{}) {
+	defer close(done)
+
+	if hangup != nil {
+		defer signal.Stop(hangup)
+	}
+
+	var poll <-chan time.Time
+	if l.PollInterval > 0 {
+		ticker := time.NewTicker(l.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hangup:
+			l.reloadAndReport()
+		case <-poll:
+			if l.changed() {
+				l.reloadAndReport()
+			}
+		}
+	}
+}
+
+// changed returns true if either file has been modified since
+// the last load attempt.  The times are updated so that a file
+// rotation that fails to load isn't retried until it changes
+// again.
+func (l *CertificateLoader) changed() bool {
+	certTime, keyTime := modTime(l.CertFile), modTime(l.KeyFile)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if certTime.Equal(l.certTime) && keyTime.Equal(l.keyTime) {
+		return false
+	}
+	l.certTime, l.keyTime = certTime, keyTime
+
+	return true
+}
+
+func (l *CertificateLoader) reloadAndReport() {
+	if err := l.Reload(); err != nil {
+		ctx := context.New(stdctx.Background())
+		l.ErrorHook.InvokeSafely(ctx, nil, err)
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,238 @@
+package httpx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+)
+
+func writeCertificate(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	assert.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+type certificateFixture struct {
+	dir      string
+	certFile string
+	keyFile  string
+}
+
+func newCertificateFixture(t *testing.T) certificateFixture {
+	dir, err := ioutil.TempDir("", "certificate")
+	assert.NoError(t, err)
+
+	f := certificateFixture{
+		dir:      dir,
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "key.pem"),
+	}
+	writeCertificate(t, f.certFile, f.keyFile, "first.example.com")
+
+	return f
+}
+
+type recordingErrorHook struct {
+	sync.Mutex
+	errs []merry.Error
+}
+
+func (h *recordingErrorHook) Handle(_ context.Context, _ *Request, err merry.Error) {
+	h.Lock()
+	h.errs = append(h.errs, err)
+	h.Unlock()
+}
+
+func (h *recordingErrorHook) calls() int {
+	h.Lock()
+	defer h.Unlock()
+	return len(h.errs)
+}
+
+func TestCertificateLoaderMissingFileNames(t *testing.T) {
+	cut := &CertificateLoader{KeyFile: "key.pem"}
+	assert.Error(t, cut.Start())
+
+	cut = &CertificateLoader{CertFile: "cert.pem"}
+	assert.Error(t, cut.Start())
+}
+
+func TestCertificateLoaderMissingFiles(t *testing.T) {
+	cut := &CertificateLoader{
+		CertFile: "/zalgo/cert.pem",
+		KeyFile:  "/zalgo/key.pem",
+	}
+	assert.Error(t, cut.Start())
+
+	cert, err := cut.GetCertificate(nil)
+	assert.Error(t, err)
+	assert.Nil(t, cert)
+}
+
+func TestCertificateLoaderPollReload(t *testing.T) {
+	fixture := newCertificateFixture(t)
+	defer os.RemoveAll(fixture.dir)
+
+	hook := new(recordingErrorHook)
+	cut := &CertificateLoader{
+		CertFile:     fixture.certFile,
+		KeyFile:      fixture.keyFile,
+		PollInterval: 10 * time.Millisecond,
+		IgnoreHangup: true,
+		ErrorHook:    hook.Handle,
+	}
+	assert.NoError(t, cut.Start())
+	defer cut.Stop()
+
+	cert, err := cut.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "first.example.com", commonName(t, cert))
+
+	writeCertificate(t, fixture.certFile, fixture.keyFile, "second.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(fixture.certFile, future, future)
+	time.Sleep(50 * time.Millisecond)
+
+	cert, err = cut.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "second.example.com", commonName(t, cert))
+	assert.Equal(t, 0, hook.calls())
+}
+
+func TestCertificateLoaderReloadFailureKeepsCertificate(t *testing.T) {
+	fixture := newCertificateFixture(t)
+	defer os.RemoveAll(fixture.dir)
+
+	hook := new(recordingErrorHook)
+	cut := &CertificateLoader{
+		CertFile:     fixture.certFile,
+		KeyFile:      fixture.keyFile,
+		PollInterval: 10 * time.Millisecond,
+		IgnoreHangup: true,
+		ErrorHook:    hook.Handle,
+	}
+	assert.NoError(t, cut.Start())
+	defer cut.Stop()
+
+	assert.NoError(t, ioutil.WriteFile(fixture.keyFile, []byte("garbage"), 0600))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(fixture.keyFile, future, future)
+	time.Sleep(50 * time.Millisecond)
+
+	cert, err := cut.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "first.example.com", commonName(t, cert))
+	assert.Equal(t, 1, hook.calls(), "failed reload should be reported once")
+}
+
+func TestCertificateLoaderHangupReload(t *testing.T) {
+	fixture := newCertificateFixture(t)
+	defer os.RemoveAll(fixture.dir)
+
+	cut := &CertificateLoader{
+		CertFile: fixture.certFile,
+		KeyFile:  fixture.keyFile,
+	}
+	assert.NoError(t, cut.Start())
+	defer cut.Stop()
+
+	writeCertificate(t, fixture.certFile, fixture.keyFile, "second.example.com")
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	time.Sleep(50 * time.Millisecond)
+
+	cert, err := cut.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "second.example.com", commonName(t, cert))
+}
+
+func TestCertificateLoaderStopTwice(t *testing.T) {
+	fixture := newCertificateFixture(t)
+	defer os.RemoveAll(fixture.dir)
+
+	cut := &CertificateLoader{
+		CertFile:     fixture.certFile,
+		KeyFile:      fixture.keyFile,
+		IgnoreHangup: true,
+	}
+	assert.NoError(t, cut.Start())
+	assert.NoError(t, cut.Start())
+	cut.Stop()
+	cut.Stop()
+
+	cert, err := cut.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, cert)
+}
+
+func TestCertificateLoaderTLSConfig(t *testing.T) {
+	fixture := newCertificateFixture(t)
+	defer os.RemoveAll(fixture.dir)
+
+	cut := &CertificateLoader{
+		CertFile: fixture.certFile,
+		KeyFile:  fixture.keyFile,
+	}
+	assert.NoError(t, cut.Reload())
+
+	config := cut.TLSConfig(nil)
+	assert.Empty(t, config.Certificates)
+	cert, err := config.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "first.example.com", commonName(t, cert))
+
+	base := &tls.Config{
+		Certificates: []tls.Certificate{{}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	config = cut.TLSConfig(base)
+	assert.False(t, config == base, "base not copied")
+	assert.Empty(t, config.Certificates)
+	assert.NotNil(t, config.GetCertificate)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Len(t, base.Certificates, 1)
+	assert.Nil(t, base.GetCertificate)
+}
@@ -29,6 +29,17 @@ func (h ErrorHandler) InvokeSafely(ctx context.Context, request *Request, err me
 	return h(ctx, request, err), nil
 }
 
+// ResponseHandler is a block of logic to apply to the response
+// of a request before it is sent to the user agent, e.g. to add
+// headers.  The returned response replaces the given one, if nil
+// the given response is used unchanged.
+type ResponseHandler func(context.Context, *Request, Response) Response
+
+func (h ResponseHandler) InvokeSafely(ctx context.Context, request *Request, response Response) (_ Response, exception merry.Error) {
+	defer errorx.CapturePanic(&exception, "panic in response handler")
+	return h(ctx, request, response), nil
+}
+
 type responseBuffer struct {
 	code    int
 	headers http.Header
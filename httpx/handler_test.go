@@ -101,6 +101,37 @@ func TestErrorHandlerOK(t *testing.T) {
 	assert.Equal(t, http.StatusTeapot, response.StatusCode())
 }
 
+func TestResponseHandlerPanic(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+
+	var handler ResponseHandler
+	handler = func(context.Context, *Request, Response) Response {
+		panic(merry.New("i blewed up!"))
+	}
+
+	response, exception := handler.InvokeSafely(ctx, request, NewEmpty(http.StatusOK))
+	assert.Error(t, exception)
+	assert.Nil(t, response)
+}
+
+func TestResponseHandlerOK(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+
+	var handler ResponseHandler
+	handler = func(_ context.Context, _ *Request, response Response) Response {
+		response.Headers().Set("X-Teapot", "true")
+		return response
+	}
+
+	response, exception := handler.InvokeSafely(ctx, request, NewEmpty(http.StatusTeapot))
+	assert.NoError(t, exception)
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusTeapot, response.StatusCode())
+	assert.Equal(t, "true", response.Headers().Get("X-Teapot"))
+}
+
 func TestAdaptStdHandlerNoOutput(t *testing.T) {
 	ctx := context.NewFakeContextDefaultFatal(t)
 	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
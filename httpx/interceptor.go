@@ -53,6 +53,17 @@ func (i *ResponseInterceptor) WriteResponse(response Response) merry.Error {
 	return WriteResponse(i, response)
 }
 
+// WriteHeadResponse writes the status code and headers of a
+// response instance, discarding the body.
+// The "Content-Length" header is set to the size of the
+// serialized body if the response doesn't specify it.
+// It is not safe to use the `ResponseWriter` methods of this
+// instance after calling this method.
+// Any error returned from `Response.Serialize` will be returned.
+func (i *ResponseInterceptor) WriteHeadResponse(response Response) merry.Error {
+	return WriteHeadResponse(i, response)
+}
+
 // Flush attempts to call the `Flush` method on the underlying
 // `ResponseWriter`, if it implments the `http.Flusher` interface.
 func (i *ResponseInterceptor) Flush() ResponseSnapshot {
@@ -86,3 +86,85 @@ func TestInterceptorWriteResponsePanicString(t *testing.T) {
 
 	assert.Error(t, cut.WriteResponse(response))
 }
+
+type stringMarshaler string
+
+func (m stringMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + string(m) + `"`), nil
+}
+
+func TestInterceptorWriteHeadResponse(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	response := NewOK(stringMarshaler("zalgo"))
+	response.Headers().Set("x-zalgo", "he comes")
+
+	assert.NoError(t, cut.WriteHeadResponse(response))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Zero(t, rw.Body.Len())
+	assert.Equal(t, "he comes", rw.HeaderMap.Get("X-Zalgo"))
+	assert.Equal(t, "8", rw.HeaderMap.Get(ContentLengthHeaderKey))
+
+	snapshot := cut.Snapshot()
+	assert.Equal(t, http.StatusOK, snapshot.StatusCode)
+	assert.Zero(t, snapshot.Size)
+}
+
+func TestInterceptorWriteHeadResponseExplicitContentLength(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	response := NewOK(stringMarshaler("zalgo"))
+	response.Headers().Set(ContentLengthHeaderKey, "1024")
+
+	assert.NoError(t, cut.WriteHeadResponse(response))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Zero(t, rw.Body.Len())
+	assert.Equal(t, "1024", rw.HeaderMap.Get(ContentLengthHeaderKey))
+}
+
+func TestInterceptorWriteHeadResponseEmpty(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	assert.NoError(t, cut.WriteHeadResponse(NewEmpty(http.StatusNoContent)))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Empty(t, rw.HeaderMap.Get(ContentLengthHeaderKey))
+}
+
+func TestInterceptorWriteHeadResponseEmptyBody(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	assert.NoError(t, cut.WriteHeadResponse(NewEmpty(http.StatusOK)))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Zero(t, rw.Body.Len())
+	assert.Equal(t, "0", rw.HeaderMap.Get(ContentLengthHeaderKey))
+}
+
+func TestInterceptorWriteHeadResponseNotModified(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	assert.NoError(t, cut.WriteHeadResponse(NewEmpty(http.StatusNotModified)))
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.HeaderMap.Get(ContentLengthHeaderKey))
+}
+
+func TestInterceptorWriteHeadResponsePanic(t *testing.T) {
+	rw := httptest.NewRecorder()
+	cut := NewInterceptor(rw)
+
+	response := &FakeResponse{
+		StatusCodeHook: func() int { return 451 },
+		HeadersHook:    func() http.Header { return nil },
+		TrailersHook:   func() http.Header { return nil },
+		ErrHook:        func() error { return nil },
+		SerializeHook: func(io.Writer) merry.Error {
+			panic(merry.New("i blewed up"))
+		},
+	}
+
+	assert.Error(t, cut.WriteHeadResponse(response))
+}
@@ -2,6 +2,8 @@ package httpx
 
 import (
 	"net"
+	"os"
+	"syscall"
 	"time"
 
 	"github.com/ansel1/merry"
@@ -22,6 +24,46 @@ func HTTPListenerForAddress(addr string) (net.Listener, merry.Error) {
 	return tcpKeepAliveListener{l.(*net.TCPListener)}, nil
 }
 
+// UnixListenerForPath returns a Unix domain socket listener for
+// the given path.  A stale socket file left at the path by a
+// previous process is removed, but an error is returned if
+// another process is still accepting connections on it.
+func UnixListenerForPath(path string) (net.Listener, merry.Error) {
+	if path == "" {
+		return nil, merry.New("open Unix listener: path empty")
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, merry.New("open Unix listener: socket in use").Append(path)
+		}
+		if !connectionRefused(err) {
+			return nil, merry.Prepend(err, "open Unix listener: check socket")
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, merry.Prepend(err, "open Unix listener")
+	}
+
+	return l, nil
+}
+
+// connectionRefused returns true if the error is due to nothing
+// listening on the address dialed.
+func connectionRefused(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+
+	return err == syscall.ECONNREFUSED
+}
+
 // tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
 // connections. It's used by ListenAndServe and ListenAndServeTLS
 // so dead TCP connections (e.g. closing laptop mid-download)
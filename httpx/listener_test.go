@@ -1,7 +1,11 @@
 package httpx
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -68,3 +72,65 @@ func TestAcceptError(t *testing.T) {
 	assert.Error(t, listenErr)
 	assert.Nil(t, conn)
 }
+
+func TestUnixListenerForPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.sock")
+
+	listener, err := UnixListenerForPath(path)
+	assert.NoError(t, err)
+	assert.NotNil(t, listener)
+	assert.Equal(t, "unix", listener.Addr().Network())
+
+	// a stale socket is replaced
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.NoError(t, listener.Close())
+
+	listener, err = UnixListenerForPath(path)
+	assert.NoError(t, err)
+	assert.NotNil(t, listener)
+	assert.NoError(t, listener.Close())
+}
+
+func TestUnixListenerForPathInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.sock")
+
+	listener, err := UnixListenerForPath(path)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	second, err := UnixListenerForPath(path)
+	assert.Error(t, err)
+	assert.Nil(t, second)
+
+	// the live socket is untouched
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	assert.True(t, connectionRefused(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}))
+	assert.False(t, connectionRefused(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EACCES)}))
+}
+
+func TestUnixListenerForPathEmpty(t *testing.T) {
+	listener, err := UnixListenerForPath("")
+	assert.Error(t, err)
+	assert.Nil(t, listener)
+}
+
+func TestUnixListenerForPathBadPath(t *testing.T) {
+	listener, err := UnixListenerForPath("/zalgo/does/not/exist.sock")
+	assert.Error(t, err)
+	assert.Nil(t, listener)
+}
@@ -1,7 +1,12 @@
 package httpx
 
+import (
+	"github.com/ansel1/merry"
+)
+
 // PathParameter is a single URL path parameter.
 type PathParameter struct {
 	Name  string
 	Value string
+	Err   merry.Error // the value failed the route constraint
 }
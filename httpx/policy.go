@@ -0,0 +1,136 @@
+package httpx
+
+import (
+	"time"
+)
+
+// NoLimit is the value of a numeric `Policy` field that removes
+// an inherited limit or time budget.
+const NoLimit = -1
+
+// Bool returns a pointer to the given value, for setting the
+// boolean fields of a `Policy`.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// IsTrue returns true if the value is set and true.  Unset
+// boolean fields of a `Policy` are false.
+func IsTrue(v *bool) bool {
+	return v != nil && *v
+}
+
+// Policy controls the behavior of per-endpoint request
+// processing before control is passed to the handler.
+//
+// Unset fields, nil or zero, are inherited when policies are
+// merged.  A setting is switched off by setting it to a pointer
+// to false, see `Bool`, and a limit or time budget is removed by
+// setting it to `NoLimit`.
+type Policy struct {
+	// Will malformed query parameters be passed through or
+	// rejected?
+	AllowMalformedQueryParameters *bool `json:",omitempty"`
+	// Will unknown query parameters be passed through or
+	// rejected?
+	AllowUnknownQueryParameters *bool `json:",omitempty"`
+	// Will requests  with missing/extra trailing slash
+	// be redirected?
+	AllowTrailingSlashRedirects *bool `json:",omitempty"`
+	// Will URL escaped path parameters be preserved?
+	PreserveEscapedPathParameters *bool `json:",omitempty"`
+	// Will HEAD requests be serviced by the GET pipeline if the
+	// endpoint has no HEAD pipeline?  The response body is
+	// discarded but the status code and headers are preserved.
+	// Only applies to GET pipelines.
+	AllowImplicitHead *bool `json:",omitempty"`
+	// Will path parameters that fail the constraint of their
+	// route be rejected with a bad request response?  If not
+	// the request is treated as if the route were unknown.
+	RejectMalformedPathParameters *bool `json:",omitempty"`
+	// The time budget for the pipeline to complete.  If zero or
+	// negative there is no budget.
+	TimeBudget time.Duration `json:",omitempty"`
+	// The maximum size in bytes of the request body.  Requests
+	// declaring a larger Content-Length are rejected with a 413
+	// response before any handler runs, otherwise reading the
+	// body fails once the limit is exceeded and the response is
+	// replaced with a 413.  If zero or
+	// negative the size is unlimited.
+	MaxBodySize int64 `json:",omitempty"`
+	// The maximum time to read the request body, measured from
+	// when the pipeline starts.  The body is streamed to the
+	// handlers, reads fail once the time has elapsed and the
+	// response is replaced with a 408.  If zero or negative
+	// there is no timeout.
+	BodyReadTimeout time.Duration `json:",omitempty"`
+	// The minimum average rate in bytes per second the request
+	// body must be read at.  The body is streamed to the
+	// handlers, reads fail if the rate, checked every second, is
+	// too slow and the response is replaced with a 408.  If zero
+	// or negative there is no minimum.
+	MinBodyReadRate int64 `json:",omitempty"`
+	// The maximum number of requests the pipeline will service
+	// concurrently.  If zero or
+	// negative concurrency is unlimited.
+	MaxConcurrency int `json:",omitempty"`
+	// The maximum number of requests that will wait for the
+	// pipeline once `MaxConcurrency` is reached.  Requests
+	// beyond this are rejected immediately.  If zero no requests
+	// wait, if negative the queue is unbounded.  Only applies if
+	// `MaxConcurrency` is set.
+	MaxQueueDepth int `json:",omitempty"`
+	// The maximum time a request will wait in the queue before
+	// being rejected.  If zero or negative requests wait until
+	// the time budget expires or the request is aborted.
+	QueueTimeout time.Duration `json:",omitempty"`
+}
+
+// Merge returns a copy of the policy with the set fields of the
+// override replacing those of the receiver.  Boolean fields are
+// set if not nil, numeric fields if not zero.  This is how
+// endpoints inherit and override the defaults of their groups
+// and services.
+func (p Policy) Merge(override Policy) Policy {
+	if override.AllowMalformedQueryParameters != nil {
+		p.AllowMalformedQueryParameters = override.AllowMalformedQueryParameters
+	}
+	if override.AllowUnknownQueryParameters != nil {
+		p.AllowUnknownQueryParameters = override.AllowUnknownQueryParameters
+	}
+	if override.AllowTrailingSlashRedirects != nil {
+		p.AllowTrailingSlashRedirects = override.AllowTrailingSlashRedirects
+	}
+	if override.PreserveEscapedPathParameters != nil {
+		p.PreserveEscapedPathParameters = override.PreserveEscapedPathParameters
+	}
+	if override.AllowImplicitHead != nil {
+		p.AllowImplicitHead = override.AllowImplicitHead
+	}
+	if override.RejectMalformedPathParameters != nil {
+		p.RejectMalformedPathParameters = override.RejectMalformedPathParameters
+	}
+	if override.TimeBudget != 0 {
+		p.TimeBudget = override.TimeBudget
+	}
+	if override.MaxBodySize != 0 {
+		p.MaxBodySize = override.MaxBodySize
+	}
+	if override.BodyReadTimeout != 0 {
+		p.BodyReadTimeout = override.BodyReadTimeout
+	}
+	if override.MinBodyReadRate != 0 {
+		p.MinBodyReadRate = override.MinBodyReadRate
+	}
+	if override.MaxConcurrency != 0 {
+		p.MaxConcurrency = override.MaxConcurrency
+	}
+	if override.MaxQueueDepth != 0 {
+		p.MaxQueueDepth = override.MaxQueueDepth
+	}
+	if override.QueueTimeout != 0 {
+		p.QueueTimeout = override.QueueTimeout
+	}
+
+	return p
+}
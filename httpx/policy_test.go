@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyMerge(t *testing.T) {
+	defaults := Policy{
+		AllowTrailingSlashRedirects: Bool(true),
+		TimeBudget:                  time.Second,
+		MaxBodySize:                 1024,
+		MaxConcurrency:              4,
+	}
+
+	assert.Equal(t, defaults, defaults.Merge(Policy{}))
+	assert.Equal(t, defaults, Policy{}.Merge(defaults))
+
+	override := Policy{
+		AllowImplicitHead: Bool(true),
+		TimeBudget:        time.Minute,
+		QueueTimeout:      time.Millisecond,
+	}
+	expected := Policy{
+		AllowTrailingSlashRedirects: Bool(true),
+		AllowImplicitHead:           Bool(true),
+		TimeBudget:                  time.Minute,
+		MaxBodySize:                 1024,
+		MaxConcurrency:              4,
+		QueueTimeout:                time.Millisecond,
+	}
+	assert.Equal(t, expected, defaults.Merge(override))
+	assert.Equal(t, time.Second, defaults.TimeBudget, "receiver modified")
+}
+
+func TestPolicyMergeOverridesToUnset(t *testing.T) {
+	defaults := Policy{
+		AllowTrailingSlashRedirects: Bool(true),
+		AllowImplicitHead:           Bool(true),
+		TimeBudget:                  time.Second,
+		MaxBodySize:                 1024,
+		MaxConcurrency:              4,
+	}
+
+	override := Policy{
+		AllowTrailingSlashRedirects: Bool(false),
+		TimeBudget:                  NoLimit,
+		MaxBodySize:                 NoLimit,
+		MaxConcurrency:              NoLimit,
+	}
+	result := defaults.Merge(override)
+	assert.False(t, IsTrue(result.AllowTrailingSlashRedirects))
+	assert.True(t, IsTrue(result.AllowImplicitHead))
+	assert.Equal(t, time.Duration(NoLimit), result.TimeBudget)
+	assert.Equal(t, int64(NoLimit), result.MaxBodySize)
+	assert.Equal(t, NoLimit, result.MaxConcurrency)
+	assert.True(t, IsTrue(defaults.AllowTrailingSlashRedirects), "receiver modified")
+}
+
+func TestIsTrue(t *testing.T) {
+	assert.False(t, IsTrue(nil))
+	assert.False(t, IsTrue(Bool(false)))
+	assert.True(t, IsTrue(Bool(true)))
+}
+
+func TestPolicyMergeAllFields(t *testing.T) {
+	// every field must be merged
+	var override Policy
+	v := reflect.ValueOf(&override).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Ptr:
+			field.Set(reflect.ValueOf(Bool(true)))
+		case reflect.Int, reflect.Int64:
+			field.SetInt(42)
+		default:
+			t.Fatalf("unexpected kind of field %s", v.Type().Field(i).Name)
+		}
+	}
+
+	assert.Equal(t, override, Policy{}.Merge(override))
+}
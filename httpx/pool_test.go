@@ -17,6 +17,10 @@ func TestPool(t *testing.T) {
 	assert.Equal(t, parent, cut.Request)
 	assert.Nil(t, cut.PathParams)
 	assert.Nil(t, cut.QueryParams)
+	assert.Equal(t, "", cut.Variant)
+	assert.Equal(t, "", cut.Service)
+	assert.Equal(t, "", cut.Route)
+	assert.Nil(t, cut.Policy)
 	assert.Equal(t, "", cut.id)
 	assert.Equal(t, "", cut.clientIP)
 }
@@ -22,6 +22,7 @@ var (
 	}
 	InvalidParameterNameEscape  = merry.New("invalid parameter name escape")
 	InvalidParameterValueEscape = merry.New("invalid parameter value escape")
+	MalformedPathParameter      = merry.New("malformed path parameter")
 	MalformedQueryParamter      = merry.New("malformed query parameter")
 	MissingQueryParamter        = merry.New("missing query parameter")
 	UnknownQueryParamter        = merry.New("unknown query parameter")
@@ -34,6 +35,10 @@ func GetRequest(parent *http.Request) *Request {
 	request.Request = parent
 	request.PathParams = nil
 	request.QueryParams = nil
+	request.Variant = ""
+	request.Service = ""
+	request.Route = ""
+	request.Policy = nil
 	request.id = ""
 	request.clientIP = ""
 
@@ -49,8 +54,22 @@ type Request struct {
 	*http.Request
 	PathParams  []PathParameter
 	QueryParams []*QueryParameter
-	id          string
-	clientIP    string
+	// Variant is the name of the upstream variant chosen for
+	// the request by a traffic splitter, if any
+	Variant string
+	// Service is the name of the service of the endpoint that
+	// matched the request, empty until the request is routed
+	Service string
+	// Route is the route template of the endpoint that matched
+	// the request, e.g. "/users/:id", empty until the request
+	// is routed
+	Route string
+	// Policy is a copy of the policy of the pipeline
+	// servicing the request, nil until the request is routed.
+	// It must not be modified.
+	Policy   *Policy
+	id       string
+	clientIP string
 }
 
 // ParseQueryParameters parses the URL-encoded query string and
@@ -0,0 +1,108 @@
+package httpx
+
+import (
+	"net"
+	"regexp"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/errorx"
+)
+
+// DefaultRequestIDMaxLength is the maximum length in bytes of
+// request ids accepted by `RequestIDFromNetworks`.
+const DefaultRequestIDMaxLength = 128
+
+// RequestIDValidator determines whether a request id sent by the
+// user agent, or a proxy in front of the service, should be
+// trusted instead of generating a new one.
+type RequestIDValidator func(context.Context, *Request, string) bool
+
+func (v RequestIDValidator) InvokeSafely(ctx context.Context, request *Request, id string) (_ bool, exception merry.Error) {
+	defer errorx.CapturePanic(&exception, "panic in request id validator")
+
+	return v(ctx, request, id), nil
+}
+
+// RequestIDFormat returns a validator that accepts request ids
+// of at most `maxLength` bytes that match `pattern`.  A nil
+// pattern matches any id and a `maxLength` of zero allows any
+// length.
+func RequestIDFormat(pattern *regexp.Regexp, maxLength int) RequestIDValidator {
+	return func(_ context.Context, _ *Request, id string) bool {
+		if maxLength > 0 && len(id) > maxLength {
+			return false
+		}
+
+		return pattern == nil || pattern.MatchString(id)
+	}
+}
+
+// RequestIDFromNetworks returns a validator that accepts request
+// ids from peers with an address in one of the given CIDR
+// blocks, e.g. "10.0.0.0/8" for an internal load balancer.  The
+// address of the peer is taken from the `RemoteAddr` field of
+// the request, not from forwarding headers.  Ids longer than
+// `DefaultRequestIDMaxLength` are rejected.
+func RequestIDFromNetworks(cidrs ...string) (RequestIDValidator, merry.Error) {
+	return RequestIDFromNetworksWithMaxLength(DefaultRequestIDMaxLength, cidrs...)
+}
+
+// RequestIDFromNetworksWithMaxLength is like
+// `RequestIDFromNetworks` but rejects ids longer than
+// `maxLength` bytes instead.  If `maxLength` isn't positive
+// `DefaultRequestIDMaxLength` is used.
+func RequestIDFromNetworksWithMaxLength(maxLength int, cidrs ...string) (RequestIDValidator, merry.Error) {
+	if maxLength <= 0 {
+		maxLength = DefaultRequestIDMaxLength
+	}
+
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, merry.Prepend(err, "request id validator: parse network").Append(cidr)
+		}
+		networks[i] = network
+	}
+
+	validator := func(_ context.Context, request *Request, id string) bool {
+		if len(id) > maxLength {
+			return false
+		}
+
+		host, _, err := net.SplitHostPort(request.RemoteAddr)
+		if err != nil {
+			host = request.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return validator, nil
+}
+
+// AllRequestIDValidators returns a validator that accepts a
+// request id only if all of the given validators do.
+func AllRequestIDValidators(validators ...RequestIDValidator) RequestIDValidator {
+	return func(ctx context.Context, request *Request, id string) bool {
+		for _, validator := range validators {
+			if !validator(ctx, request, id) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
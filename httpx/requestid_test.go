@@ -0,0 +1,108 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+)
+
+func TestRequestIDValidatorPanic(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+
+	var cut RequestIDValidator
+	cut = func(context.Context, *Request, string) bool {
+		panic("i blewed up!")
+	}
+
+	ok, exception := cut.InvokeSafely(ctx, request, "abc")
+	assert.Error(t, exception)
+	assert.False(t, ok)
+}
+
+func TestRequestIDFormat(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+
+	cut := RequestIDFormat(regexp.MustCompile(`^[0-9a-f-]+$`), 36)
+	assert.True(t, cut(ctx, request, "4d371233-4470-53c3-b1df-9a3b231645dc"))
+	assert.False(t, cut(ctx, request, "4d371233-4470-53c3-b1df-9a3b231645dc0"))
+	assert.False(t, cut(ctx, request, "zalgo"))
+
+	cut = RequestIDFormat(nil, 0)
+	assert.True(t, cut(ctx, request, strings.Repeat("zalgo", 100)))
+}
+
+func TestRequestIDFromNetworks(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+
+	cut, err := RequestIDFromNetworks("10.0.0.0/8", "2001:db8::/32")
+	assert.NoError(t, err)
+
+	for addr, expected := range map[string]bool{
+		"10.1.2.3:1234":      true,
+		"[2001:db8::1]:1234": true,
+		"10.1.2.3":           true,
+		"192.0.2.1:1234":     false,
+		"[2001:db9::1]:1234": false,
+		"lolwut":             false,
+		"10.1.2.3.4:1234":    false,
+		"":                   false,
+	} {
+		request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+		request.RemoteAddr = addr
+		request.Header.Set("X-Forwarded-For", "10.1.2.3")
+
+		assert.Equal(t, expected, cut(ctx, request, "abc"), addr)
+	}
+}
+
+func TestRequestIDFromNetworksMaxLength(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+	request.RemoteAddr = "10.1.2.3:1234"
+
+	cut, err := RequestIDFromNetworks("10.0.0.0/8")
+	assert.NoError(t, err)
+	assert.True(t, cut(ctx, request, strings.Repeat("a", DefaultRequestIDMaxLength)))
+	assert.False(t, cut(ctx, request, strings.Repeat("a", DefaultRequestIDMaxLength+1)))
+
+	cut, err = RequestIDFromNetworksWithMaxLength(4, "10.0.0.0/8")
+	assert.NoError(t, err)
+	assert.True(t, cut(ctx, request, "abcd"))
+	assert.False(t, cut(ctx, request, "abcde"))
+
+	cut, err = RequestIDFromNetworksWithMaxLength(0, "10.0.0.0/8")
+	assert.NoError(t, err)
+	assert.False(t, cut(ctx, request, strings.Repeat("a", DefaultRequestIDMaxLength+1)))
+}
+
+func TestRequestIDFromNetworksMalformed(t *testing.T) {
+	cut, err := RequestIDFromNetworks("10.0.0.0/33")
+	assert.Error(t, err)
+	assert.Nil(t, cut)
+}
+
+func TestAllRequestIDValidators(t *testing.T) {
+	ctx := context.NewFakeContextDefaultFatal(t)
+	request := &Request{Request: httptest.NewRequest(http.MethodGet, "/test", nil)}
+	request.RemoteAddr = "10.1.2.3:1234"
+
+	networks, err := RequestIDFromNetworks("10.0.0.0/8")
+	assert.NoError(t, err)
+	cut := AllRequestIDValidators(networks, RequestIDFormat(nil, 8))
+
+	assert.True(t, cut(ctx, request, "abc"))
+	assert.False(t, cut(ctx, request, "abcdefghi"))
+
+	request.RemoteAddr = "192.0.2.1:1234"
+	assert.False(t, cut(ctx, request, "abc"))
+
+	assert.True(t, AllRequestIDValidators()(ctx, request, "abc"))
+}
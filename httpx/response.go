@@ -12,7 +12,9 @@ import (
 )
 
 const (
-	LocationHeaderKey = "Location"
+	AllowHeaderKey         = "Allow"
+	ContentLengthHeaderKey = "Content-Length"
+	LocationHeaderKey      = "Location"
 )
 
 var (
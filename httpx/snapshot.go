@@ -15,4 +15,15 @@ type ResponseSnapshot struct {
 	Start time.Time
 	// Duration of request servicing
 	Elapsed time.Duration
+	// Upstream variant chosen by a traffic splitter, if any
+	Variant string
+	// Name of the service that matched the request, if any
+	Service string
+	// Route template of the endpoint that matched the request,
+	// if any
+	Route string
+	// Policy of the pipeline that serviced the request, if
+	// any.  It is a copy made for the request and must not be
+	// modified.
+	Policy *Policy
 }
@@ -2,6 +2,7 @@ package httpx
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/ansel1/merry"
 
@@ -34,3 +35,52 @@ func WriteResponse(w http.ResponseWriter, response Response) (err merry.Error) {
 
 	return
 }
+
+// WriteHeadResponse writes the status code and headers of a
+// response instance to the ResponseWriter, discarding the body.
+// This is suitable for responding to a HEAD request with the
+// response of a GET request.
+// The response is serialized to determine the size of the body
+// and a "Content-Length" header, possibly zero, is added if the
+// response doesn't have one and its status allows a body.
+// Any error returned from `Response.Serialize` will be returned.
+func WriteHeadResponse(w http.ResponseWriter, response Response) (err merry.Error) {
+	defer errorx.CapturePanic(&err, "panic in response serializer")
+
+	var counter byteCounter
+	err = response.Serialize(&counter)
+
+	for k, vs := range response.Headers() {
+		w.Header()[k] = vs
+	}
+	if w.Header().Get(ContentLengthHeaderKey) == "" && bodyAllowedForStatus(response.StatusCode()) {
+		w.Header().Set(ContentLengthHeaderKey, strconv.FormatInt(int64(counter), 10))
+	}
+
+	w.WriteHeader(response.StatusCode())
+
+	return
+}
+
+// bodyAllowedForStatus returns false for the status codes that
+// must not have a body, or a "Content-Length" header.
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
+
+// byteCounter is an `io.Writer` that discards and counts all
+// bytes written.
+type byteCounter int64
+
+func (c *byteCounter) Write(bs []byte) (int, error) {
+	*c += byteCounter(len(bs))
+
+	return len(bs), nil
+}
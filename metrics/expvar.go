@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ExpvarCollector exposes the numeric and boolean values of an
+// `expvar.Var`, e.g. an `*expvar.Map`, as untyped metrics.  The
+// name of each metric is the namespace followed by the keys of
+// the value in the JSON representation of the variable, e.g.
+// the "in_flight" key of the "gateway" map becomes
+// "shisa_gateway_in_flight" for the "shisa_gateway" namespace.
+// Booleans are exposed as 0 or 1 and other values are ignored.
+type ExpvarCollector struct {
+	// Namespace is the prefix of the names of the metrics.
+	Namespace string
+
+	// Var is the variable to expose.
+	Var expvar.Var
+
+	// Skip optionally lists top level keys of the variable that
+	// shouldn't be exposed.
+	Skip []string
+}
+
+type expvarSample struct {
+	name  string
+	value float64
+}
+
+// WriteText writes the values of the variable in the
+// Prometheus text exposition format.
+func (c *ExpvarCollector) WriteText(w io.Writer) error {
+	if c.Var == nil {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(c.Var.String()))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		// variables that aren't valid JSON can't be exposed
+		return nil
+	}
+
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, key := range c.Skip {
+			delete(object, key)
+		}
+	}
+
+	var samples []expvarSample
+	samples = flatten(samples, SanitizeName(c.Namespace), value)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].name < samples[j].name
+	})
+
+	previous := ""
+	for _, sample := range samples {
+		if sample.name == previous {
+			continue
+		}
+		previous = sample.name
+
+		if err := writeHeader(w, sample.name, "", "untyped"); err != nil {
+			return err
+		}
+		if err := writeSample(w, sample.name, nil, nil, sample.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func flatten(samples []expvarSample, name string, value interface{}) []expvarSample {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			samples = append(samples, expvarSample{name: name, value: f})
+		}
+	case bool:
+		f := 0.0
+		if v {
+			f = 1
+		}
+		samples = append(samples, expvarSample{name: name, value: f})
+	case map[string]interface{}:
+		for key, child := range v {
+			samples = flatten(samples, name+SanitizeName("_"+key), child)
+		}
+	}
+
+	return samples
+}
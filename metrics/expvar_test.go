@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpvarCollector(t *testing.T) {
+	vars := new(expvar.Map).Init()
+	vars.Add("in_flight", 3)
+	vars.AddFloat("load-average", 0.5)
+	vars.Set("uptime", expvar.Func(func() interface{} { return "1h" }))
+	vars.Set("ready", expvar.Func(func() interface{} { return true }))
+	vars.Set("settings", expvar.Func(func() interface{} { return map[string]int{"MaxConcurrency": 10} }))
+	services := new(expvar.Map).Init()
+	services.Add("2xx", 7)
+	vars.Set("services", services)
+
+	r := NewRegistry()
+	r.Register("test", &ExpvarCollector{
+		Namespace: "shisa-test",
+		Var:       vars,
+		Skip:      []string{"settings"},
+	})
+
+	expected := `# TYPE shisa_test_in_flight untyped
+shisa_test_in_flight 3
+# TYPE shisa_test_load_average untyped
+shisa_test_load_average 0.5
+# TYPE shisa_test_ready untyped
+shisa_test_ready 1
+# TYPE shisa_test_services_2xx untyped
+shisa_test_services_2xx 7
+`
+	assert.Equal(t, expected, writeText(t, r))
+}
+
+func TestExpvarCollectorScalar(t *testing.T) {
+	v := new(expvar.Int)
+	v.Set(42)
+
+	r := NewRegistry()
+	r.Register("test", &ExpvarCollector{Namespace: "answer", Var: v})
+
+	assert.Equal(t, "# TYPE answer untyped\nanswer 42\n", writeText(t, r))
+}
+
+func TestExpvarCollectorInvalid(t *testing.T) {
+	r := NewRegistry()
+	r.Register("nil", &ExpvarCollector{Namespace: "nil"})
+	r.Register("invalid", &ExpvarCollector{Namespace: "invalid", Var: expvar.Func(func() interface{} { return make(chan int) })})
+
+	assert.Equal(t, "", writeText(t, r))
+}
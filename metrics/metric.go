@@ -0,0 +1,379 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ansel1/merry"
+)
+
+const (
+	counterKind   = "counter"
+	gaugeKind     = "gauge"
+	histogramKind = "histogram"
+)
+
+// DefaultBuckets are the upper bounds of the buckets used by
+// `NewHistogram` if none are given, suitable for latencies in
+// seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewCounter returns the counter with the given name from
+// `DefaultRegistry`, see `Registry.NewCounter`.
+func NewCounter(name, help string, labels ...string) (*Counter, merry.Error) {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+// NewGauge returns the gauge with the given name from
+// `DefaultRegistry`, see `Registry.NewGauge`.
+func NewGauge(name, help string, labels ...string) (*Gauge, merry.Error) {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+// NewHistogram returns the histogram with the given name from
+// `DefaultRegistry`, see `Registry.NewHistogram`.
+func NewHistogram(name, help string, buckets []float64, labels ...string) (*Histogram, merry.Error) {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewCounter returns a counter with the given name and label
+// names, creating it if needed.  An error is returned if a
+// different metric with the same name exists or a name is
+// invalid.
+func (r *Registry) NewCounter(name, help string, labels ...string) (*Counter, merry.Error) {
+	c := &Counter{family: newFamily(desc{name: name, help: help, kind: counterKind, labels: labels})}
+	m, err := r.register(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(*Counter), nil
+}
+
+// NewGauge returns a gauge with the given name and label names,
+// creating it if needed.  An error is returned if a different
+// metric with the same name exists or a name is invalid.
+func (r *Registry) NewGauge(name, help string, labels ...string) (*Gauge, merry.Error) {
+	g := &Gauge{family: newFamily(desc{name: name, help: help, kind: gaugeKind, labels: labels})}
+	m, err := r.register(g)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(*Gauge), nil
+}
+
+// NewHistogram returns a histogram with the given name, bucket
+// upper bounds and label names, creating it if needed.  If no
+// buckets are given `DefaultBuckets` will be used.  An error is
+// returned if the buckets aren't increasing, a different metric
+// with the same name exists or a name is invalid.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) (*Histogram, merry.Error) {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	for i := range buckets {
+		if math.IsNaN(buckets[i]) || (i > 0 && buckets[i] <= buckets[i-1]) {
+			return nil, merry.New("metrics: check invariants: buckets not increasing").Append(name)
+		}
+	}
+	if math.IsInf(buckets[len(buckets)-1], 1) {
+		buckets = buckets[:len(buckets)-1]
+	}
+	buckets = append([]float64(nil), buckets...)
+
+	h := &Histogram{family: newFamily(desc{name: name, help: help, kind: histogramKind, labels: labels, buckets: buckets})}
+	m, err := r.register(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(*Histogram), nil
+}
+
+type desc struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+}
+
+func (d desc) matches(other desc) bool {
+	if d.name != other.name || d.kind != other.kind {
+		return false
+	}
+	if len(d.labels) != len(other.labels) || len(d.buckets) != len(other.buckets) {
+		return false
+	}
+	for i := range d.labels {
+		if d.labels[i] != other.labels[i] {
+			return false
+		}
+	}
+	for i := range d.buckets {
+		if d.buckets[i] != other.buckets[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type metric interface {
+	Collector
+	desc() desc
+}
+
+// series is the value of a metric for one set of label values.
+type series struct {
+	bits    uint64 // float64 value of a counter or gauge, sum of a histogram
+	count   uint64
+	buckets []uint64
+	values  []string
+}
+
+func (s *series) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+func (s *series) store(value float64) {
+	atomic.StoreUint64(&s.bits, math.Float64bits(value))
+}
+
+func (s *series) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&s.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&s.bits, old, updated) {
+			return
+		}
+	}
+}
+
+type family struct {
+	d      desc
+	mtx    sync.RWMutex
+	series map[string]*series
+}
+
+func newFamily(d desc) *family {
+	d.labels = append([]string(nil), d.labels...)
+	return &family{
+		d:      d,
+		series: make(map[string]*series),
+	}
+}
+
+func (f *family) desc() desc {
+	return f.d
+}
+
+// get returns the series for the label values, creating it if
+// needed.
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.d.labels) {
+		panic(fmt.Sprintf("metrics: %s: expected %d label values, got %d", f.d.name, len(f.d.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	f.mtx.RLock()
+	s, ok := f.series[key]
+	f.mtx.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+	s = &series{values: append([]string(nil), values...)}
+	if f.d.kind == histogramKind {
+		s.buckets = make([]uint64, len(f.d.buckets))
+	}
+	f.series[key] = s
+
+	return s
+}
+
+// find returns the series for the label values or nil.
+func (f *family) find(values []string) *series {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.series[strings.Join(values, "\xff")]
+}
+
+func (f *family) sorted() []*series {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*series, len(keys))
+	for i, key := range keys {
+		result[i] = f.series[key]
+	}
+
+	return result
+}
+
+// WriteText writes the metric in the Prometheus text exposition
+// format.
+func (f *family) WriteText(w io.Writer) error {
+	if err := writeHeader(w, f.d.name, f.d.help, f.d.kind); err != nil {
+		return err
+	}
+
+	for _, s := range f.sorted() {
+		if f.d.kind != histogramKind {
+			if err := writeSample(w, f.d.name, f.d.labels, s.values, s.load()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		labels := append(append([]string(nil), f.d.labels...), "le")
+		values := append(append([]string(nil), s.values...), "")
+		var cumulative uint64
+		for i, bound := range f.d.buckets {
+			cumulative += atomic.LoadUint64(&s.buckets[i])
+			values[len(values)-1] = formatFloat(bound)
+			if err := writeSample(w, f.d.name+"_bucket", labels, values, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		count := atomic.LoadUint64(&s.count)
+		values[len(values)-1] = "+Inf"
+		if err := writeSample(w, f.d.name+"_bucket", labels, values, float64(count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, f.d.name+"_sum", f.d.labels, s.values, s.load()); err != nil {
+			return err
+		}
+		if err := writeSample(w, f.d.name+"_count", f.d.labels, s.values, float64(count)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Counter is a value that only increases, e.g. the number of
+// requests served.  The methods taking label values panic if
+// the number of values differs from the number of labels of the
+// counter.
+type Counter struct {
+	*family
+}
+
+// Inc adds one to the counter for the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.get(labelValues).add(1)
+}
+
+// Add adds the delta to the counter for the label values.
+// Negative deltas are ignored.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.get(labelValues).add(delta)
+}
+
+// Value returns the counter for the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	if s := c.find(labelValues); s != nil {
+		return s.load()
+	}
+
+	return 0
+}
+
+// Gauge is a value that may increase and decrease, e.g. the
+// number of requests in flight.  The methods taking label
+// values panic if the number of values differs from the number
+// of labels of the gauge.
+type Gauge struct {
+	*family
+}
+
+// Set changes the gauge for the label values.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.get(labelValues).store(value)
+}
+
+// Add adds the delta to the gauge for the label values.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.get(labelValues).add(delta)
+}
+
+// Inc adds one to the gauge for the label values.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.get(labelValues).add(1)
+}
+
+// Dec subtracts one from the gauge for the label values.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.get(labelValues).add(-1)
+}
+
+// Value returns the gauge for the label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	if s := g.find(labelValues); s != nil {
+		return s.load()
+	}
+
+	return 0
+}
+
+// Histogram counts observations, e.g. request latencies, in
+// buckets.  The methods taking label values panic if the number
+// of values differs from the number of labels of the histogram.
+type Histogram struct {
+	*family
+}
+
+// Observe adds the value to the histogram for the label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	s := h.get(labelValues)
+
+	i := sort.SearchFloat64s(h.d.buckets, value)
+	if i < len(s.buckets) {
+		atomic.AddUint64(&s.buckets[i], 1)
+	}
+	atomic.AddUint64(&s.count, 1)
+	s.add(value)
+}
+
+// Count returns the number of observations for the label
+// values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	if s := h.find(labelValues); s != nil {
+		return atomic.LoadUint64(&s.count)
+	}
+
+	return 0
+}
+
+// Sum returns the sum of the observations for the label values.
+func (h *Histogram) Sum(labelValues ...string) float64 {
+	if s := h.find(labelValues); s != nil {
+		return s.load()
+	}
+
+	return 0
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeText(t *testing.T, r *Registry) string {
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteText(&buf))
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewCounter("requests_total", "Requests served.", "method", "code")
+	assert.NoError(t, err)
+
+	cut.Inc("GET", "200")
+	cut.Inc("GET", "200")
+	cut.Add(3, "POST", "201")
+	cut.Add(-1, "POST", "201")
+
+	assert.Equal(t, float64(2), cut.Value("GET", "200"))
+	assert.Equal(t, float64(3), cut.Value("POST", "201"))
+	assert.Zero(t, cut.Value("PUT", "200"))
+
+	expected := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{method="GET",code="200"} 2
+requests_total{method="POST",code="201"} 3
+`
+	assert.Equal(t, expected, writeText(t, r))
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewCounter("hits", "")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cut.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(1000), cut.Value())
+}
+
+func TestCounterWrongLabelCount(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewCounter("requests_total", "", "method")
+	assert.NoError(t, err)
+
+	assert.Panics(t, func() { cut.Inc() })
+	assert.Panics(t, func() { cut.Inc("GET", "200") })
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewGauge("in_flight", "Requests in flight.")
+	assert.NoError(t, err)
+
+	cut.Inc()
+	cut.Inc()
+	cut.Dec()
+	assert.Equal(t, float64(1), cut.Value())
+
+	cut.Add(-3.5)
+	assert.Equal(t, -2.5, cut.Value())
+
+	cut.Set(math.Inf(1))
+
+	expected := `# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight +Inf
+`
+	assert.Equal(t, expected, writeText(t, r))
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewHistogram("latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	assert.NoError(t, err)
+
+	cut.Observe(0.05, "/a")
+	cut.Observe(0.1, "/a")
+	cut.Observe(0.5, "/a")
+	cut.Observe(5, "/a")
+
+	assert.Equal(t, uint64(4), cut.Count("/a"))
+	assert.Equal(t, 5.65, cut.Sum("/a"))
+	assert.Zero(t, cut.Count("/b"))
+	assert.Zero(t, cut.Sum("/b"))
+
+	expected := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="1"} 3
+latency_seconds_bucket{route="/a",le="+Inf"} 4
+latency_seconds_sum{route="/a"} 5.65
+latency_seconds_count{route="/a"} 4
+`
+	assert.Equal(t, expected, writeText(t, r))
+}
+
+func TestHistogramDefaultBuckets(t *testing.T) {
+	r := NewRegistry()
+	cut, err := r.NewHistogram("latency_seconds", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBuckets, cut.d.buckets)
+
+	other, err := r.NewHistogram("other_seconds", "", []float64{1, math.Inf(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1}, other.d.buckets)
+}
+
+func TestHistogramBadBuckets(t *testing.T) {
+	r := NewRegistry()
+
+	_, err := r.NewHistogram("latency_seconds", "", []float64{1, 0.5})
+	assert.Error(t, err)
+
+	_, err = r.NewHistogram("latency_seconds", "", []float64{1, 1})
+	assert.Error(t, err)
+
+	_, err = r.NewHistogram("latency_seconds", "", []float64{math.NaN()})
+	assert.Error(t, err)
+}
+
+func TestDefaultRegistryConstructors(t *testing.T) {
+	counter, err := NewCounter("metrics_test_counter", "")
+	assert.NoError(t, err)
+	defer DefaultRegistry.Unregister("metrics_test_counter")
+
+	gauge, err := NewGauge("metrics_test_gauge", "")
+	assert.NoError(t, err)
+	defer DefaultRegistry.Unregister("metrics_test_gauge")
+
+	histogram, err := NewHistogram("metrics_test_histogram", "", nil)
+	assert.NoError(t, err)
+	defer DefaultRegistry.Unregister("metrics_test_histogram")
+
+	counter.Inc()
+	gauge.Set(2)
+	histogram.Observe(3)
+
+	text := writeText(t, DefaultRegistry)
+	assert.Contains(t, text, "metrics_test_counter 1\n")
+	assert.Contains(t, text, "metrics_test_gauge 2\n")
+	assert.Contains(t, text, "metrics_test_histogram_count 1\n")
+}
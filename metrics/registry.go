@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ansel1/merry"
+)
+
+const (
+	// TextContentType is the media type of the Prometheus text
+	// exposition format written by `Registry.WriteText`.
+	TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DefaultRegistry is the registry used by `NewCounter`,
+// `NewGauge` and `NewHistogram`.
+var DefaultRegistry = NewRegistry()
+
+// Collector writes metrics in the Prometheus text exposition
+// format.  All of the samples of a metric must be written
+// together, preceded by its HELP and TYPE lines.
+type Collector interface {
+	WriteText(w io.Writer) error
+}
+
+// Registry is a set of named collectors that are exposed
+// together.  It is safe for concurrent use.
+type Registry struct {
+	mtx        sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Register adds a collector with the given name, replacing any
+// existing one.
+func (r *Registry) Register(name string, c Collector) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.collectors[name] = c
+}
+
+// Unregister removes the named collector.
+func (r *Registry) Unregister(name string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.collectors, name)
+}
+
+// WriteText writes all of the collectors, in order of their
+// names, in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) merry.Error {
+	r.mtx.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]Collector, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mtx.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	for i, c := range collectors {
+		if err := c.WriteText(buf); err != nil {
+			return merry.Prepend(err, "metrics: write text").Append(names[i])
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return merry.Prepend(err, "metrics: write text")
+	}
+
+	return nil
+}
+
+// register adds the metric unless one with the same name exists.
+// The existing metric is returned if it is the same kind with
+// the same labels, otherwise an error.
+func (r *Registry) register(m metric) (metric, merry.Error) {
+	if !validName(m.desc().name, false) {
+		return nil, merry.New("metrics: register: invalid metric name").Append(m.desc().name)
+	}
+	for _, label := range m.desc().labels {
+		if !validName(label, true) || label == "le" {
+			return nil, merry.New("metrics: register: invalid label name").Append(label)
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if existing, ok := r.collectors[m.desc().name]; ok {
+		if other, ok := existing.(metric); ok && other.desc().matches(m.desc()) {
+			return other, nil
+		}
+		return nil, merry.New("metrics: register: conflicting metric").Append(m.desc().name)
+	}
+	r.collectors[m.desc().name] = m
+
+	return m, nil
+}
+
+// validName returns true if the value is a valid metric name,
+// or label name if `label` is true.
+func validName(value string, label bool) bool {
+	if value == "" || (label && strings.HasPrefix(value, "__")) {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case c == ':' && !label:
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// SanitizeName replaces the characters of the value that aren't
+// valid in a metric name with underscores.
+func SanitizeName(value string) string {
+	b := []byte(value)
+	for i, c := range b {
+		switch {
+		case c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) error {
+	if help != "" {
+		if _, err := io.WriteString(w, "# HELP "+name+" "+escapeHelp(help)+"\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "# TYPE "+name+" "+kind+"\n")
+
+	return err
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, value float64) error {
+	b := make([]byte, 0, 64)
+	b = append(b, name...)
+	if len(labels) != 0 {
+		b = append(b, '{')
+		for i, label := range labels {
+			if i != 0 {
+				b = append(b, ',')
+			}
+			b = append(b, label...)
+			b = append(b, '=', '"')
+			b = append(b, escapeLabelValue(values[i])...)
+			b = append(b, '"')
+		}
+		b = append(b, '}')
+	}
+	b = append(b, ' ')
+	b = append(b, formatFloat(value)...)
+	b = append(b, '\n')
+
+	_, err := w.Write(b)
+
+	return err
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
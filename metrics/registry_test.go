@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingCollector struct{}
+
+func (failingCollector) WriteText(io.Writer) error {
+	return errors.New("i blewed up!")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("i blewed up!")
+}
+
+func TestRegistryExistingMetric(t *testing.T) {
+	r := NewRegistry()
+	first, err := r.NewCounter("requests_total", "", "method")
+	assert.NoError(t, err)
+
+	second, err := r.NewCounter("requests_total", "", "method")
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+}
+
+func TestRegistryConflictingMetric(t *testing.T) {
+	r := NewRegistry()
+	_, err := r.NewCounter("requests_total", "", "method")
+	assert.NoError(t, err)
+
+	_, err = r.NewCounter("requests_total", "", "code")
+	assert.Error(t, err)
+
+	_, err = r.NewGauge("requests_total", "", "method")
+	assert.Error(t, err)
+
+	_, err = r.NewHistogram("requests_total", "", nil, "method")
+	assert.Error(t, err)
+
+	r.Register("custom", failingCollector{})
+	_, err = r.NewCounter("custom", "")
+	assert.Error(t, err)
+}
+
+func TestRegistryInvalidNames(t *testing.T) {
+	r := NewRegistry()
+
+	for _, name := range []string{"", "1xx", "zalgo-he-comes", "he comes"} {
+		_, err := r.NewCounter(name, "")
+		assert.Error(t, err, name)
+	}
+
+	for _, label := range []string{"", "le", "__reserved", "a:b", "1xx"} {
+		_, err := r.NewCounter("requests_total", "", label)
+		assert.Error(t, err, label)
+	}
+
+	_, err := r.NewCounter("http:requests_total", "", "_method")
+	assert.NoError(t, err)
+}
+
+func TestRegistryWriteTextOrder(t *testing.T) {
+	r := NewRegistry()
+	b, err := r.NewGauge("b", "")
+	assert.NoError(t, err)
+	a, err := r.NewGauge("a", "")
+	assert.NoError(t, err)
+	a.Set(1)
+	b.Set(2)
+
+	assert.Equal(t, "# TYPE a gauge\na 1\n# TYPE b gauge\nb 2\n", writeText(t, r))
+
+	r.Unregister("a")
+	assert.Equal(t, "# TYPE b gauge\nb 2\n", writeText(t, r))
+}
+
+func TestRegistryWriteTextCollectorError(t *testing.T) {
+	r := NewRegistry()
+	r.Register("zalgo", failingCollector{})
+
+	assert.Error(t, r.WriteText(ioutil.Discard))
+}
+
+func TestRegistryWriteTextWriterError(t *testing.T) {
+	r := NewRegistry()
+	g, err := r.NewGauge("zalgo", "")
+	assert.NoError(t, err)
+	g.Set(1)
+
+	assert.Error(t, r.WriteText(failingWriter{}))
+}
+
+func TestRegistryEscaping(t *testing.T) {
+	r := NewRegistry()
+	c, err := r.NewCounter("requests_total", "Requests \\ served\nby route.", "route")
+	assert.NoError(t, err)
+	c.Inc("/\"zalgo\"\\\n")
+
+	expected := `# HELP requests_total Requests \\ served\nby route.
+# TYPE requests_total counter
+requests_total{route="/\"zalgo\"\\\n"} 1
+`
+	assert.Equal(t, expected, writeText(t, r))
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "start_time", SanitizeName("start-time"))
+	assert.Equal(t, "_xx", SanitizeName("2xx"))
+	assert.Equal(t, "http:requests_total", SanitizeName("http:requests_total"))
+	assert.Equal(t, "he_comes_", SanitizeName("he comes!"))
+}
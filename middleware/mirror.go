@@ -0,0 +1,385 @@
+package middleware
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/errorx"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/tracecontext"
+)
+
+const (
+	defaultMirrorConcurrency = 16
+	defaultMirrorBodySize    = 1 << 20
+	defaultMirrorTimeout     = time.Second * 10
+)
+
+// Difference describes how the response from the shadow server
+// for a mirrored request differs from the primary response.
+// Bodies are truncated to the `MaxBodySize` of the mirror.
+type Difference struct {
+	PrimaryStatusCode int
+	ShadowStatusCode  int
+	PrimaryBody       []byte
+	ShadowBody        []byte
+}
+
+// DiffHook is invoked when the status code or body of the
+// response from the shadow server differs from the primary
+// response.
+type DiffHook func(context.Context, *httpx.Request, Difference)
+
+func (h DiffHook) InvokeSafely(ctx context.Context, request *httpx.Request, diff Difference) (exception merry.Error) {
+	defer errorx.CapturePanic(&exception, "panic in diff hook")
+
+	h(ctx, request, diff)
+
+	return
+}
+
+// Mirror sends a copy of a percentage of the requests handled by
+// a `ReverseProxy` to a shadow server.  Copies are sent
+// asynchronously and the responses are discarded, so the shadow
+// server never affects the response to the user agent.
+//
+// A mirror must not be copied after first use.
+type Mirror struct {
+	// counters first for 64-bit alignment
+	mirrored int64
+	dropped  int64
+	failed   int64
+	differed int64
+
+	// Router must be non-nil and returns the request to contact
+	// the shadow server.  It is passed a copy of the request
+	// made before the `Router` of the proxy is run.
+	Router Router
+
+	// Invoker can be set to optionally customize how the shadow
+	// server is contacted.  If this is not set
+	// `http.DefaultTransport` will be used.
+	Invoker Invoker
+
+	// Percentage of requests to mirror, from 0 to 100.
+	Percentage float64
+
+	// MaxConcurrency is the maximum number of mirrored requests
+	// in flight.  Requests that would exceed it are not
+	// mirrored.  The default is 16.
+	MaxConcurrency int
+
+	// MaxBodySize is the size of the largest request body that
+	// will be mirrored and of the response bodies compared for
+	// the `DiffHook`.  The default is 1 MiB.
+	MaxBodySize int64
+
+	// Timeout is the maximum duration of a mirrored request,
+	// including waiting for the primary response to be sent
+	// when comparing them.  The default is 10 seconds.
+	Timeout time.Duration
+
+	// DiffHook can be set to optionally be notified of
+	// differences between the primary and shadow responses.
+	DiffHook DiffHook
+
+	// ErrorHook can be set to optionally be notified of errors
+	// sending mirrored requests.
+	ErrorHook httpx.ErrorHook
+
+	once  sync.Once
+	slots chan struct{}
+}
+
+// String returns the mirror counters as JSON so it may be
+// published with `expvar`.
+func (m *Mirror) String() string {
+	var stats = struct {
+		Mirrored int64 `json:"mirrored"`
+		Dropped  int64 `json:"dropped"`
+		Failed   int64 `json:"failed"`
+		Differed int64 `json:"differed"`
+	}{
+		Mirrored: atomic.LoadInt64(&m.mirrored),
+		Dropped:  atomic.LoadInt64(&m.dropped),
+		Failed:   atomic.LoadInt64(&m.failed),
+		Differed: atomic.LoadInt64(&m.differed),
+	}
+
+	bs, _ := json.Marshal(stats)
+
+	return string(bs)
+}
+
+func (m *Mirror) init() {
+	concurrency := m.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultMirrorConcurrency
+	}
+	m.slots = make(chan struct{}, concurrency)
+}
+
+func (m *Mirror) maxBodySize() int64 {
+	if m.MaxBodySize <= 0 {
+		return defaultMirrorBodySize
+	}
+
+	return m.MaxBodySize
+}
+
+// start mirrors the request if it is sampled and there is
+// capacity.  The request body is buffered so it may be read by
+// both servers.  The result should be passed the primary
+// response and is nil if the request is not mirrored.
+func (m *Mirror) start(ctx context.Context, request *httpx.Request) *shadowRequest {
+	if m.Percentage <= 0 || rand.Float64()*100 >= m.Percentage {
+		return nil
+	}
+
+	m.once.Do(m.init)
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		atomic.AddInt64(&m.dropped, 1)
+		return nil
+	}
+
+	var body []byte
+	if request.Body != nil {
+		limit := m.maxBodySize()
+		buf, err := ioutil.ReadAll(io.LimitReader(request.Body, limit+1))
+		if err != nil || int64(len(buf)) > limit {
+			request.Body = readCloser{
+				Reader: io.MultiReader(bytes.NewReader(buf), request.Body),
+				Closer: request.Body,
+			}
+			<-m.slots
+			atomic.AddInt64(&m.dropped, 1)
+			return nil
+		}
+		request.Body = readCloser{Reader: bytes.NewReader(buf), Closer: request.Body}
+		body = buf
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = defaultMirrorTimeout
+	}
+
+	shadowCtx := context.New(stdctx.Background()).
+		WithRequestID(ctx.RequestID()).
+		WithActor(ctx.Actor())
+	if tc, ok := tracecontext.FromContext(ctx); ok {
+		shadowCtx = tracecontext.WithTraceContext(shadowCtx, tc)
+	}
+	if parent := ctx.Span(); parent != nil {
+		span := parent.Tracer().StartSpan("ReverseHTTPProxy.Mirror", opentracing.FollowsFrom(parent.Context()))
+		ext.Component.Set(span, "middleware")
+		shadowCtx = shadowCtx.WithSpan(span)
+	}
+	shadowCtx, cancel := shadowCtx.WithTimeout(timeout)
+
+	shadow := &httpx.Request{Request: request.Request.WithContext(shadowCtx)}
+	url := *request.URL
+	shadow.URL = &url
+	shadow.Header = cloneHeaders(request.Header)
+	shadow.QueryParams = cloneQueryParams(request.QueryParams)
+	shadow.PathParams = clonePathParams(request.PathParams)
+	if body != nil {
+		shadow.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	s := &shadowRequest{mirror: m}
+	if m.DiffHook != nil {
+		s.primary = make(chan capturedBody, 1)
+	}
+
+	atomic.AddInt64(&m.mirrored, 1)
+	go s.run(shadowCtx, cancel, shadow)
+
+	return s
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type capturedBody struct {
+	code int
+	body []byte
+}
+
+// limitedBuffer retains up to limit bytes written to it and
+// discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - int64(b.Len()); remaining > 0 {
+		if int64(len(p)) > remaining {
+			b.Buffer.Write(p[:remaining])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+type shadowRequest struct {
+	mirror  *Mirror
+	primary chan capturedBody
+}
+
+// capture returns a response that records the primary response
+// as it is serialized for comparison with the shadow response.
+func (s *shadowRequest) capture(response httpx.Response) httpx.Response {
+	if s == nil || s.primary == nil {
+		return response
+	}
+
+	return &capturedResponse{Response: response, shadow: s}
+}
+
+func (s *shadowRequest) run(ctx context.Context, cancel stdctx.CancelFunc, request *httpx.Request) {
+	defer func() { <-s.mirror.slots }()
+	defer cancel()
+
+	span := noopSpan
+	if ctxSpan := ctx.Span(); ctxSpan != nil {
+		span = ctxSpan
+		defer span.Finish()
+	}
+
+	m := s.mirror
+	if m.Router == nil {
+		s.fail(ctx, request, merry.New("proxy middleware: mirror: check invariants: router is nil"))
+		return
+	}
+
+	out, err, exception := m.Router.InvokeSafely(ctx, request)
+	if exception != nil {
+		s.fail(ctx, request, exception.Prepend("proxy middleware: mirror: run Router"))
+		return
+	} else if err != nil {
+		s.fail(ctx, request, err.Prepend("proxy middleware: mirror: run Router"))
+		return
+	} else if out == nil {
+		s.fail(ctx, request, merry.New("proxy middleware: mirror: run Router: result is nil"))
+		return
+	}
+	request = out
+	request.Close = false
+
+	for _, h := range hopHeaders {
+		delete(request.Header, h)
+	}
+
+	if err := injectTracingHeaders(ctx, span, request.Header); err != nil {
+		s.fail(ctx, request, merry.Prepend(err, "proxy middleware: mirror: inject open tracing headers"))
+		return
+	}
+
+	var response httpx.Response
+	if m.Invoker == nil {
+		r, err := http.DefaultTransport.RoundTrip(request.Request)
+		if err != nil {
+			s.fail(ctx, request, merry.Prepend(err, "proxy middleware: mirror: run default invoker"))
+			return
+		}
+		response = httpx.ResponseAdapter{Response: r}
+	} else {
+		response, err, exception = m.Invoker.InvokeSafely(ctx, request)
+		if exception != nil {
+			s.fail(ctx, request, exception.Prepend("proxy middleware: mirror: run Invoker"))
+			return
+		} else if err != nil {
+			s.fail(ctx, request, err.Prepend("proxy middleware: mirror: run Invoker"))
+			return
+		} else if response == nil {
+			s.fail(ctx, request, merry.New("proxy middleware: mirror: run Invoker: result is nil"))
+			return
+		}
+	}
+
+	shadowBody := &limitedBuffer{limit: m.maxBodySize()}
+	if s.primary == nil {
+		shadowBody.limit = 0
+	}
+	if err := response.Serialize(shadowBody); err != nil {
+		s.fail(ctx, request, err.Prepend("proxy middleware: mirror: read response"))
+		return
+	}
+
+	if s.primary == nil {
+		return
+	}
+
+	var primary capturedBody
+	select {
+	case primary = <-s.primary:
+	case <-ctx.Done():
+		return
+	}
+
+	if primary.code == response.StatusCode() && bytes.Equal(primary.body, shadowBody.Bytes()) {
+		return
+	}
+
+	atomic.AddInt64(&m.differed, 1)
+	diff := Difference{
+		PrimaryStatusCode: primary.code,
+		ShadowStatusCode:  response.StatusCode(),
+		PrimaryBody:       primary.body,
+		ShadowBody:        shadowBody.Bytes(),
+	}
+	if exception := m.DiffHook.InvokeSafely(ctx, request, diff); exception != nil {
+		s.fail(ctx, request, exception.Prepend("proxy middleware: mirror: run DiffHook"))
+	}
+}
+
+func (s *shadowRequest) fail(ctx context.Context, request *httpx.Request, err merry.Error) {
+	atomic.AddInt64(&s.mirror.failed, 1)
+
+	if span := ctx.Span(); span != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.String("error", err.Error()))
+	}
+
+	s.mirror.ErrorHook.InvokeSafely(ctx, request, err)
+}
+
+type capturedResponse struct {
+	httpx.Response
+	shadow *shadowRequest
+}
+
+func (r *capturedResponse) Serialize(w io.Writer) merry.Error {
+	buf := &limitedBuffer{limit: r.shadow.mirror.maxBodySize()}
+	err := r.Response.Serialize(io.MultiWriter(w, buf))
+
+	select {
+	case r.shadow.primary <- capturedBody{code: r.StatusCode(), body: buf.Bytes()}:
+	default:
+	}
+
+	return err
+}
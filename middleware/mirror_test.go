@@ -0,0 +1,325 @@
+package middleware
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+)
+
+type mirrorStats struct {
+	Mirrored int64 `json:"mirrored"`
+	Dropped  int64 `json:"dropped"`
+	Failed   int64 `json:"failed"`
+	Differed int64 `json:"differed"`
+}
+
+func readMirrorStats(t *testing.T, m *Mirror) (stats mirrorStats) {
+	assert.NoError(t, json.Unmarshal([]byte(m.String()), &stats))
+	return
+}
+
+func passthroughRouter(c context.Context, r *httpx.Request) (*httpx.Request, merry.Error) {
+	return r, nil
+}
+
+func textInvoker(code int, text string, body *string) Invoker {
+	return func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+		if r.Body != nil && body != nil {
+			bs, _ := ioutil.ReadAll(r.Body)
+			*body = string(bs)
+		}
+		response := httptest.NewRecorder()
+		response.WriteHeader(code)
+		response.WriteString(text)
+		return httpx.ResponseAdapter{Response: response.Result()}, nil
+	}
+}
+
+func serveProxy(t *testing.T, cut *ReverseProxy, body string) {
+	r := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	request := &httpx.Request{Request: r}
+	ctx := context.New(stdctx.Background())
+
+	response := cut.Service(ctx, request)
+	assert.NotNil(t, response)
+	assert.NoError(t, response.Serialize(ioutil.Discard))
+}
+
+func TestMirror(t *testing.T) {
+	var primaryBody, shadowBody string
+	shadowed := make(chan struct{})
+	var diffed bool
+	mirror := &Mirror{
+		Router: func(c context.Context, r *httpx.Request) (*httpx.Request, merry.Error) {
+			r.URL.Host = "shadow.example.com"
+			return r, nil
+		},
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			defer close(shadowed)
+			return textInvoker(http.StatusOK, "hello", &shadowBody)(c, r)
+		},
+		Percentage: 100,
+		DiffHook: func(context.Context, *httpx.Request, Difference) {
+			diffed = true
+		},
+		ErrorHook: func(context.Context, *httpx.Request, merry.Error) {
+			t.Fatal("unexpected error")
+		},
+	}
+	cut := &ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			assert.NotEqual(t, "shadow.example.com", r.URL.Host)
+			return textInvoker(http.StatusOK, "hello", &primaryBody)(c, r)
+		},
+		Mirror: mirror,
+	}
+
+	serveProxy(t, cut, "zalgo")
+	<-shadowed
+	waitForMirror(t, mirror)
+
+	assert.Equal(t, "zalgo", primaryBody)
+	assert.Equal(t, "zalgo", shadowBody)
+	assert.False(t, diffed)
+	assert.Equal(t, mirrorStats{Mirrored: 1}, readMirrorStats(t, mirror))
+}
+
+func TestMirrorDifference(t *testing.T) {
+	diffs := make(chan Difference, 1)
+	mirror := &Mirror{
+		Router:     passthroughRouter,
+		Invoker:    textInvoker(http.StatusTeapot, "shadow", nil),
+		Percentage: 100,
+		DiffHook: func(c context.Context, r *httpx.Request, diff Difference) {
+			diffs <- diff
+		},
+	}
+	cut := &ReverseProxy{
+		Router:  passthroughRouter,
+		Invoker: textInvoker(http.StatusOK, "primary", nil),
+		Mirror:  mirror,
+	}
+
+	serveProxy(t, cut, "")
+
+	select {
+	case diff := <-diffs:
+		assert.Equal(t, http.StatusOK, diff.PrimaryStatusCode)
+		assert.Equal(t, http.StatusTeapot, diff.ShadowStatusCode)
+		assert.Equal(t, "primary", string(diff.PrimaryBody))
+		assert.Equal(t, "shadow", string(diff.ShadowBody))
+	case <-time.After(time.Second):
+		t.Fatal("diff hook not invoked")
+	}
+
+	waitForMirror(t, mirror)
+	assert.Equal(t, mirrorStats{Mirrored: 1, Differed: 1}, readMirrorStats(t, mirror))
+}
+
+func TestMirrorDifferenceHookPanic(t *testing.T) {
+	errs := make(chan merry.Error, 1)
+	mirror := &Mirror{
+		Router:     passthroughRouter,
+		Invoker:    textInvoker(http.StatusOK, "shadow", nil),
+		Percentage: 100,
+		DiffHook: func(context.Context, *httpx.Request, Difference) {
+			panic(merry.New("i blewed up!"))
+		},
+		ErrorHook: func(c context.Context, r *httpx.Request, err merry.Error) {
+			errs <- err
+		},
+	}
+	cut := &ReverseProxy{
+		Router:  passthroughRouter,
+		Invoker: textInvoker(http.StatusOK, "primary", nil),
+		Mirror:  mirror,
+	}
+
+	serveProxy(t, cut, "")
+
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "run DiffHook")
+	case <-time.After(time.Second):
+		t.Fatal("error hook not invoked")
+	}
+}
+
+func TestMirrorPercentageZero(t *testing.T) {
+	mirror := &Mirror{
+		Router: func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+			t.Fatal("unexpected mirror")
+			return nil, nil
+		},
+	}
+	cut := &ReverseProxy{
+		Router:  passthroughRouter,
+		Invoker: textInvoker(http.StatusOK, "hello", nil),
+		Mirror:  mirror,
+	}
+
+	serveProxy(t, cut, "")
+
+	assert.Equal(t, mirrorStats{}, readMirrorStats(t, mirror))
+}
+
+func TestMirrorConcurrencyExceeded(t *testing.T) {
+	release := make(chan struct{})
+	mirror := &Mirror{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			<-release
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+		Percentage:     100,
+		MaxConcurrency: 1,
+	}
+	cut := &ReverseProxy{
+		Router:  passthroughRouter,
+		Invoker: textInvoker(http.StatusOK, "hello", nil),
+		Mirror:  mirror,
+	}
+
+	serveProxy(t, cut, "")
+	serveProxy(t, cut, "")
+
+	assert.Equal(t, mirrorStats{Mirrored: 1, Dropped: 1}, readMirrorStats(t, mirror))
+
+	close(release)
+	waitForMirror(t, mirror)
+
+	serveProxy(t, cut, "")
+	waitForMirror(t, mirror)
+
+	assert.Equal(t, mirrorStats{Mirrored: 2, Dropped: 1}, readMirrorStats(t, mirror))
+}
+
+func TestMirrorBodyTooLarge(t *testing.T) {
+	var primaryBody string
+	mirror := &Mirror{
+		Router: func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+			t.Fatal("unexpected mirror")
+			return nil, nil
+		},
+		Percentage:  100,
+		MaxBodySize: 4,
+	}
+	cut := &ReverseProxy{
+		Router:  passthroughRouter,
+		Invoker: textInvoker(http.StatusOK, "hello", &primaryBody),
+		Mirror:  mirror,
+	}
+
+	serveProxy(t, cut, "he comes")
+
+	assert.Equal(t, "he comes", primaryBody)
+	assert.Equal(t, mirrorStats{Dropped: 1}, readMirrorStats(t, mirror))
+}
+
+func TestMirrorErrors(t *testing.T) {
+	for name, mirror := range map[string]*Mirror{
+		"missing router": {},
+		"router error": {
+			Router: func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+				return nil, merry.New("i blewed up!")
+			},
+		},
+		"router panic": {
+			Router: func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+				panic("i blewed up!")
+			},
+		},
+		"nil router result": {
+			Router: func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+				return nil, nil
+			},
+		},
+		"invoker error": {
+			Router: passthroughRouter,
+			Invoker: func(context.Context, *httpx.Request) (httpx.Response, merry.Error) {
+				return nil, merry.New("i blewed up!")
+			},
+		},
+		"invoker panic": {
+			Router: passthroughRouter,
+			Invoker: func(context.Context, *httpx.Request) (httpx.Response, merry.Error) {
+				panic("i blewed up!")
+			},
+		},
+		"nil invoker result": {
+			Router: passthroughRouter,
+			Invoker: func(context.Context, *httpx.Request) (httpx.Response, merry.Error) {
+				return nil, nil
+			},
+		},
+		"uncontactable": {
+			Router: func(c context.Context, r *httpx.Request) (*httpx.Request, merry.Error) {
+				r.URL.Scheme = "http"
+				r.URL.Host = "127.0.0.1:0"
+				return r, nil
+			},
+		},
+	} {
+		errs := make(chan merry.Error, 1)
+		mirror.Percentage = 100
+		mirror.ErrorHook = func(c context.Context, r *httpx.Request, err merry.Error) {
+			errs <- err
+		}
+		cut := &ReverseProxy{
+			Router:  passthroughRouter,
+			Invoker: textInvoker(http.StatusOK, "hello", nil),
+			Mirror:  mirror,
+		}
+
+		serveProxy(t, cut, "")
+
+		select {
+		case err := <-errs:
+			assert.Contains(t, err.Error(), "proxy middleware: mirror", name)
+		case <-time.After(time.Second):
+			t.Fatalf("%s: error hook not invoked", name)
+		}
+
+		waitForMirror(t, mirror)
+		assert.Equal(t, mirrorStats{Mirrored: 1, Failed: 1}, readMirrorStats(t, mirror), name)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	cut := &limitedBuffer{limit: 4}
+
+	n, err := cut.Write([]byte("he "))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	n, err = cut.Write([]byte("comes"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	assert.True(t, bytes.Equal([]byte("he c"), cut.Bytes()))
+}
+
+// waitForMirror waits for all mirrored requests to finish
+func waitForMirror(t *testing.T, m *Mirror) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if len(m.slots) == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("mirrored requests did not finish")
+}
@@ -13,6 +13,11 @@ import (
 	"github.com/shisa-platform/core/context"
 	"github.com/shisa-platform/core/errorx"
 	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/tracecontext"
+)
+
+const (
+	defaultRequestIDHeaderName = "X-Request-ID"
 )
 
 var (
@@ -86,6 +91,24 @@ type ReverseProxy struct {
 	// default handler will return the recommended status code
 	// and an empty body.
 	ErrorHandler httpx.ErrorHandler
+
+	// Mirror can be set to optionally send a copy of a
+	// percentage of requests to a shadow server.
+	Mirror *Mirror
+
+	// RequestIDHeaderName optionally customizes the name of the
+	// header used to send the request id of the context to the
+	// proxied server.  The header is only set if the request
+	// doesn't already have one, unless `ReplaceRequestID` is
+	// set.
+	// If empty "X-Request-Id" will be used.
+	RequestIDHeaderName string
+
+	// ReplaceRequestID replaces any request id sent by the user
+	// agent with the one of the context.  Set it when the proxy
+	// is behind a gateway so upstreams receive the id the
+	// gateway validated or generated.
+	ReplaceRequestID bool
 }
 
 func (m *ReverseProxy) Service(ctx context.Context, r *httpx.Request) httpx.Response {
@@ -97,7 +120,13 @@ func (m *ReverseProxy) Service(ctx context.Context, r *httpx.Request) httpx.Resp
 		ext.Component.Set(span, "middleware")
 	}
 
-	request := &httpx.Request{Request: r.WithContext(subCtx)}
+	request := &httpx.Request{
+		Request: r.WithContext(subCtx),
+		Variant: r.Variant,
+		Service: r.Service,
+		Route:   r.Route,
+		Policy:  r.Policy,
+	}
 
 	request.Header = cloneHeaders(r.Header)
 	request.QueryParams = cloneQueryParams(r.QueryParams)
@@ -107,11 +136,28 @@ func (m *ReverseProxy) Service(ctx context.Context, r *httpx.Request) httpx.Resp
 		request.Body = nil
 	}
 
-	request, response := m.route(subCtx, request)
+	if requestID := ctx.RequestID(); requestID != "" {
+		header := m.RequestIDHeaderName
+		if header == "" {
+			header = defaultRequestIDHeaderName
+		}
+		if m.ReplaceRequestID || request.Header.Get(header) == "" {
+			request.Header.Set(header, requestID)
+		}
+	}
+
+	var shadow *shadowRequest
+	if m.Mirror != nil {
+		shadow = m.Mirror.start(subCtx, request)
+	}
+
+	routed, response := m.route(subCtx, request)
+	r.Variant = request.Variant
 	if response != nil {
-		return response
+		return shadow.capture(response)
 	}
 
+	request = routed
 	request.Close = false
 
 	// Remove hop-by-hop headers listed in the "Connection"
@@ -160,7 +206,7 @@ func (m *ReverseProxy) Service(ctx context.Context, r *httpx.Request) httpx.Resp
 		delete(response.Headers(), h)
 	}
 
-	return m.respond(subCtx, request, response)
+	return shadow.capture(m.respond(subCtx, request, response))
 }
 
 func (m *ReverseProxy) route(ctx context.Context, request *httpx.Request) (*httpx.Request, httpx.Response) {
@@ -201,8 +247,7 @@ func (m *ReverseProxy) invoke(ctx context.Context, request *httpx.Request) httpx
 		defer span.Finish()
 	}
 
-	carrier := opentracing.HTTPHeadersCarrier(request.Header)
-	if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, carrier); err != nil {
+	if err := injectTracingHeaders(subCtx, span, request.Header); err != nil {
 		err1 := merry.Prepend(err, "proxy middleware: inject open tracing headers")
 		err1 = err1.WithHTTPCode(http.StatusBadGateway)
 		return m.handleError(subCtx, request, err1)
@@ -316,3 +361,33 @@ func cloneHeaders(headers http.Header) http.Header {
 
 	return h2
 }
+
+// injectTracingHeaders sets the headers of the tracer for the
+// span and the W3C trace context headers.  If the tracer doesn't
+// set "traceparent" itself the headers are set from a child of
+// the trace context of the context, if any, and the span is
+// tagged with the ids sent so the upstream's parent id is that
+// of a recorded span.  Inbound trace context headers are never
+// passed along as is.
+func injectTracingHeaders(ctx context.Context, span opentracing.Span, header http.Header) error {
+	header.Del(tracecontext.TraceParentHeader)
+	header.Del(tracecontext.TraceStateHeader)
+
+	carrier := opentracing.HTTPHeadersCarrier(header)
+	if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, carrier); err != nil {
+		return err
+	}
+
+	if header.Get(tracecontext.TraceParentHeader) != "" {
+		return nil
+	}
+	if tc, ok := tracecontext.FromContext(ctx); ok {
+		child := tc.Child()
+		span.SetTag("w3c.trace_id", child.TraceID.String())
+		span.SetTag("w3c.span_id", child.ParentID.String())
+		span.SetTag("w3c.parent_id", tc.ParentID.String())
+		child.Inject(header)
+	}
+
+	return nil
+}
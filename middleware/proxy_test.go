@@ -18,6 +18,7 @@ import (
 
 	"github.com/shisa-platform/core/context"
 	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/tracecontext"
 )
 
 func TestReverseProxyMissingRouter(t *testing.T) {
@@ -448,6 +449,228 @@ func TestReverseProxySanitizeRequestHeaders(t *testing.T) {
 	assert.True(t, invokerInvoked)
 }
 
+func TestReverseProxyForwardsRequestID(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Equal(t, "zalgo-he-comes", r.Header.Get("X-Request-Id"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	ctx := context.New(stdctx.Background()).WithRequestID("zalgo-he-comes")
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+}
+
+func TestReverseProxyKeepsCallerRequestID(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Equal(t, "caller", r.Header.Get("X-Request-Id"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	request.Header.Set("X-Request-Id", "caller")
+	ctx := context.New(stdctx.Background()).WithRequestID("zalgo-he-comes")
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+}
+
+func TestReverseProxyReplacesRequestID(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Equal(t, "zalgo-he-comes", r.Header.Get("X-Request-Id"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+		ReplaceRequestID: true,
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	request.Header.Set("X-Request-Id", "spoofed")
+	ctx := context.New(stdctx.Background()).WithRequestID("zalgo-he-comes")
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+	assert.Equal(t, "spoofed", request.Header.Get("X-Request-Id"))
+}
+
+func TestReverseProxyForwardsRequestIDCustomHeader(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Equal(t, "zalgo-he-comes", r.Header.Get("X-Zalgo"))
+			assert.Empty(t, r.Header.Get("X-Request-Id"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+		RequestIDHeaderName: "X-Zalgo",
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	ctx := context.New(stdctx.Background()).WithRequestID("zalgo-he-comes")
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+}
+
+func TestReverseProxyPreservesMatchedRoute(t *testing.T) {
+	policy := &httpx.Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
+	var routerInvoked bool
+	cut := ReverseProxy{
+		Router: func(c context.Context, r *httpx.Request) (*httpx.Request, merry.Error) {
+			routerInvoked = true
+			assert.Equal(t, "users", r.Service)
+			assert.Equal(t, "/users/:id", r.Route)
+			assert.Equal(t, policy, r.Policy)
+			return r, nil
+		},
+		Invoker: textInvoker(http.StatusOK, "hello", nil),
+	}
+	r := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	request := &httpx.Request{Request: r, Service: "users", Route: "/users/:id", Policy: policy}
+	ctx := context.New(stdctx.Background())
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, routerInvoked)
+}
+
+func TestReverseProxyTraceContext(t *testing.T) {
+	tc := tracecontext.New(true)
+	tc.State = "congo=t61rcWkgMzE"
+
+	var (
+		invokerInvoked bool
+		parentID       string
+	)
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			actual, err := tracecontext.Extract(r.Header)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.TraceID, actual.TraceID)
+			assert.NotEqual(t, tc.ParentID, actual.ParentID)
+			assert.True(t, actual.Sampled())
+			assert.Equal(t, tc.State, actual.State)
+			parentID = actual.ParentID.String()
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	request.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	ctx := context.New(stdctx.Background())
+	ctx = tracecontext.WithTraceContext(ctx, tc)
+
+	tracer := mocktracer.New()
+	ctx = ctx.WithSpan(tracer.StartSpan("test"))
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+
+	var invoke *mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.OperationName == "ReverseHTTPProxy.Invoke" {
+			invoke = span
+		}
+	}
+	if assert.NotNil(t, invoke) {
+		assert.Equal(t, parentID, invoke.Tag("w3c.span_id"))
+		assert.Equal(t, tc.ParentID.String(), invoke.Tag("w3c.parent_id"))
+		assert.Equal(t, tc.TraceID.String(), invoke.Tag("w3c.trace_id"))
+	}
+}
+
+func TestReverseProxyTraceContextMissing(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Empty(t, r.Header.Get("traceparent"))
+			assert.Empty(t, r.Header.Get("tracestate"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	request.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	ctx := context.New(stdctx.Background())
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+}
+
+type traceParentPropigator struct{}
+
+func (traceParentPropigator) Inject(_ mocktracer.MockSpanContext, carrier interface{}) error {
+	carrier.(opentracing.HTTPHeadersCarrier).Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	return nil
+}
+
+func TestReverseProxyTraceContextFromTracer(t *testing.T) {
+	var invokerInvoked bool
+	cut := ReverseProxy{
+		Router: passthroughRouter,
+		Invoker: func(c context.Context, r *httpx.Request) (httpx.Response, merry.Error) {
+			invokerInvoked = true
+			assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", r.Header.Get("traceparent"))
+			return httpx.NewEmpty(http.StatusOK), nil
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	request := &httpx.Request{Request: r}
+	ctx := context.New(stdctx.Background())
+	ctx = tracecontext.WithTraceContext(ctx, tracecontext.New(true))
+	tracer := mocktracer.New()
+	tracer.RegisterInjector(opentracing.HTTPHeaders, traceParentPropigator{})
+	ctx = ctx.WithSpan(tracer.StartSpan("test"))
+
+	response := cut.Service(ctx, request)
+
+	assert.NotNil(t, response)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.True(t, invokerInvoked)
+}
+
 func TestReverseProxySanitizeResponseHeaders(t *testing.T) {
 	var routerInvoked bool
 	var invokerInvoked bool
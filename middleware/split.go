@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/errorx"
+	"github.com/shisa-platform/core/httpx"
+)
+
+// StickyKey returns the attribute of a request used to assign
+// it to a variant.  Requests with the same key are assigned to
+// the same variant as long as the weights don't change.  An
+// empty key results in a random assignment.
+type StickyKey func(context.Context, *httpx.Request) string
+
+func (k StickyKey) InvokeSafely(ctx context.Context, request *httpx.Request) (key string, exception merry.Error) {
+	defer errorx.CapturePanic(&exception, "panic in sticky key")
+
+	key = k(ctx, request)
+
+	return
+}
+
+// HeaderKey returns a StickyKey using the value of the given
+// request header.
+func HeaderKey(name string) StickyKey {
+	return func(ctx context.Context, request *httpx.Request) string {
+		return request.Header.Get(name)
+	}
+}
+
+// CookieKey returns a StickyKey using the value of the given
+// request cookie.
+func CookieKey(name string) StickyKey {
+	return func(ctx context.Context, request *httpx.Request) string {
+		cookie, err := request.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// ActorKey is a StickyKey using the ID of the actor of the
+// context, if any.
+func ActorKey(ctx context.Context, request *httpx.Request) string {
+	if actor := ctx.Actor(); actor != nil {
+		return actor.ID()
+	}
+
+	return ""
+}
+
+// Variant is a version of an upstream service that receives a
+// share of traffic from a `Splitter`.
+type Variant struct {
+	// Name identifies the variant and must be unique within a
+	// splitter.  It is recorded on the span of the request as
+	// the "variant" tag and in the `Variant` field of the
+	// request.
+	Name string
+
+	// Weight is the share of traffic sent to the variant
+	// relative to the others.  A weight of zero sends no
+	// traffic.  The weights of a splitter must not total more
+	// than `MaxTotalWeight`.
+	Weight int
+
+	// Router returns the request to contact the variant and
+	// must be non-nil.
+	Router Router
+}
+
+// MaxTotalWeight is the largest allowed sum of the weights of
+// the variants of a splitter.
+const MaxTotalWeight = math.MaxInt32
+
+// Splitter distributes requests among variants of an upstream
+// service in proportion to their weights, e.g. to send a small
+// percentage of traffic to a canary release.  Use its `Route`
+// method as the `Router` of a `ReverseProxy`.  Weights may be
+// changed while serving.
+type Splitter struct {
+	// Key optionally assigns requests to variants consistently.
+	// If this is not set requests are assigned randomly.
+	Key StickyKey
+
+	mtx      sync.RWMutex
+	variants []Variant
+	total    int
+}
+
+// NewSplitter returns a splitter for the given variants.  At
+// least one variant must have a non-zero weight.
+func NewSplitter(key StickyKey, variants ...Variant) (*Splitter, merry.Error) {
+	if len(variants) == 0 {
+		return nil, merry.New("splitter: check invariants: variants empty")
+	}
+
+	names := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		if variant.Name == "" {
+			return nil, merry.New("splitter: check invariants: variant name empty")
+		}
+		if names[variant.Name] {
+			return nil, merry.New("splitter: check invariants: duplicate variant name").Append(variant.Name)
+		}
+		names[variant.Name] = true
+		if variant.Router == nil {
+			return nil, merry.New("splitter: check invariants: variant router is nil").Append(variant.Name)
+		}
+	}
+
+	s := &Splitter{
+		Key:      key,
+		variants: append([]Variant(nil), variants...),
+	}
+	if err := s.SetWeights(nil); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Weights returns the current weight of each variant.
+func (s *Splitter) Weights() map[string]int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	weights := make(map[string]int, len(s.variants))
+	for _, variant := range s.variants {
+		weights[variant.Name] = variant.Weight
+	}
+
+	return weights
+}
+
+// SetWeights changes the weights of the named variants.
+// Variants that aren't named keep their current weight.  An
+// error is returned, and no weights are changed, if a name is
+// unknown, a weight is negative or all weights would be zero.
+func (s *Splitter) SetWeights(weights map[string]int) merry.Error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	variants := append([]Variant(nil), s.variants...)
+	for name, weight := range weights {
+		if weight < 0 {
+			return merry.New("splitter: set weights: weight is negative").Append(name)
+		}
+
+		found := false
+		for i := range variants {
+			if variants[i].Name == name {
+				variants[i].Weight = weight
+				found = true
+				break
+			}
+		}
+		if !found {
+			return merry.New("splitter: set weights: unknown variant").Append(name)
+		}
+	}
+
+	var total int
+	for _, variant := range variants {
+		if variant.Weight < 0 {
+			return merry.New("splitter: set weights: weight is negative").Append(variant.Name)
+		}
+		if variant.Weight > MaxTotalWeight-total {
+			return merry.New("splitter: set weights: total weight too large").Append(variant.Name)
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		return merry.New("splitter: set weights: total weight is zero")
+	}
+
+	s.variants = variants
+	s.total = total
+
+	return nil
+}
+
+// Route assigns the request to a variant, records the choice
+// and returns the result of the router of the variant.
+func (s *Splitter) Route(ctx context.Context, request *httpx.Request) (*httpx.Request, merry.Error) {
+	var point uint64
+	key := ""
+	if s.Key != nil {
+		var exception merry.Error
+		key, exception = s.Key.InvokeSafely(ctx, request)
+		if exception != nil {
+			return nil, exception.Prepend("splitter: route: run Key")
+		}
+	}
+	if key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		point = uint64(hash.Sum32())
+	} else {
+		point = uint64(rand.Uint32())
+	}
+
+	variant := s.choose(point)
+
+	request.Variant = variant.Name
+	if span := ctx.Span(); span != nil {
+		span.SetTag("variant", variant.Name)
+	}
+
+	out, err, exception := variant.Router.InvokeSafely(ctx, request)
+	if exception != nil {
+		return nil, exception.Prepend("splitter: route: run Router").Append(variant.Name)
+	} else if err != nil {
+		return nil, err.Prepend("splitter: route: run Router").Append(variant.Name)
+	} else if out == nil {
+		return nil, merry.New("splitter: route: run Router: result is nil").Append(variant.Name)
+	}
+
+	return out, nil
+}
+
+// choose maps a point in [0, 2^32) onto the cumulative weights
+// of the variants so a key keeps its variant when weights
+// change unless its point falls in a range that moved.  The
+// product can't overflow as the total is capped at
+// `MaxTotalWeight`.
+func (s *Splitter) choose(point uint64) Variant {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	target := int((point * uint64(s.total)) >> 32)
+	for _, variant := range s.variants {
+		if target < variant.Weight {
+			return variant
+		}
+		target -= variant.Weight
+	}
+
+	return s.variants[len(s.variants)-1]
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	stdctx "context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ansel1/merry"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/models"
+)
+
+func hostRouter(host string) Router {
+	return func(c context.Context, r *httpx.Request) (*httpx.Request, merry.Error) {
+		r.URL.Host = host
+		return r, nil
+	}
+}
+
+func newTestSplitter(t *testing.T, key StickyKey, stable, canary int) *Splitter {
+	cut, err := NewSplitter(key,
+		Variant{Name: "stable", Weight: stable, Router: hostRouter("stable")},
+		Variant{Name: "canary", Weight: canary, Router: hostRouter("canary")},
+	)
+	assert.NoError(t, err)
+	assert.NotNil(t, cut)
+
+	return cut
+}
+
+func TestNewSplitterErrors(t *testing.T) {
+	router := hostRouter("stable")
+	for name, variants := range map[string][]Variant{
+		"empty":          nil,
+		"missing name":   {{Weight: 1, Router: router}},
+		"duplicate name": {{Name: "a", Weight: 1, Router: router}, {Name: "a", Weight: 1, Router: router}},
+		"missing router": {{Name: "a", Weight: 1}},
+		"negative":       {{Name: "a", Weight: -1, Router: router}, {Name: "b", Weight: 2, Router: router}},
+		"zero total":     {{Name: "a", Router: router}},
+		"too large":      {{Name: "a", Weight: MaxTotalWeight, Router: router}, {Name: "b", Weight: 1, Router: router}},
+	} {
+		cut, err := NewSplitter(nil, variants...)
+		assert.Error(t, err, name)
+		assert.Nil(t, cut, name)
+	}
+}
+
+func TestSplitterSetWeights(t *testing.T) {
+	cut := newTestSplitter(t, nil, 99, 1)
+	assert.Equal(t, map[string]int{"stable": 99, "canary": 1}, cut.Weights())
+
+	assert.NoError(t, cut.SetWeights(map[string]int{"canary": 10}))
+	assert.Equal(t, map[string]int{"stable": 99, "canary": 10}, cut.Weights())
+
+	assert.Error(t, cut.SetWeights(map[string]int{"lolwut": 10}))
+	assert.Error(t, cut.SetWeights(map[string]int{"canary": -1}))
+	assert.Error(t, cut.SetWeights(map[string]int{"canary": 0, "stable": 0}))
+	assert.Error(t, cut.SetWeights(map[string]int{"canary": MaxTotalWeight}))
+	assert.Equal(t, map[string]int{"stable": 99, "canary": 10}, cut.Weights())
+}
+
+func TestSplitterChooseMaxTotalWeight(t *testing.T) {
+	cut := newTestSplitter(t, nil, MaxTotalWeight-1, 1)
+
+	assert.Equal(t, "stable", cut.choose(0).Name)
+	assert.Equal(t, "canary", cut.choose(math.MaxUint32).Name)
+}
+
+func TestSplitterRoute(t *testing.T) {
+	cut := newTestSplitter(t, nil, 1, 0)
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	ctx := context.New(stdctx.Background()).WithSpan(span)
+	request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+
+	out, err := cut.Route(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "stable", out.URL.Host)
+	assert.Equal(t, "stable", request.Variant)
+	assert.Equal(t, "stable", span.(*mocktracer.MockSpan).Tag("variant"))
+
+	assert.NoError(t, cut.SetWeights(map[string]int{"stable": 0, "canary": 1}))
+
+	out, err = cut.Route(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "canary", out.URL.Host)
+	assert.Equal(t, "canary", request.Variant)
+}
+
+func TestSplitterRouteSticky(t *testing.T) {
+	cut := newTestSplitter(t, HeaderKey("X-User"), 50, 50)
+	ctx := context.New(stdctx.Background())
+
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		var first string
+		for j := 0; j < 3; j++ {
+			request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+			request.Header.Set("X-User", user)
+
+			_, err := cut.Route(ctx, request)
+			assert.NoError(t, err)
+			if j == 0 {
+				first = request.Variant
+				counts[first]++
+			}
+			assert.Equal(t, first, request.Variant, user)
+		}
+	}
+
+	assert.True(t, counts["stable"] > 0)
+	assert.True(t, counts["canary"] > 0)
+}
+
+func TestSplitterRouteStickyWeightChange(t *testing.T) {
+	cut := newTestSplitter(t, HeaderKey("X-User"), 90, 10)
+	ctx := context.New(stdctx.Background())
+
+	assign := func() map[string]string {
+		result := make(map[string]string)
+		for i := 0; i < 100; i++ {
+			user := fmt.Sprintf("user-%d", i)
+			request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+			request.Header.Set("X-User", user)
+			_, err := cut.Route(ctx, request)
+			assert.NoError(t, err)
+			result[user] = request.Variant
+		}
+		return result
+	}
+
+	before := assign()
+	assert.NoError(t, cut.SetWeights(map[string]int{"stable": 80, "canary": 20}))
+	after := assign()
+
+	// users only move towards the variant whose share grew
+	for user, variant := range before {
+		if variant == "stable" && after[user] == "canary" {
+			continue
+		}
+		assert.Equal(t, variant, after[user], user)
+	}
+}
+
+func TestSplitterKeys(t *testing.T) {
+	request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	request.Header.Set("X-User", "zalgo")
+	request.AddCookie(&http.Cookie{Name: "user", Value: "he comes"})
+	ctx := context.New(stdctx.Background())
+
+	assert.Equal(t, "zalgo", HeaderKey("X-User")(ctx, request))
+	assert.Equal(t, "he comes", CookieKey("user")(ctx, request))
+	assert.Equal(t, "", CookieKey("lolwut")(ctx, request))
+	assert.Equal(t, "", ActorKey(ctx, request))
+
+	user := &models.FakeUser{IDHook: func() string { return "123" }}
+	ctx = ctx.WithActor(user)
+	assert.Equal(t, "123", ActorKey(ctx, request))
+}
+
+func TestSplitterRouteErrors(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+
+	for name, router := range map[string]Router{
+		"error": func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+			return nil, merry.New("i blewed up!")
+		},
+		"panic": func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+			panic("i blewed up!")
+		},
+		"nil result": func(context.Context, *httpx.Request) (*httpx.Request, merry.Error) {
+			return nil, nil
+		},
+	} {
+		cut, err := NewSplitter(nil, Variant{Name: "stable", Weight: 1, Router: router})
+		assert.NoError(t, err)
+
+		request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+		out, err := cut.Route(ctx, request)
+		assert.Error(t, err, name)
+		assert.Nil(t, out, name)
+	}
+
+	key := func(context.Context, *httpx.Request) string {
+		panic("i blewed up!")
+	}
+	cut := newTestSplitter(t, key, 1, 1)
+	request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	out, err := cut.Route(ctx, request)
+	assert.Error(t, err)
+	assert.Nil(t, out)
+}
+
+func TestReverseProxySplitterVariant(t *testing.T) {
+	cut := newTestSplitter(t, nil, 0, 1)
+	proxy := ReverseProxy{
+		Router:  cut.Route,
+		Invoker: textInvoker(http.StatusOK, "hello", nil),
+	}
+
+	request := &httpx.Request{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	ctx := context.New(stdctx.Background())
+
+	response := proxy.Service(ctx, request)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.Equal(t, "canary", request.Variant)
+}
@@ -0,0 +1,276 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+// OperationKey identifies an operation by HTTP method and the
+// route template of its endpoint, e.g. `{"GET", "/users/:id"}`.
+type OperationKey struct {
+	Method string
+	Route  string
+}
+
+// Annotation optionally adds the parts of an operation that
+// can't be derived from a service endpoint.
+type Annotation struct {
+	Summary     string
+	Description string
+	OperationID string
+	Deprecated  bool
+
+	// Tags replace the default tag of the service name.
+	Tags []string
+
+	// ParameterDescriptions are descriptions of path and query
+	// parameters keyed by name.
+	ParameterDescriptions map[string]string
+
+	// RequestBody describes the payload of the request.
+	RequestBody *RequestBody
+
+	// Responses are keyed by status code, e.g. "200", or
+	// "default".
+	// If empty a default response with no content is used.
+	Responses map[string]*Response
+}
+
+// Generator builds OpenAPI documents from services.  Paths and
+// parameters are derived from the endpoint route templates and
+// typed or constrained path parameters are converted to
+// schemas.  Query parameters are derived from the named
+// `QuerySchemas` of each pipeline.
+type Generator struct {
+	Info       Info        // API metadata.  Title and Version are required.
+	Servers    []Server    // optional locations serving the API
+	Components *Components // optional schemas referenced by annotations
+
+	// Annotations optionally describe operations.  An
+	// annotation for an operation not present in the services
+	// is an error so that documents don't drift from the
+	// routes the gateway serves.
+	Annotations map[OperationKey]Annotation
+}
+
+// Generate returns an OpenAPI document describing the given
+// services.  Routes claimed by more than one service, e.g. with
+// different `Hosts`, are described by the first service.
+func (g Generator) Generate(services ...*service.Service) (*Document, merry.Error) {
+	if g.Info.Title == "" {
+		return nil, merry.New("openapi: check invariants: info title empty")
+	}
+	if g.Info.Version == "" {
+		return nil, merry.New("openapi: check invariants: info version empty")
+	}
+
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       g.Info,
+		Servers:    g.Servers,
+		Paths:      make(map[string]PathItem),
+		Components: g.Components,
+	}
+
+	annotated := make(map[OperationKey]bool, len(g.Annotations))
+	for _, svc := range services {
+		if svc.Name == "" {
+			return nil, merry.New("openapi: check invariants: service name empty")
+		}
+		doc.Tags = append(doc.Tags, Tag{Name: svc.Name})
+
+		for _, endp := range endpoints(svc) {
+			path, params, err := convertRoute(endp.Route)
+			if err != nil {
+				return nil, err.Prepend("openapi: generate").Append(svc.Name).Append(endp.Route)
+			}
+
+			item, ok := doc.Paths[path]
+			if !ok {
+				item = make(PathItem)
+				doc.Paths[path] = item
+			}
+
+			for _, p := range pipelines(endp.Endpoint, endp.policy) {
+				method := strings.ToLower(p.method)
+				if _, ok := item[method]; ok {
+					continue
+				}
+
+				key := OperationKey{Method: p.method, Route: endp.Route}
+				annotation, ok := g.Annotations[key]
+				if !ok && p.implicit {
+					annotation, ok = g.Annotations[OperationKey{Method: http.MethodGet, Route: endp.Route}]
+				}
+				if ok {
+					annotated[key] = true
+				}
+
+				item[method] = newOperation(svc.Name, params, p.pipeline, annotation, p.implicit)
+			}
+		}
+	}
+
+	for key := range g.Annotations {
+		if !annotated[key] {
+			return nil, merry.New("openapi: check invariants: annotation for unknown operation").Append(key.Method).Append(key.Route)
+		}
+	}
+
+	return doc, nil
+}
+
+// Service is a handler that responds with the document as JSON
+// so that it can be served by a gateway endpoint, e.g.
+// `service.GetEndpoint("/openapi.json", doc.Service)`.
+func (d *Document) Service(ctx context.Context, request *httpx.Request) httpx.Response {
+	return httpx.NewOK(d)
+}
+
+type scopedEndpoint struct {
+	service.Endpoint
+	policy service.Policy
+}
+
+// endpoints returns the endpoints of a service, including those
+// of its groups, with their full routes and the default policy
+// of their pipelines.
+func endpoints(svc *service.Service) []scopedEndpoint {
+	result := make([]scopedEndpoint, 0, len(svc.Endpoints))
+	for _, endp := range svc.Endpoints {
+		endp.Route = svc.BasePath + endp.Route
+		result = append(result, scopedEndpoint{Endpoint: endp, policy: svc.Policy})
+	}
+
+	return appendGroups(result, svc.BasePath, svc.Policy, svc.Groups)
+}
+
+func appendGroups(result []scopedEndpoint, prefix string, policy service.Policy, groups []service.Group) []scopedEndpoint {
+	for _, group := range groups {
+		groupPrefix := prefix + group.Prefix
+		groupPolicy := policy.Merge(group.Policy)
+
+		for _, endp := range group.Endpoints {
+			endp.Route = groupPrefix + endp.Route
+			result = append(result, scopedEndpoint{Endpoint: endp, policy: groupPolicy})
+		}
+
+		result = appendGroups(result, groupPrefix, groupPolicy, group.Groups)
+	}
+
+	return result
+}
+
+type methodPipeline struct {
+	method   string
+	pipeline *service.Pipeline
+	implicit bool
+}
+
+// pipelines returns the pipelines of the endpoint that can be
+// described by OpenAPI, which doesn't support CONNECT.
+func pipelines(endp service.Endpoint, policy service.Policy) []methodPipeline {
+	all := []methodPipeline{
+		{method: http.MethodGet, pipeline: endp.Get},
+		{method: http.MethodHead, pipeline: endp.Head},
+		{method: http.MethodPut, pipeline: endp.Put},
+		{method: http.MethodPost, pipeline: endp.Post},
+		{method: http.MethodPatch, pipeline: endp.Patch},
+		{method: http.MethodDelete, pipeline: endp.Delete},
+		{method: http.MethodOptions, pipeline: endp.Options},
+		{method: http.MethodTrace, pipeline: endp.Trace},
+	}
+	if endp.Get != nil {
+		policy = policy.Merge(endp.Get.Policy)
+	}
+	if endp.Head == nil && endp.Get != nil && httpx.IsTrue(policy.AllowImplicitHead) {
+		all[1] = methodPipeline{method: http.MethodHead, pipeline: endp.Get, implicit: true}
+	}
+
+	result := all[:0]
+	for _, p := range all {
+		if p.pipeline != nil {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+func newOperation(tag string, params []Parameter, pipeline *service.Pipeline, annotation Annotation, implicitHead bool) *Operation {
+	op := &Operation{
+		Tags:        annotation.Tags,
+		Summary:     annotation.Summary,
+		Description: annotation.Description,
+		OperationID: annotation.OperationID,
+		Deprecated:  annotation.Deprecated,
+		Responses:   make(map[string]*Response),
+	}
+	if len(op.Tags) == 0 {
+		op.Tags = []string{tag}
+	}
+	if implicitHead {
+		// operation ids must be unique
+		op.OperationID = ""
+	}
+
+	op.Parameters = append(op.Parameters, params...)
+	for _, schema := range pipeline.QuerySchemas {
+		if schema.Name == "" {
+			continue
+		}
+		op.Parameters = append(op.Parameters, queryParameter(schema))
+	}
+	for i, param := range op.Parameters {
+		if description, ok := annotation.ParameterDescriptions[param.Name]; ok {
+			op.Parameters[i].Description = description
+		}
+	}
+
+	if !implicitHead {
+		op.RequestBody = annotation.RequestBody
+	}
+
+	for code, response := range annotation.Responses {
+		if implicitHead {
+			response = &Response{Description: response.Description}
+		}
+		op.Responses[code] = response
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &Response{Description: "response"}
+	}
+
+	return op
+}
+
+func queryParameter(schema httpx.ParameterSchema) Parameter {
+	param := Parameter{
+		Name:     schema.Name,
+		In:       "query",
+		Required: schema.Required,
+		Schema:   &Schema{Type: "string"},
+	}
+	if schema.Default != "" {
+		param.Schema.Default = schema.Default
+	}
+
+	if schema.Multiplicity != 1 {
+		param.Schema = &Schema{
+			Type:  "array",
+			Items: param.Schema,
+		}
+		if schema.Multiplicity != 0 {
+			max := schema.Multiplicity
+			param.Schema.MaxItems = &max
+		}
+	}
+
+	return param
+}
@@ -0,0 +1,248 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+	"github.com/shisa-platform/core/httpx"
+	"github.com/shisa-platform/core/service"
+)
+
+var (
+	info = Info{Title: "Test API", Version: "1.0"}
+)
+
+func dummyHandler(context.Context, *httpx.Request) httpx.Response {
+	return httpx.NewEmpty(http.StatusOK)
+}
+
+func newService() *service.Service {
+	get := service.GetEndpointWithPolicy("/users/:id{uint}", service.Policy{AllowImplicitHead: httpx.Bool(true)}, dummyHandler)
+	get.Get.QuerySchemas = []httpx.ParameterSchema{
+		{Name: "fields", Default: "all", Multiplicity: 1},
+		{Name: "tag", Multiplicity: 3, Required: true},
+	}
+	get.Delete = &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+	get.Connect = &service.Pipeline{Handlers: []httpx.Handler{dummyHandler}}
+
+	return &service.Service{
+		Name: "users",
+		Endpoints: []service.Endpoint{
+			get,
+			service.PostEndpoint("/users", dummyHandler),
+		},
+	}
+}
+
+func TestGeneratorMissingInfo(t *testing.T) {
+	cut := Generator{Info: Info{Version: "1.0"}}
+	doc, err := cut.Generate(newService())
+	assert.Error(t, err)
+	assert.Nil(t, doc)
+
+	cut = Generator{Info: Info{Title: "Test API"}}
+	doc, err = cut.Generate(newService())
+	assert.Error(t, err)
+	assert.Nil(t, doc)
+}
+
+func TestGeneratorMissingServiceName(t *testing.T) {
+	svc := newService()
+	svc.Name = ""
+
+	cut := Generator{Info: info}
+	doc, err := cut.Generate(svc)
+	assert.Error(t, err)
+	assert.Nil(t, doc)
+}
+
+func TestGeneratorBadRoute(t *testing.T) {
+	svc := newService()
+	svc.Endpoints[1].Route = "/users/:id{uint"
+
+	cut := Generator{Info: info}
+	doc, err := cut.Generate(svc)
+	assert.Error(t, err)
+	assert.Nil(t, doc)
+}
+
+func TestGeneratorUnknownAnnotation(t *testing.T) {
+	cut := Generator{
+		Info: info,
+		Annotations: map[OperationKey]Annotation{
+			{Method: http.MethodPut, Route: "/users"}: {Summary: "update users"},
+		},
+	}
+	doc, err := cut.Generate(newService())
+	assert.Error(t, err)
+	assert.Nil(t, doc)
+}
+
+func TestGenerator(t *testing.T) {
+	userSchema := &Schema{Ref: "#/components/schemas/User"}
+	cut := Generator{
+		Info:    info,
+		Servers: []Server{{URL: "https://api.example.com"}},
+		Components: &Components{
+			Schemas: map[string]*Schema{
+				"User": {Type: "object", Properties: map[string]*Schema{"id": {Type: "integer"}}},
+			},
+		},
+		Annotations: map[OperationKey]Annotation{
+			{Method: http.MethodGet, Route: "/users/:id{uint}"}: {
+				Summary:               "Fetch a user",
+				OperationID:           "getUser",
+				ParameterDescriptions: map[string]string{"id": "the user id"},
+				Responses: map[string]*Response{
+					"200": {
+						Description: "the user",
+						Content:     map[string]MediaType{"application/json": {Schema: userSchema}},
+					},
+				},
+			},
+			{Method: http.MethodPost, Route: "/users"}: {
+				Tags: []string{"admin"},
+				RequestBody: &RequestBody{
+					Required: true,
+					Content:  map[string]MediaType{"application/json": {Schema: userSchema}},
+				},
+			},
+		},
+	}
+
+	doc, err := cut.Generate(newService())
+	assert.NoError(t, err)
+	assert.NotNil(t, doc)
+
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, info, doc.Info)
+	assert.Equal(t, []Tag{{Name: "users"}}, doc.Tags)
+	assert.Len(t, doc.Paths, 2)
+
+	item := doc.Paths["/users/{id}"]
+	assert.Len(t, item, 3)
+	assert.NotContains(t, item, "connect")
+
+	get := item["get"]
+	assert.Equal(t, "Fetch a user", get.Summary)
+	assert.Equal(t, "getUser", get.OperationID)
+	assert.Equal(t, []string{"users"}, get.Tags)
+	assert.Len(t, get.Parameters, 3)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "path", get.Parameters[0].In)
+	assert.Equal(t, "the user id", get.Parameters[0].Description)
+	assert.Equal(t, "fields", get.Parameters[1].Name)
+	assert.Equal(t, "query", get.Parameters[1].In)
+	assert.Equal(t, &Schema{Type: "string", Default: "all"}, get.Parameters[1].Schema)
+	assert.Equal(t, "tag", get.Parameters[2].Name)
+	assert.True(t, get.Parameters[2].Required)
+	assert.Equal(t, "array", get.Parameters[2].Schema.Type)
+	assert.Equal(t, uint(3), *get.Parameters[2].Schema.MaxItems)
+	assert.Equal(t, userSchema, get.Responses["200"].Content["application/json"].Schema)
+
+	head := item["head"]
+	assert.Empty(t, head.OperationID)
+	assert.Equal(t, "the user", head.Responses["200"].Description)
+	assert.Empty(t, head.Responses["200"].Content)
+
+	del := item["delete"]
+	assert.Equal(t, "response", del.Responses["default"].Description)
+
+	post := doc.Paths["/users"]["post"]
+	assert.Equal(t, []string{"admin"}, post.Tags)
+	assert.True(t, post.RequestBody.Required)
+	assert.Empty(t, post.Parameters)
+}
+
+func TestGeneratorDuplicateRoutes(t *testing.T) {
+	svc1 := newService()
+	svc2 := newService()
+	svc2.Name = "admin"
+	svc2.Hosts = []string{"admin.example.com"}
+
+	cut := Generator{Info: info}
+	doc, err := cut.Generate(svc1, svc2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users"}, doc.Paths["/users"]["post"].Tags)
+}
+
+func TestGeneratorGroups(t *testing.T) {
+	svc := &service.Service{
+		Name:     "accounts",
+		BasePath: "/v2",
+		Groups: []service.Group{
+			{
+				Prefix:    "/accounts",
+				Policy:    service.Policy{AllowImplicitHead: httpx.Bool(true)},
+				Endpoints: []service.Endpoint{service.GetEndpoint("/:id", dummyHandler)},
+				Groups: []service.Group{
+					{
+						Prefix:    "/:id/users",
+						Endpoints: []service.Endpoint{service.PostEndpoint("/", dummyHandler)},
+					},
+				},
+			},
+		},
+	}
+
+	cut := Generator{
+		Info: info,
+		Annotations: map[OperationKey]Annotation{
+			{Method: http.MethodGet, Route: "/v2/accounts/:id"}: {Summary: "get account"},
+		},
+	}
+	doc, err := cut.Generate(svc)
+	assert.NoError(t, err)
+	assert.NotNil(t, doc)
+
+	assert.Len(t, doc.Paths, 2)
+	assert.Contains(t, doc.Paths["/v2/accounts/{id}"], "get")
+	assert.Contains(t, doc.Paths["/v2/accounts/{id}"], "head")
+	assert.Equal(t, "get account", doc.Paths["/v2/accounts/{id}"]["get"].Summary)
+	assert.Contains(t, doc.Paths["/v2/accounts/{id}/users/"], "post")
+}
+
+func TestGeneratorGroupPolicyMerged(t *testing.T) {
+	get := service.GetEndpointWithPolicy("/:id", service.Policy{TimeBudget: time.Second}, dummyHandler)
+	svc := &service.Service{
+		Name: "accounts",
+		Groups: []service.Group{
+			{
+				Prefix:    "/accounts",
+				Policy:    service.Policy{AllowImplicitHead: httpx.Bool(true)},
+				Endpoints: []service.Endpoint{get},
+			},
+		},
+	}
+
+	cut := Generator{Info: info}
+	doc, err := cut.Generate(svc)
+	assert.NoError(t, err)
+
+	assert.Contains(t, doc.Paths["/accounts/{id}"], "get")
+	assert.Contains(t, doc.Paths["/accounts/{id}"], "head")
+}
+
+func TestDocumentService(t *testing.T) {
+	cut := Generator{Info: info}
+	doc, err := cut.Generate(newService())
+	assert.NoError(t, err)
+
+	response := doc.Service(nil, nil)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.Equal(t, "application/json", response.Headers().Get("Content-Type"))
+
+	w := httptest.NewRecorder()
+	assert.NoError(t, response.Serialize(w))
+
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, Version, result["openapi"])
+	assert.Contains(t, result["paths"], "/users/{id}")
+}
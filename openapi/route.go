@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	"bytes"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/service"
+)
+
+// convertRoute returns the OpenAPI path template and path
+// parameters of a gateway route template, e.g.
+// "/users/:id{uint}" becomes "/users/{id}" with an integer
+// parameter.
+func convertRoute(route string) (string, []Parameter, merry.Error) {
+	var buf bytes.Buffer
+	var params []Parameter
+
+	for i := 0; i < len(route); i++ {
+		c := route[i]
+		if c != ':' && c != '*' {
+			buf.WriteByte(c)
+			continue
+		}
+
+		end := i + 1
+		for end < len(route) && route[end] != '/' && route[end] != '{' {
+			end++
+		}
+		name := route[i+1 : end]
+		if name == "" {
+			return "", nil, merry.New("wildcard name empty")
+		}
+
+		var expr string
+		if end < len(route) && route[end] == '{' {
+			brace := end
+			if end = service.ConstraintEnd(route, brace); end == -1 {
+				return "", nil, merry.New("unterminated wildcard constraint")
+			}
+			expr = route[brace+1 : end-1]
+		}
+
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   constraintSchema(expr),
+		})
+		buf.WriteString("{" + name + "}")
+		i = end - 1
+	}
+
+	return buf.String(), params, nil
+}
+
+func constraintSchema(expr string) *Schema {
+	if expr == "" {
+		return &Schema{Type: "string"}
+	}
+
+	if builtin, ok := service.BuiltinConstraints[expr]; ok {
+		schema := &Schema{
+			Type:    builtin.Type,
+			Format:  builtin.Format,
+			Pattern: builtin.Pattern,
+		}
+		if builtin.Minimum != nil {
+			min := *builtin.Minimum
+			schema.Minimum = &min
+		}
+		return schema
+	}
+
+	return &Schema{Type: "string", Pattern: "^(?:" + expr + ")$"}
+}
@@ -0,0 +1,49 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertRoute(t *testing.T) {
+	path, params, err := convertRoute("/static/path")
+	assert.NoError(t, err)
+	assert.Equal(t, "/static/path", path)
+	assert.Empty(t, params)
+
+	path, params, err = convertRoute("/users/:id{uint}/files/:name{[a-z]{3}}/*rest")
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/{id}/files/{name}/{rest}", path)
+	assert.Len(t, params, 3)
+	assert.Equal(t, "id", params[0].Name)
+	assert.Equal(t, "path", params[0].In)
+	assert.True(t, params[0].Required)
+	assert.Equal(t, "integer", params[0].Schema.Type)
+	assert.Equal(t, float64(0), *params[0].Schema.Minimum)
+	assert.Equal(t, "name", params[1].Name)
+	assert.Equal(t, "^(?:[a-z]{3})$", params[1].Schema.Pattern)
+	assert.Equal(t, "rest", params[2].Name)
+	assert.Equal(t, "string", params[2].Schema.Type)
+
+	path, params, err = convertRoute("/user_:name/about")
+	assert.NoError(t, err)
+	assert.Equal(t, "/user_{name}/about", path)
+	assert.Len(t, params, 1)
+}
+
+func TestConvertRouteBadWildcard(t *testing.T) {
+	_, _, err := convertRoute("/users/:/about")
+	assert.Error(t, err)
+
+	_, _, err = convertRoute("/users/:id{uint")
+	assert.Error(t, err)
+}
+
+func TestConstraintSchema(t *testing.T) {
+	assert.Equal(t, &Schema{Type: "string"}, constraintSchema(""))
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, constraintSchema("int"))
+	assert.Equal(t, &Schema{Type: "string", Format: "uuid"}, constraintSchema("uuid"))
+	assert.Equal(t, &Schema{Type: "string", Pattern: "^[A-Za-z]+$"}, constraintSchema("alpha"))
+	assert.Equal(t, &Schema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}, constraintSchema("alnum"))
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/shisa-platform/core/uuid"
+)
+
+// Constraint is a builtin constraint of the values of path
+// parameters, e.g. `:id{uint}`.  The schema fields describe the
+// values matched as a JSON schema.
+type Constraint struct {
+	Match   func(string) bool // reports if the value is allowed
+	Type    string            // the schema type, "integer" or "string"
+	Format  string            // the schema format, if any
+	Pattern string            // the schema pattern, if any
+	Minimum *float64          // the minimum value, if any
+}
+
+var (
+	zero = float64(0)
+
+	// BuiltinConstraints are the constraints that may be used
+	// by name in route templates.  Any other constraint is a
+	// regular expression that must match the entire value.
+	BuiltinConstraints = map[string]Constraint{
+		"int": {
+			Match: func(value string) bool {
+				_, err := strconv.ParseInt(value, 10, 64)
+				return err == nil
+			},
+			Type:   "integer",
+			Format: "int64",
+		},
+		"uint": {
+			Match: func(value string) bool {
+				_, err := strconv.ParseUint(value, 10, 64)
+				return err == nil
+			},
+			Type:    "integer",
+			Format:  "int64",
+			Minimum: &zero,
+		},
+		"alpha": {
+			Match: func(value string) bool {
+				for i := 0; i < len(value); i++ {
+					if !isAlpha(value[i]) {
+						return false
+					}
+				}
+				return value != ""
+			},
+			Type:    "string",
+			Pattern: "^[A-Za-z]+$",
+		},
+		"alnum": {
+			Match: func(value string) bool {
+				for i := 0; i < len(value); i++ {
+					if !isAlpha(value[i]) && !isDigit(value[i]) {
+						return false
+					}
+				}
+				return value != ""
+			},
+			Type:    "string",
+			Pattern: "^[A-Za-z0-9]+$",
+		},
+		"uuid": {
+			Match: func(value string) bool {
+				_, err := uuid.Parse(value)
+				return err == nil
+			},
+			Type:   "string",
+			Format: "uuid",
+		},
+	}
+)
+
+func isAlpha(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// ConstraintEnd returns the index after the brace that closes
+// the one at index `start` of a route template, or -1 if the
+// braces are unbalanced.
+func ConstraintEnd(route string, start int) int {
+	depth := 0
+	for i := start; i < len(route); i++ {
+		switch route[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return -1
+}
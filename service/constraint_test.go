@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraintEnd(t *testing.T) {
+	assert.Equal(t, 6, ConstraintEnd("{uint}", 0))
+	assert.Equal(t, 10, ConstraintEnd("{[0-9]{3}}/", 0))
+	assert.Equal(t, -1, ConstraintEnd("{[0-9]{3}", 0))
+}
+
+func TestBuiltinConstraints(t *testing.T) {
+	for name, c := range BuiltinConstraints {
+		assert.NotNil(t, c.Match, name)
+		assert.Contains(t, []string{"integer", "string"}, c.Type, name)
+	}
+
+	assert.True(t, BuiltinConstraints["uint"].Match("42"))
+	assert.False(t, BuiltinConstraints["uint"].Match("-42"))
+	assert.True(t, BuiltinConstraints["alnum"].Match("zalgo666"))
+	assert.False(t, BuiltinConstraints["alpha"].Match(""))
+}
@@ -11,7 +11,7 @@ import (
 
 var (
 	expectedRoute  = "/foo"
-	expectedPolicy = Policy{AllowTrailingSlashRedirects: true}
+	expectedPolicy = Policy{AllowTrailingSlashRedirects: httpx.Bool(true)}
 	emptyPolicy    = Policy{}
 )
 
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/shisa-platform/core/httpx"
+)
+
+// Group is a set of endpoints sharing a route prefix, handlers
+// and default policy.  Groups can be nested, in which case the
+// prefix, handlers and policy of the enclosing group or service
+// are inherited.
+type Group struct {
+	// Prefix is prepended to the routes of the group's
+	// endpoints and nested groups, e.g. "/accounts".  It must
+	// begin with '/' and must not end with '/'.
+	Prefix string
+
+	// Endpoints of the group.  Their routes are relative to
+	// `Prefix`.
+	Endpoints []Endpoint
+
+	// Groups are optional nested groups.
+	Groups []Group
+
+	// Handlers are optional handlers that should be invoked for
+	// all endpoints of the group.  These will be prepended to
+	// the endpoint handlers, after those of any enclosing groups
+	// and the service.
+	Handlers []httpx.Handler
+
+	// ResponseHandlers are optional handlers that should be
+	// invoked on the responses of all endpoints of the group.
+	// These will be appended to the endpoint response handlers,
+	// before those of any enclosing groups and the service.
+	ResponseHandlers []httpx.ResponseHandler
+
+	// Policy is the default policy of the group's pipelines.
+	// Its set fields override those of the enclosing group
+	// or service and are overridden by the set fields of
+	// the pipelines' policies, see `Policy.Merge`.
+	Policy Policy
+}
@@ -0,0 +1,8 @@
+package service
+
+// Maintenance describes which parts of a gateway are in
+// maintenance mode.
+type Maintenance struct {
+	Enabled  bool     // is the whole gateway in maintenance?
+	Services []string // names of the services in maintenance
+}
@@ -16,6 +16,10 @@ type Pipeline struct {
 	Policy       Policy                  // customizes automated behavior
 	Handlers     []httpx.Handler         // the pipline steps, minimum one
 	QuerySchemas []httpx.ParameterSchema // optional query parameter validation
+
+	// ResponseHandlers are optional handlers invoked in order on
+	// the response before it is sent to the user agent.
+	ResponseHandlers []httpx.ResponseHandler
 }
 
 func (p Pipeline) jsonify(buf *bytes.Buffer) {
@@ -28,5 +32,9 @@ func (p Pipeline) jsonify(buf *bytes.Buffer) {
 		buf.WriteString(",\"QuerySchemas\":")
 		enc.Encode(p.QuerySchemas)
 	}
+	if len(p.ResponseHandlers) != 0 {
+		buf.WriteString(",\"ResponseHandlers\":")
+		buf.WriteString(strconv.Itoa(len(p.ResponseHandlers)))
+	}
 	buf.WriteByte('}')
 }
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"github.com/shisa-platform/core/httpx"
+)
+
+// Route describes an endpoint installed in a gateway.
+type Route struct {
+	Service string        // name of the service owning the route
+	Route   string        // the route template, e.g. "/users/:id"
+	Hosts   []string      `json:",omitempty"` // host restrictions of the service
+	Methods []RouteMethod // the methods the route will service
+}
+
+// RouteMethod describes an installed pipeline of a route.
+type RouteMethod struct {
+	Method       string                  // the HTTP method
+	Policy       Policy                  // the pipeline's policy
+	QuerySchemas []httpx.ParameterSchema `json:",omitempty"`
+}
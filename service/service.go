@@ -11,13 +11,41 @@ import (
 // store dependencies in the struct.
 type Service struct {
 	Name      string     // Service name.  Required.
-	Endpoints []Endpoint // Service endpoints. Requried.
+	Endpoints []Endpoint // Service endpoints. Requried unless in `Groups`.
+
+	// BasePath is optionally prepended to the routes of all
+	// endpoints and groups, e.g. "/v2".  It must begin with '/'
+	// and must not end with '/'.
+	BasePath string
+
+	// Groups are optional sets of endpoints sharing a route
+	// prefix, handlers and default policy.
+	Groups []Group
+
+	// Policy is the optional default policy of all pipelines.
+	// The set fields of the policies of groups and
+	// pipelines override it, see `Policy.Merge`.
+	Policy Policy
+
+	// Hosts optionally restricts the service to requests for
+	// the given host names.  Names are either exact, e.g.
+	// "api.example.com", or a wildcard for any subdomain, e.g.
+	// "*.example.com".
+	// If empty the service will handle requests for any host
+	// not claimed by another service.
+	Hosts []string
 
 	// Handlers are optional handlers that should be invoked for
 	// all endpoints.  These will be prepended to all endpoint
 	// handlers when a service is registered.
 	Handlers []httpx.Handler
 
+	// ResponseHandlers are optional handlers that should be
+	// invoked on the responses of all endpoints.  These will be
+	// appended to all endpoint response handlers when a service
+	// is registered.
+	ResponseHandlers []httpx.ResponseHandler
+
 	// MalformedRequestHandler optionally customizes the
 	// response to the user agent when a malformed request is
 	// presented.
@@ -41,6 +69,14 @@ type Service struct {
 	// body.
 	RedirectHandler httpx.Handler
 
+	// OverloadedHandler optionally customizes the response
+	// returned to the user agent when a request is rejected
+	// because a pipeline has reached its `Policy.MaxConcurrency`
+	// and wait queue limits, e.g. to return 429 instead.
+	// If nil the default handler will return a 503 status code
+	// with an empty body.
+	OverloadedHandler httpx.Handler
+
 	// InternalServerErrorHandler optionally customizes the
 	// response returned to the user agent when the gateway
 	// encounters an error trying to service a request to an
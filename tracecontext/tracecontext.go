@@ -0,0 +1,232 @@
+// Package tracecontext propagates the W3C Trace Context
+// "traceparent" and "tracestate" headers.  It works
+// independently of the OpenTracing tracer in use, so traces
+// continue through the gateway even when tracing is disabled.
+// See https://www.w3.org/TR/trace-context/
+package tracecontext
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/ansel1/merry"
+
+	"github.com/shisa-platform/core/context"
+)
+
+const (
+	// TraceParentHeader is the name of the header identifying
+	// the trace and the calling span.
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader is the name of the header carrying
+	// vendor specific trace data.
+	TraceStateHeader = "tracestate"
+
+	// FlagSampled is set in `TraceContext.Flags` if the caller
+	// may have recorded the trace.
+	FlagSampled byte = 0x01
+
+	version          = "00"
+	traceParentLen   = 55
+	maxTraceStateLen = 512
+)
+
+type key struct{}
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid returns true if the id is not all zeros.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// String returns the id in lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid returns true if the id is not all zeros.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// String returns the id in lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// TraceContext is the position of a request in a trace.
+type TraceContext struct {
+	TraceID  TraceID
+	ParentID SpanID
+	Flags    byte
+	// State is the value of the "tracestate" header.  It is
+	// passed along unchanged.
+	State string
+}
+
+// New returns the context of a new trace.
+func New(sampled bool) TraceContext {
+	var tc TraceContext
+	for !tc.TraceID.IsValid() {
+		randomize(tc.TraceID[:])
+	}
+	for !tc.ParentID.IsValid() {
+		randomize(tc.ParentID[:])
+	}
+	if sampled {
+		tc.Flags = FlagSampled
+	}
+
+	return tc
+}
+
+// Child returns the context for a call made by the span
+// identified by this context, i.e. with the same trace, flags
+// and state and a new parent id.
+func (tc TraceContext) Child() TraceContext {
+	child := tc
+	child.ParentID = SpanID{}
+	for !child.ParentID.IsValid() {
+		randomize(child.ParentID[:])
+	}
+
+	return child
+}
+
+// Sampled returns true if `FlagSampled` is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&FlagSampled != 0
+}
+
+// String returns the value of the "traceparent" header for the
+// context.
+func (tc TraceContext) String() string {
+	var b [traceParentLen]byte
+	copy(b[:], version)
+	b[2] = '-'
+	hex.Encode(b[3:35], tc.TraceID[:])
+	b[35] = '-'
+	hex.Encode(b[36:52], tc.ParentID[:])
+	b[52] = '-'
+	hex.Encode(b[53:], []byte{tc.Flags})
+
+	return string(b[:])
+}
+
+// Parse decodes the value of a "traceparent" header.  Values
+// with a future version are accepted as long as the fields
+// known to version "00" are valid.
+func Parse(traceparent string) (tc TraceContext, err merry.Error) {
+	value := strings.TrimSpace(traceparent)
+	if len(value) < traceParentLen {
+		return tc, merry.New("trace context: parse: too short").Append(traceparent)
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return tc, merry.New("trace context: parse: malformed").Append(traceparent)
+	}
+
+	ver := value[:2]
+	if !isLowerHex(ver) || ver == "ff" {
+		return tc, merry.New("trace context: parse: invalid version").Append(traceparent)
+	}
+	if ver == version && len(value) != traceParentLen {
+		return tc, merry.New("trace context: parse: malformed").Append(traceparent)
+	}
+	if len(value) > traceParentLen && value[traceParentLen] != '-' {
+		return tc, merry.New("trace context: parse: malformed").Append(traceparent)
+	}
+
+	if !isLowerHex(value[3:35]) || !isLowerHex(value[36:52]) || !isLowerHex(value[53:55]) {
+		return tc, merry.New("trace context: parse: invalid hex").Append(traceparent)
+	}
+
+	hex.Decode(tc.TraceID[:], []byte(value[3:35]))
+	hex.Decode(tc.ParentID[:], []byte(value[36:52]))
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(value[53:55]))
+	tc.Flags = flags[0]
+
+	if !tc.TraceID.IsValid() {
+		return tc, merry.New("trace context: parse: trace id is zero").Append(traceparent)
+	}
+	if !tc.ParentID.IsValid() {
+		return tc, merry.New("trace context: parse: parent id is zero").Append(traceparent)
+	}
+
+	return tc, nil
+}
+
+// Extract returns the trace context sent in the given headers.
+// An error is returned if the "traceparent" header is missing
+// or malformed, in which case "tracestate" is ignored.
+func Extract(header http.Header) (TraceContext, merry.Error) {
+	values := header[http.CanonicalHeaderKey(TraceParentHeader)]
+	if len(values) == 0 {
+		return TraceContext{}, merry.New("trace context: extract: header missing")
+	}
+	if len(values) > 1 {
+		return TraceContext{}, merry.New("trace context: extract: multiple headers")
+	}
+
+	tc, err := Parse(values[0])
+	if err != nil {
+		return tc, err
+	}
+
+	states := header[http.CanonicalHeaderKey(TraceStateHeader)]
+	if state := strings.TrimSpace(strings.Join(states, ",")); len(state) <= maxTraceStateLen {
+		tc.State = state
+	}
+
+	return tc, nil
+}
+
+// Inject sets the "traceparent" and, if there is any state, the
+// "tracestate" headers for the context, replacing any existing
+// values.
+func (tc TraceContext) Inject(header http.Header) {
+	header.Set(TraceParentHeader, tc.String())
+	if tc.State != "" {
+		header.Set(TraceStateHeader, tc.State)
+	} else {
+		header.Del(TraceStateHeader)
+	}
+}
+
+// FromContext returns the trace context of the given context,
+// if any.
+func FromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(key{}).(TraceContext)
+	return tc, ok
+}
+
+// WithTraceContext returns the given context configured with
+// the trace context.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return ctx.WithValue(key{}, tc)
+}
+
+func randomize(b []byte) {
+	if _, err := crand.Read(b); err != nil {
+		mrand.Read(b)
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,149 @@
+package tracecontext
+
+import (
+	stdctx "context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/shisa-platform/core/context"
+)
+
+const (
+	expectedTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	expectedTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	expectedParentID    = "00f067aa0ba902b7"
+)
+
+func TestParse(t *testing.T) {
+	tc, err := Parse(expectedTraceParent)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTraceID, tc.TraceID.String())
+	assert.Equal(t, expectedParentID, tc.ParentID.String())
+	assert.True(t, tc.Sampled())
+	assert.Equal(t, expectedTraceParent, tc.String())
+}
+
+func TestParseFutureVersion(t *testing.T) {
+	tc, err := Parse("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-zalgo")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTraceID, tc.TraceID.String())
+	assert.False(t, tc.Sampled())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", tc.String())
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, value := range map[string]string{
+		"empty":            "",
+		"short":            "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"long":             expectedTraceParent + "-zalgo",
+		"future long":      "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01zalgo",
+		"separator":        "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"version ff":       "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"version hex":      "0z-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"uppercase":        "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"flags hex":        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+		"zero trace id":    "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"zero parent id":   "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"parent id length": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-b7-01",
+	} {
+		_, err := Parse(value)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	header := make(http.Header)
+	header.Set(TraceParentHeader, expectedTraceParent)
+	header.Add(TraceStateHeader, "congo=t61rcWkgMzE")
+	header.Add(TraceStateHeader, "rojo=00f067aa0ba902b7")
+
+	tc, err := Extract(header)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTraceID, tc.TraceID.String())
+	assert.Equal(t, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7", tc.State)
+}
+
+func TestExtractMissing(t *testing.T) {
+	_, err := Extract(make(http.Header))
+	assert.Error(t, err)
+}
+
+func TestExtractMultiple(t *testing.T) {
+	header := make(http.Header)
+	header.Add(TraceParentHeader, expectedTraceParent)
+	header.Add(TraceParentHeader, expectedTraceParent)
+
+	_, err := Extract(header)
+	assert.Error(t, err)
+}
+
+func TestExtractStateTooLong(t *testing.T) {
+	header := make(http.Header)
+	header.Set(TraceParentHeader, expectedTraceParent)
+	header.Set(TraceStateHeader, "zalgo="+strings.Repeat("z", maxTraceStateLen))
+
+	tc, err := Extract(header)
+	assert.NoError(t, err)
+	assert.Empty(t, tc.State)
+}
+
+func TestInject(t *testing.T) {
+	tc, err := Parse(expectedTraceParent)
+	assert.NoError(t, err)
+	tc.State = "congo=t61rcWkgMzE"
+
+	header := make(http.Header)
+	tc.Inject(header)
+	assert.Equal(t, expectedTraceParent, header.Get(TraceParentHeader))
+	assert.Equal(t, "congo=t61rcWkgMzE", header.Get(TraceStateHeader))
+
+	tc.State = ""
+	tc.Inject(header)
+	assert.Equal(t, expectedTraceParent, header.Get(TraceParentHeader))
+	assert.Empty(t, header[TraceStateHeader])
+}
+
+func TestNew(t *testing.T) {
+	tc := New(true)
+	assert.True(t, tc.TraceID.IsValid())
+	assert.True(t, tc.ParentID.IsValid())
+	assert.True(t, tc.Sampled())
+
+	other := New(false)
+	assert.NotEqual(t, tc.TraceID, other.TraceID)
+	assert.False(t, other.Sampled())
+
+	parsed, err := Parse(tc.String())
+	assert.NoError(t, err)
+	assert.Equal(t, tc, parsed)
+}
+
+func TestChild(t *testing.T) {
+	tc, err := Parse(expectedTraceParent)
+	assert.NoError(t, err)
+	tc.State = "congo=t61rcWkgMzE"
+
+	child := tc.Child()
+	assert.Equal(t, tc.TraceID, child.TraceID)
+	assert.NotEqual(t, tc.ParentID, child.ParentID)
+	assert.True(t, child.ParentID.IsValid())
+	assert.Equal(t, tc.Flags, child.Flags)
+	assert.Equal(t, tc.State, child.State)
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.New(stdctx.Background())
+
+	_, ok := FromContext(ctx)
+	assert.False(t, ok)
+
+	tc := New(false)
+	ctx = WithTraceContext(ctx, tc)
+
+	actual, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, tc, actual)
+}